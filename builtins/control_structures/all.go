@@ -3,14 +3,20 @@ package controlStructures
 
 import (
 	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
 	"github.com/nikolalohinski/gonja/v2/parser"
 )
 
 var All = exec.NewControlStructureSet(map[string]parser.ControlStructureParser{
 	"autoescape": autoescapeParser,
 	"block":      blockParser,
+	"break":      breakParser,
+	"call":       callParser,
+	"continue":   continueParser,
+	"do":         doParser,
 	"extends":    extendsParser,
 	"filter":     filterParser,
+	"flush":      flushParser,
 	"for":        forParser,
 	"from":       fromParser,
 	"if":         ifParser,
@@ -19,5 +25,22 @@ var All = exec.NewControlStructureSet(map[string]parser.ControlStructureParser{
 	"macro":      macroParser,
 	"raw":        rawParser,
 	"set":        setParser,
+	"trans":      transParser,
 	"with":       withParser,
 })
+
+func init() {
+	// Registering the control structures lets templates using them be precompiled, see nodes.RegisterType
+	for _, controlStructure := range []nodes.ControlStructure{
+		&AutoescapeControlStructure{}, &BlockControlStructure{}, &BreakControlStructure{},
+		&CallControlStructure{}, &ContinueControlStructure{}, &DoControlStructure{},
+		&ExtendsControlStructure{}, &FilterControlStructure{}, &FlushControlStructure{}, &ForControlStructure{},
+		&FromImportControlStructure{}, &IfControlStructure{}, &ImportControlStructure{},
+		&IncludeControlStructure{}, &MacroControlStructure{}, &RawControlStructure{},
+		&SetControlStructure{}, &TransControlStructure{}, &WithControlStructure{},
+	} {
+		if err := nodes.RegisterType(controlStructure); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 type AutoescapeControlStructure struct {
 	Wrapper    *nodes.Wrapper
 	Autoescape bool
+	// Escaper is the name of the escaping strategy to switch to, if any
+	Escaper string
 }
 
 func (acs *AutoescapeControlStructure) Position() *tokens.Token {
@@ -22,9 +24,16 @@ func (acs *AutoescapeControlStructure) String() string {
 	return fmt.Sprintf("AutoescapeControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (acs *AutoescapeControlStructure) Apply(f nodes.ApplyFunc) error {
+	return nodes.ApplyTo(f, &acs.Wrapper)
+}
+
 func (acs *AutoescapeControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	sub := r.Inherit()
 	sub.Config.AutoEscape = acs.Autoescape
+	if acs.Escaper != "" {
+		sub.Config.Escaper = acs.Escaper
+	}
 
 	err := sub.ExecuteWrapper(acs.Wrapper)
 	if err != nil {
@@ -43,16 +52,21 @@ func autoescapeParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStruc
 	}
 	cs.Wrapper = wrapper
 
-	modeToken := args.Match(tokens.Name)
-	if modeToken == nil {
-		return nil, args.Error("A mode is required for autoescape cs.", nil)
-	}
-	if modeToken.Val == "true" {
+	if escaperToken := args.Match(tokens.String); escaperToken != nil {
 		cs.Autoescape = true
-	} else if modeToken.Val == "false" {
-		cs.Autoescape = false
+		cs.Escaper = escaperToken.Val
 	} else {
-		return nil, args.Error("Only 'true' or 'false' is valid as an autoescape cs.", nil)
+		modeToken := args.Match(tokens.Name)
+		if modeToken == nil {
+			return nil, args.Error("A mode is required for autoescape cs.", nil)
+		}
+		if modeToken.Val == "true" {
+			cs.Autoescape = true
+		} else if modeToken.Val == "false" {
+			cs.Autoescape = false
+		} else {
+			return nil, args.Error("Only 'true', 'false' or the name of an escaper is valid as an autoescape cs.", nil)
+		}
 	}
 
 	if !args.Stream().End() {
@@ -15,6 +15,7 @@ import (
 type BlockControlStructure struct {
 	location *tokens.Token
 	name     string
+	wrapper  *nodes.Wrapper
 }
 
 func (bcs *BlockControlStructure) Position() *tokens.Token {
@@ -25,19 +26,46 @@ func (bcs *BlockControlStructure) String() string {
 	return fmt.Sprintf("BlockControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Name returns the name of the block
+func (bcs *BlockControlStructure) Name() string {
+	return bcs.name
+}
+
+// Body returns the nodes of the block
+func (bcs *BlockControlStructure) Body() *nodes.Wrapper {
+	return bcs.wrapper
+}
+
+// Apply applies f to the nodes of the body of the block, which is shared with the blocks of the template
+func (bcs *BlockControlStructure) Apply(f nodes.ApplyFunc) error {
+	if bcs.wrapper == nil {
+		return nil
+	}
+	return bcs.wrapper.Apply(f)
+}
+
+func (bcs *BlockControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location": &bcs.location,
+		"name":     &bcs.name,
+		"wrapper":  &bcs.wrapper,
+	}
+}
+
 func (bcs *BlockControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	blocks := r.RootNode.GetBlocks(bcs.name)
-	block, blocks := blocks[0], blocks[1:]
-
-	if block == nil {
+	templates := r.RootNode.GetBlockTemplates(bcs.name)
+	if len(blocks) == 0 {
 		return errors.Errorf(`Unable to find block "%s"`, bcs.name)
 	}
+	block, blocks := blocks[0], blocks[1:]
 
-	sub := r.Inherit()
-	infos := &BlockInfos{Block: bcs, Renderer: sub, Blocks: blocks}
+	// Blocks are rendered with the autoescaping of the template defining them
+	sub := r.ForTemplate(templates[0].Identifier).Inherit()
+	infos := &BlockInfos{Block: bcs, Renderer: sub, Blocks: blocks, Templates: templates[1:]}
 
 	sub.Environment.Context.Set("super", infos.super)
-	sub.Environment.Context.Set("self", exec.Self(sub))
+	sub.Environment.Context.Set("self", exec.SelfValues(sub))
 
 	err := sub.ExecuteWrapper(block)
 	if err != nil {
@@ -48,30 +76,44 @@ func (bcs *BlockControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlSt
 }
 
 type BlockInfos struct {
-	Block    *BlockControlStructure
-	Renderer *exec.Renderer
-	Blocks   []*nodes.Wrapper
-	Root     *nodes.Template
+	Block     *BlockControlStructure
+	Renderer  *exec.Renderer
+	Blocks    []*nodes.Wrapper
+	Templates []*nodes.Template // templates defining the blocks, in the same order
+	Root      *nodes.Template
 }
 
-func (bi *BlockInfos) super() string {
+func (bi *BlockInfos) super() *exec.Value {
 	if len(bi.Blocks) <= 0 {
-		return ""
+		return exec.AsSafeValue("")
 	}
 	r := bi.Renderer
 	block, blocks := bi.Blocks[0], bi.Blocks[1:]
-	sub := r.Inherit()
+	sub := r.ForTemplate(bi.Templates[0].Identifier).Inherit()
 	var out strings.Builder
-	sub.Output = &out
+	sub.Buffer(&out)
 	infos := &BlockInfos{
-		Block:    bi.Block,
-		Renderer: sub,
-		Blocks:   blocks,
+		Block:     bi.Block,
+		Renderer:  sub,
+		Blocks:    blocks,
+		Templates: bi.Templates[1:],
 	}
-	sub.Environment.Context.Set("self", exec.Self(sub))
+	sub.Environment.Context.Set("self", exec.SelfValues(sub))
 	sub.Environment.Context.Set("super", infos.super)
-	sub.ExecuteWrapper(block)
-	return out.String()
+	if err := sub.ExecuteWrapper(block); err != nil {
+		return exec.AsValue(err)
+	}
+	// The content of the parent block was already escaped if need be
+	return sub.RenderedValue(out.String())
+}
+
+// DuplicateBlockError is returned when parsing a template defining a block twice
+type DuplicateBlockError struct {
+	Name string
+}
+
+func (e *DuplicateBlockError) Error() string {
+	return fmt.Sprintf("Block named '%s' already defined", e.Name)
 }
 
 func blockParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
@@ -91,7 +133,10 @@ func blockParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 		return nil, errors.New("Tag 'block' takes exactly 1 argument (an identifier).")
 	}
 
+	// Blocks can be rendered on their own, out of the loops around them
+	restore := p.WithLoopDepth(0)
 	wrapper, endargs, err := p.WrapUntil("endblock")
+	restore()
 	if err != nil {
 		return nil, err
 	}
@@ -112,9 +157,10 @@ func blockParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 	if !p.Template.Blocks.Exists(name.Val) {
 		p.Template.Blocks.Register(name.Val, wrapper)
 	} else {
-		return nil, args.Error(fmt.Sprintf("Block named '%s' already defined", name.Val), nil)
+		return nil, args.Locate(&DuplicateBlockError{Name: name.Val}, name)
 	}
 
 	block.name = name.Val
+	block.wrapper = wrapper
 	return block, nil
 }
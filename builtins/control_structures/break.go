@@ -0,0 +1,43 @@
+package controlStructures
+
+import (
+	"fmt"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+type BreakControlStructure struct {
+	location *tokens.Token
+}
+
+func (bcs *BreakControlStructure) Position() *tokens.Token {
+	return bcs.location
+}
+
+func (bcs *BreakControlStructure) String() string {
+	t := bcs.Position()
+	return fmt.Sprintf("BreakControlStructure(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (bcs *BreakControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &bcs.location}
+}
+
+func (bcs *BreakControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	return exec.ErrLoopBreak
+}
+
+func breakParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	if p.LoopDepth() == 0 {
+		return nil, args.Error("Tag 'break' must be used within a loop.", nil)
+	}
+	if !args.End() {
+		return nil, args.Error("Tag 'break' does not take any argument.", args.Current())
+	}
+	return &BreakControlStructure{
+		location: p.Current(),
+	}, nil
+}
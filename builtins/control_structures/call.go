@@ -0,0 +1,137 @@
+package controlStructures
+
+import (
+	"fmt"
+	"io"
+	"maps"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+type CallControlStructure struct {
+	location *tokens.Token
+	call     *nodes.Call
+	caller   *nodes.Macro
+}
+
+func (ccs *CallControlStructure) Position() *tokens.Token {
+	return ccs.location
+}
+
+func (ccs *CallControlStructure) String() string {
+	t := ccs.Position()
+	return fmt.Sprintf("CallControlStructure(Call=%s Line=%d Col=%d)", ccs.call, t.Line, t.Col)
+}
+
+// Call returns the call of the macro the body is passed to
+func (ccs *CallControlStructure) Call() *nodes.Call {
+	return ccs.call
+}
+
+// Caller returns the body of the tag, as the macro passed as caller
+func (ccs *CallControlStructure) Caller() *nodes.Macro {
+	return ccs.caller
+}
+
+func (ccs *CallControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyToEach(f, ccs.caller.Kwargs); err != nil {
+		return err
+	}
+	if err := nodes.ApplyTo(f, &ccs.call); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &ccs.caller.Wrapper)
+}
+
+func (ccs *CallControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location": &ccs.location,
+		"call":     &ccs.call,
+		"caller":   &ccs.caller,
+	}
+}
+
+func (ccs *CallControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	caller, err := exec.MacroNodeToFunc(ccs.caller, r)
+	if err != nil {
+		return errors.Wrap(err, `Unable to build caller`)
+	}
+
+	sub := r.Inherit()
+	sub.Environment.Context.Set(exec.CallerKeyword, exec.Caller(caller))
+
+	// The caller is passed as an additional keyword argument referencing
+	// the caller set in the sub context, the original node being left untouched
+	call := *ccs.call
+	call.Kwargs = maps.Clone(ccs.call.Kwargs)
+	call.Kwargs[exec.CallerKeyword] = &nodes.Name{
+		Name: &tokens.Token{
+			Type: tokens.Name,
+			Val:  exec.CallerKeyword,
+			Pos:  ccs.location.Pos,
+			Line: ccs.location.Line,
+			Col:  ccs.location.Col,
+		},
+	}
+
+	value := sub.Eval(&call)
+	if value.IsError() {
+		return errors.Wrapf(value, `Unable to call %s`, ccs.call.Func)
+	}
+
+	_, err = io.WriteString(r.Output, value.String())
+	return err
+}
+
+func callParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	cs := &CallControlStructure{
+		location: p.Current(),
+	}
+	cs.caller = &nodes.Macro{
+		Location: cs.location,
+		Name:     exec.CallerKeyword,
+		Kwargs:   []*nodes.Pair{},
+	}
+
+	if args.Match(tokens.LeftParenthesis) != nil {
+		kwargs, err := parseMacroArguments(p, args)
+		if err != nil {
+			return nil, err
+		}
+		cs.caller.Kwargs = kwargs
+	}
+
+	expression, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	call, ok := expression.(*nodes.Call)
+	if !ok {
+		return nil, args.Error("Tag 'call' expects a macro call.", expression.Position())
+	}
+	cs.call = call
+
+	if !args.End() {
+		return nil, args.Error("Malformed call-tag args.", args.Current())
+	}
+
+	// The body is passed as a macro, called out of the loops enclosing the tag
+	restore := p.WithLoopDepth(0)
+	wrapper, endargs, err := p.WrapUntil("endcall")
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	cs.caller.Wrapper = wrapper
+
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	return cs, nil
+}
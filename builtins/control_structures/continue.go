@@ -0,0 +1,43 @@
+package controlStructures
+
+import (
+	"fmt"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+type ContinueControlStructure struct {
+	location *tokens.Token
+}
+
+func (ccs *ContinueControlStructure) Position() *tokens.Token {
+	return ccs.location
+}
+
+func (ccs *ContinueControlStructure) String() string {
+	t := ccs.Position()
+	return fmt.Sprintf("ContinueControlStructure(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (ccs *ContinueControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &ccs.location}
+}
+
+func (ccs *ContinueControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	return exec.ErrLoopContinue
+}
+
+func continueParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	if p.LoopDepth() == 0 {
+		return nil, args.Error("Tag 'continue' must be used within a loop.", nil)
+	}
+	if !args.End() {
+		return nil, args.Error("Tag 'continue' does not take any argument.", args.Current())
+	}
+	return &ContinueControlStructure{
+		location: p.Current(),
+	}, nil
+}
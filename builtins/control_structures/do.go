@@ -0,0 +1,103 @@
+package controlStructures
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+type DoControlStructure struct {
+	location    *tokens.Token
+	expression  nodes.Expression
+	condition   nodes.Expression
+	alternative nodes.Expression
+}
+
+func (dcs *DoControlStructure) Position() *tokens.Token {
+	return dcs.location
+}
+
+func (dcs *DoControlStructure) String() string {
+	t := dcs.Position()
+	return fmt.Sprintf("DoControlStructure(Expression=%s Line=%d Col=%d)", dcs.expression, t.Line, t.Col)
+}
+
+// Expression returns the evaluated expression, along with its condition and alternative if any
+func (dcs *DoControlStructure) Expression() (expression, condition, alternative nodes.Expression) {
+	return dcs.expression, dcs.condition, dcs.alternative
+}
+
+func (dcs *DoControlStructure) Apply(f nodes.ApplyFunc) error {
+	for _, field := range []*nodes.Expression{&dcs.expression, &dcs.condition, &dcs.alternative} {
+		if err := nodes.ApplyTo(f, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dcs *DoControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location":    &dcs.location,
+		"expression":  &dcs.expression,
+		"condition":   &dcs.condition,
+		"alternative": &dcs.alternative,
+	}
+}
+
+// Execute evaluates the expression for its side effects only and discards the result
+func (dcs *DoControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	expression := dcs.expression
+	if dcs.condition != nil {
+		condition := r.Eval(dcs.condition)
+		if condition.IsError() {
+			return errors.Wrapf(condition, `Unable to evaluate condition %s`, dcs.condition)
+		}
+		if condition.IsNil() || !condition.IsTrue() {
+			expression = dcs.alternative
+		}
+	}
+	if expression == nil {
+		return nil
+	}
+
+	value := r.Eval(expression)
+	if value.IsError() {
+		return errors.Wrapf(value, `Unable to evaluate expression %s`, expression)
+	}
+	return nil
+}
+
+func doParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	cs := &DoControlStructure{
+		location: p.Current(),
+	}
+
+	if args.End() {
+		return nil, args.Error("Tag 'do' requires an expression.", nil)
+	}
+
+	expression, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	cs.expression = expression
+
+	condition, alternative, err := args.ParseCondition()
+	if err != nil {
+		return nil, err
+	}
+	cs.condition = condition
+	cs.alternative = alternative
+
+	if !args.End() {
+		return nil, args.Error("Malformed 'do' tag args.", args.Current())
+	}
+
+	return cs, nil
+}
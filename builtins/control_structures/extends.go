@@ -3,6 +3,8 @@ package controlStructures
 import (
 	"fmt"
 
+	"github.com/pkg/errors"
+
 	"github.com/nikolalohinski/gonja/v2/exec"
 	"github.com/nikolalohinski/gonja/v2/nodes"
 	"github.com/nikolalohinski/gonja/v2/parser"
@@ -13,6 +15,11 @@ type ExtendsControlStructure struct {
 	location    *tokens.Token
 	filename    string
 	withContext bool
+	// expression, condition and alternative select the extended template at render time,
+	// unless it is named with a string literal and extended when parsing
+	expression  nodes.Expression
+	condition   nodes.Expression
+	alternative nodes.Expression
 }
 
 func (ecs *ExtendsControlStructure) Position() *tokens.Token {
@@ -21,13 +28,89 @@ func (ecs *ExtendsControlStructure) Position() *tokens.Token {
 
 func (ecs *ExtendsControlStructure) String() string {
 	t := ecs.Position()
+	if ecs.expression != nil {
+		return fmt.Sprintf("ExtendsControlStructure(Expression=%s Line=%d Col=%d)", ecs.expression, t.Line, t.Col)
+	}
 	return fmt.Sprintf("ExtendsControlStructure(Filename=%s Line=%d Col=%d)", ecs.filename, t.Line, t.Col)
 }
 
-func (ecs *ExtendsControlStructure) Execute(r *exec.Renderer) error {
+// Filename returns the identifier of the extended template, or an empty string when it is selected at render time
+func (ecs *ExtendsControlStructure) Filename() string {
+	return ecs.filename
+}
+
+// Expression returns the expression selecting the extended template at render time along with its optional
+// condition and alternative, which are nil when the template is named with a string literal
+func (ecs *ExtendsControlStructure) Expression() (expression, condition, alternative nodes.Expression) {
+	return ecs.expression, ecs.condition, ecs.alternative
+}
+
+// WithContext tells whether the tag ends with 'with context'
+func (ecs *ExtendsControlStructure) WithContext() bool {
+	return ecs.withContext
+}
+
+func (ecs *ExtendsControlStructure) Apply(f nodes.ApplyFunc) error {
+	for _, field := range []*nodes.Expression{&ecs.expression, &ecs.condition, &ecs.alternative} {
+		if err := nodes.ApplyTo(f, field); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (ecs *ExtendsControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location":    &ecs.location,
+		"filename":    &ecs.filename,
+		"withContext": &ecs.withContext,
+		"expression":  &ecs.expression,
+		"condition":   &ecs.condition,
+		"alternative": &ecs.alternative,
+	}
+}
+
+// Execute renders the template selected at render time in place of the one being rendered. Templates named
+// with a string literal are extended when parsing, so there is nothing left to do.
+func (ecs *ExtendsControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	expression := ecs.expression
+	if expression == nil {
+		return nil
+	}
+	if ecs.condition != nil {
+		condition := r.Eval(ecs.condition)
+		if condition.IsError() {
+			return errors.Wrapf(condition, `Unable to evaluate condition %s`, ecs.condition)
+		}
+		if condition.IsNil() || !condition.IsTrue() {
+			expression = ecs.alternative
+		}
+		if expression == nil {
+			// Without alternative, the template is rendered on its own
+			return nil
+		}
+	}
+
+	value := r.Eval(expression)
+	if value.IsError() {
+		return errors.Wrapf(value, `Unable to evaluate template to extend %s`, expression)
+	}
+	var extended *exec.Template
+	switch v := value.Interface().(type) {
+	case *exec.Template:
+		extended = v
+	default:
+		if !value.IsString() && !value.IsList() {
+			return r.Locate(errors.Errorf("unable to extend %s: expected a template, the name of a template or a list of names", value.String()), expression.Position())
+		}
+		var err error
+		if extended, _, err = loadTemplate(r, value, "extends", tag); err != nil {
+			return err
+		}
+	}
+	return r.Extend(extended, tag.Location)
+}
+
 func extendsParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
 	cs := &ExtendsControlStructure{
 		location: p.Current(),
@@ -37,18 +120,42 @@ func extendsParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructur
 		return nil, args.Error("this template has already one parent", args.Current())
 	}
 
-	// var filename nodes.Node
-	if filename := args.Match(tokens.String); filename != nil {
+	if filename := args.Match(tokens.String); filename != nil && (args.End() || args.CurrentName("with", "without") != nil) {
 		cs.filename = filename.Val
 
 		extended, err := p.Extend(cs.filename)
 		if err != nil {
-			return nil, fmt.Errorf("unable to load template '%s': %s", filename, err)
+			return nil, parser.WithFrame(fmt.Errorf("unable to load template '%s': %w", cs.filename, err), parser.Frame{
+				Kind:     "extends",
+				Name:     cs.filename,
+				Template: p.Identifier(),
+				Line:     filename.Line,
+				Column:   filename.Col,
+			})
 		}
 
 		p.Template.Parent = extended
 	} else {
-		return nil, args.Error("tag 'extends' requires a template filename as string", args.Current())
+		if filename != nil {
+			args.Stream().Backup()
+		}
+		if args.End() {
+			return nil, args.Error("tag 'extends' requires a template", args.Current())
+		}
+		// The rendering of the template stops where its parent is selected, which cannot be done from within
+		// the body of a control structure, like a loop or a macro
+		if !p.TopLevel() {
+			return nil, args.Error("tag 'extends' selecting its template at render time must be at the top level of the template", args.Current())
+		}
+		// Other expressions select the template to extend at render time
+		expression, err := args.ParseExpression()
+		if err != nil {
+			return nil, err
+		}
+		cs.expression = expression
+		if cs.condition, cs.alternative, err = args.ParseCondition(); err != nil {
+			return nil, err
+		}
 	}
 
 	if tok := args.MatchName("with", "without"); tok != nil {
@@ -30,10 +30,35 @@ func (fcs *FilterControlStructure) String() string {
 	return fmt.Sprintf("FilterControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Body returns the filtered nodes
+func (fcs *FilterControlStructure) Body() *nodes.Wrapper {
+	return fcs.bodyWrapper
+}
+
+// Filters returns the filters applied to the body, in order
+func (fcs *FilterControlStructure) Filters() []*nodes.FilterCall {
+	return fcs.filterChain
+}
+
+func (fcs *FilterControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyToEach(f, fcs.filterChain); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &fcs.bodyWrapper)
+}
+
+func (fcs *FilterControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"position":    &fcs.position,
+		"bodyWrapper": &fcs.bodyWrapper,
+		"filterChain": &fcs.filterChain,
+	}
+}
+
 func (fcs *FilterControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	var out strings.Builder
 	sub := r.Inherit()
-	sub.Output = &out
+	sub.Buffer(&out)
 	// temp := bytes.NewBuffer(make([]byte, 0, 1024)) // 1 KiB size
 
 	err := sub.ExecuteWrapper(fcs.bodyWrapper)
@@ -61,7 +86,10 @@ func filterParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure
 		position: p.Current(),
 	}
 
+	// The body is rendered on its own before being filtered, loops around it cannot be controlled from within
+	restore := p.WithLoopDepth(0)
 	wrapper, _, err := p.WrapUntil("endfilter")
+	restore()
 	if err != nil {
 		return nil, err
 	}
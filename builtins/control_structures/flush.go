@@ -0,0 +1,42 @@
+package controlStructures
+
+import (
+	"fmt"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// FlushControlStructure sends what was rendered so far to the consumer of the output, like
+// an HTTP client or the iteration of exec.Template.Stream, when the output supports it
+type FlushControlStructure struct {
+	location *tokens.Token
+}
+
+func (fcs *FlushControlStructure) Position() *tokens.Token {
+	return fcs.location
+}
+
+func (fcs *FlushControlStructure) String() string {
+	t := fcs.Position()
+	return fmt.Sprintf("FlushControlStructure(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (fcs *FlushControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &fcs.location}
+}
+
+func (fcs *FlushControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	return r.Flush()
+}
+
+func flushParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	if !args.End() {
+		return nil, args.Error("Tag 'flush' does not take any argument.", args.Current())
+	}
+	return &FlushControlStructure{
+		location: p.Current(),
+	}, nil
+}
@@ -3,6 +3,9 @@ package controlStructures
 import (
 	"fmt"
 	"math"
+	"strings"
+
+	"github.com/pkg/errors"
 
 	"github.com/nikolalohinski/gonja/v2/exec"
 	"github.com/nikolalohinski/gonja/v2/nodes"
@@ -15,6 +18,7 @@ type ForControlStructure struct {
 	Value           string // only for maps: for key, value in map
 	ObjectEvaluator nodes.Expression
 	IfCondition     nodes.Expression
+	Recursive       bool
 
 	BodyWrapper  *nodes.Wrapper
 	EmptyWrapper *nodes.Wrapper
@@ -28,17 +32,45 @@ func (fcs *ForControlStructure) String() string {
 	return fmt.Sprintf("ForControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (fcs *ForControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyTo(f, &fcs.ObjectEvaluator); err != nil {
+		return err
+	}
+	if err := nodes.ApplyTo(f, &fcs.IfCondition); err != nil {
+		return err
+	}
+	if err := nodes.ApplyTo(f, &fcs.BodyWrapper); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &fcs.EmptyWrapper)
+}
+
 type LoopInfos struct {
 	index     int
 	index0    int
 	length    int
 	revindex  int
 	revindex0 int
+	depth     int
+	depth0    int
 	first     bool
 	last      bool
 	PrevItem  *exec.Value
 	NextItem  *exec.Value
 	lastValue *exec.Value
+	recurse   func(obj *exec.Value) *exec.Value
+}
+
+// Call renders the loop body again for the given iterable, which is only possible
+// within loops marked as recursive. The depth of the nested loop is increased by one
+func (li *LoopInfos) Call(va *exec.VarArgs) *exec.Value {
+	if li.recurse == nil {
+		return exec.AsValue(errors.New("tried to call a non-recursive loop"))
+	}
+	if len(va.Args) != 1 || len(va.KwArgs) != 0 {
+		return exec.AsValue(errors.Errorf("loop() takes exactly 1 positional argument but %d were given", len(va.Args)+len(va.KwArgs)))
+	}
+	return li.recurse(va.Args[0])
 }
 
 func (li *LoopInfos) Cycle(va *exec.VarArgs) *exec.Value {
@@ -51,17 +83,24 @@ func (li *LoopInfos) Changed(value *exec.Value) bool {
 	return !same
 }
 
-func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) (forError error) {
+func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	obj := r.Eval(fcs.ObjectEvaluator)
 	if obj.IsError() {
 		return obj
 	}
 
+	return fcs.iterate(r, obj, 1)
+}
+
+func (fcs *ForControlStructure) iterate(r *exec.Renderer, obj *exec.Value, depth int) (forError error) {
 	// Create loop struct
 	items := exec.NewDict()
 
 	// First iteration: filter values to ensure proper LoopInfos
 	obj.Iterate(func(idx, count int, key, value *exec.Value) bool {
+		if forError = r.Cancelled(); forError != nil {
+			return false
+		}
 		sub := r.Inherit()
 		ctx := sub.Environment.Context
 		pair := &exec.Pair{}
@@ -97,6 +136,9 @@ func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStru
 		items.Pairs = append(items.Pairs, pair)
 		return true
 	}, func() {})
+	if forError != nil {
+		return forError
+	}
 
 	// 2nd pass: all values are defined, render
 	length := len(items.Pairs)
@@ -104,6 +146,22 @@ func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStru
 		first:  true,
 		index0: -1,
 		length: length,
+		depth:  depth,
+		depth0: depth - 1,
+	}
+	if fcs.Recursive {
+		loop.recurse = func(obj *exec.Value) *exec.Value {
+			if obj.IsError() {
+				return obj
+			}
+			var out strings.Builder
+			sub := r.Inherit()
+			sub.Buffer(&out)
+			if err := fcs.iterate(sub, obj, depth+1); err != nil {
+				return exec.AsValue(errors.Wrapf(err, "unable to render recursive loop at depth %d", depth+1))
+			}
+			return sub.RenderedValue(out.String())
+		}
 	}
 	if len(items.Pairs) == 0 && fcs.EmptyWrapper != nil {
 		if err := r.Inherit().ExecuteWrapper(fcs.EmptyWrapper); err != nil {
@@ -111,6 +169,12 @@ func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStru
 		}
 	}
 	for idx, pair := range items.Pairs {
+		if err := r.Cancelled(); err != nil {
+			return err
+		}
+		if err := r.CountLoopIteration(); err != nil {
+			return err
+		}
 		sub := r.Inherit()
 		ctx := sub.Environment.Context
 
@@ -155,7 +219,10 @@ func (fcs *ForControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStru
 
 		// Render elements with updated context
 		err := sub.ExecuteWrapper(fcs.BodyWrapper)
-		if err != nil {
+		if errors.Is(err, exec.ErrLoopBreak) {
+			break
+		}
+		if err != nil && !errors.Is(err, exec.ErrLoopContinue) {
 			return err
 		}
 	}
@@ -203,12 +270,18 @@ func forParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, e
 		cs.IfCondition = ifCondition
 	}
 
+	if args.MatchName("recursive") != nil {
+		cs.Recursive = true
+	}
+
 	if !args.End() {
 		return nil, args.Error("Malformed for-loop args.", nil)
 	}
 
-	// Body wrapping
+	// Body wrapping, within which 'break' and 'continue' are allowed
+	restore := p.WithLoopDepth(p.LoopDepth() + 1)
 	wrapper, endargs, err := p.WrapUntil("else", "endfor")
+	restore()
 	if err != nil {
 		return nil, err
 	}
@@ -26,6 +26,24 @@ func (ics *IfControlStructure) String() string {
 	return fmt.Sprintf("IfControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (ics *IfControlStructure) Apply(f nodes.ApplyFunc) error {
+	for i := range ics.Wrappers {
+		if i < len(ics.Conditions) {
+			if err := nodes.ApplyTo(f, &ics.Conditions[i]); err != nil {
+				return err
+			}
+		}
+		if err := nodes.ApplyTo(f, &ics.Wrappers[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ics *IfControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &ics.location}
+}
+
 func (ics *IfControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	for i, condition := range ics.Conditions {
 		result := r.Eval(condition)
@@ -27,6 +27,35 @@ func (ics *ImportControlStructure) String() string {
 	return fmt.Sprintf("ImportControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// FilenameExpression returns the expression of the identifier of the imported template
+func (ics *ImportControlStructure) FilenameExpression() nodes.Expression {
+	return ics.filenameExpression
+}
+
+// As returns the name the imported template is bound to
+func (ics *ImportControlStructure) As() string {
+	return ics.as
+}
+
+// WithContext tells whether the imported template is given the context with 'with context',
+// its macros only seeing the global variables otherwise
+func (ics *ImportControlStructure) WithContext() bool {
+	return ics.withContext
+}
+
+func (ics *ImportControlStructure) Apply(f nodes.ApplyFunc) error {
+	return nodes.ApplyTo(f, &ics.filenameExpression)
+}
+
+func (ics *ImportControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location":           &ics.location,
+		"filenameExpression": &ics.filenameExpression,
+		"as":                 &ics.as,
+		"withContext":        &ics.withContext,
+	}
+}
+
 func (ics *ImportControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 
 	filenameValue := r.Eval(ics.filenameExpression)
@@ -34,24 +63,22 @@ func (ics *ImportControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlS
 		return errors.Wrap(filenameValue, `Unable to evaluate filename`)
 	}
 
-	filename, err := r.Loader.Resolve(filenameValue.String())
+	template, _, err := loadTemplate(r, filenameValue, "import", tag)
 	if err != nil {
-		return errors.Errorf("failed to resolve filename: %s", err)
+		return err
 	}
+	filename := template.Root().Identifier
 
-	loader, err := r.Loader.Inherit(filename)
-	if err != nil {
-		return fmt.Errorf("failed to inherit loader from '%s': %s", filename, r.Loader)
-	}
-
-	template, err := exec.NewTemplate(filename, r.Config, loader, r.Environment)
-	if err != nil {
-		return fmt.Errorf("unable to load template '%s': %s", filename, err)
+	// Imported macros are rendered with the autoescaping of the template defining them,
+	// and only see the context of the current template when imported with context
+	renderer := r
+	if !ics.withContext {
+		renderer = r.Isolate()
 	}
-
+	imported := renderer.ForLoaded(filename)
 	macros := map[string]exec.Macro{}
 	for name, macro := range template.Macros() {
-		fn, err := exec.MacroNodeToFunc(macro, r)
+		fn, err := exec.MacroNodeToFunc(macro, imported)
 		if err != nil {
 			return errors.Wrapf(err, `Unable to import macro '%s'`, name)
 		}
@@ -68,6 +95,7 @@ type FromImportControlStructure struct {
 	WithContext        bool
 	Template           *nodes.Template
 	As                 map[string]string
+	Aliases            []string                // aliases of As, in their source order
 	Macros             map[string]*nodes.Macro // alias/name -> macro instance
 }
 
@@ -80,6 +108,14 @@ func (fcs *FromImportControlStructure) String() string {
 	return fmt.Sprintf("FromImportControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+func (fcs *FromImportControlStructure) Apply(f nodes.ApplyFunc) error {
+	return nodes.ApplyTo(f, &fcs.FilenameExpression)
+}
+
+func (fcs *FromImportControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &fcs.location}
+}
+
 func (fcs *FromImportControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 
 	filenameValue := r.Eval(fcs.FilenameExpression)
@@ -87,25 +123,22 @@ func (fcs *FromImportControlStructure) Execute(r *exec.Renderer, tag *nodes.Cont
 		return errors.Wrap(filenameValue, `Unable to evaluate filename`)
 	}
 
-	filename, err := r.Loader.Resolve(filenameValue.String())
-	if err != nil {
-		return errors.Errorf("failed to resolve filename: %s", err)
-	}
-
-	loader, err := r.Loader.Inherit(filename)
+	template, _, err := loadTemplate(r, filenameValue, "import", tag)
 	if err != nil {
-		return fmt.Errorf("failed to inherit loader from '%s': %s", filename, r.Loader)
-	}
-
-	template, err := exec.NewTemplate(filename, r.Config, loader, r.Environment)
-	if err != nil {
-		return fmt.Errorf("unable to load template '%s': %s", filename, err)
+		return err
 	}
+	filename := template.Root().Identifier
 
 	imported := template.Macros()
-	for alias, name := range fcs.As {
+	renderer := r
+	if !fcs.WithContext {
+		renderer = r.Isolate()
+	}
+	renderer = renderer.ForLoaded(filename)
+	for _, alias := range fcs.Aliases {
+		name := fcs.As[alias]
 		node := imported[name]
-		fn, err := exec.MacroNodeToFunc(node, r)
+		fn, err := exec.MacroNodeToFunc(node, renderer)
 		if err != nil {
 			return errors.Wrapf(err, `Unable to import macro '%s'`, name)
 		}
@@ -114,6 +147,14 @@ func (fcs *FromImportControlStructure) Execute(r *exec.Renderer, tag *nodes.Cont
 	return nil
 }
 
+// alias records the macro imported under the given alias, keeping the order of the aliases
+func (fcs *FromImportControlStructure) alias(alias, name string) {
+	if _, ok := fcs.As[alias]; !ok {
+		fcs.Aliases = append(fcs.Aliases, alias)
+	}
+	fcs.As[alias] = name
+}
+
 func importParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
 	cs := &ImportControlStructure{
 		location: p.Current(),
@@ -182,9 +223,9 @@ func fromParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 				return nil, args.Error("Expected macro alias name (identifier).", nil)
 			}
 			// asName = aliasToken.Val
-			cs.As[alias.Val] = name.Val
+			cs.alias(alias.Val, name.Val)
 		} else {
-			cs.As[name.Val] = name.Val
+			cs.alias(name.Val, name.Val)
 		}
 
 		if tok := args.MatchName("with", "without"); tok != nil {
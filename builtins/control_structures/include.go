@@ -16,7 +16,10 @@ type IncludeControlStructure struct {
 	filenameExpression nodes.Expression
 	ignoreMissing      bool
 	withContext        bool
-	isEmpty            bool
+	// variables are given to the included template along with the context, unless it is 'only' given those
+	variables nodes.Expression
+	only      bool
+	isEmpty   bool
 }
 
 func (ics *IncludeControlStructure) Position() *tokens.Token {
@@ -28,6 +31,47 @@ func (ics *IncludeControlStructure) String() string {
 	return fmt.Sprintf("IncludeControlStructure(Filename=%s Line=%d Col=%d)", ics.filenameExpression, t.Line, t.Col)
 }
 
+// FilenameExpression returns the expression of the identifier of the included template
+func (ics *IncludeControlStructure) FilenameExpression() nodes.Expression {
+	return ics.filenameExpression
+}
+
+// IgnoreMissing tells whether a missing template is ignored rather than failing the rendering
+func (ics *IncludeControlStructure) IgnoreMissing() bool {
+	return ics.ignoreMissing
+}
+
+// WithContext tells whether the included template is given the context, which is the case
+// unless the tag ends with 'without context' or 'only'
+func (ics *IncludeControlStructure) WithContext() bool {
+	return ics.withContext
+}
+
+// Variables returns the expression of the variables given to the included template with 'with',
+// and whether it is given those only
+func (ics *IncludeControlStructure) Variables() (nodes.Expression, bool) {
+	return ics.variables, ics.only
+}
+
+func (ics *IncludeControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyTo(f, &ics.filenameExpression); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &ics.variables)
+}
+
+func (ics *IncludeControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location":           &ics.location,
+		"filenameExpression": &ics.filenameExpression,
+		"ignoreMissing":      &ics.ignoreMissing,
+		"withContext":        &ics.withContext,
+		"variables":          &ics.variables,
+		"only":               &ics.only,
+		"isEmpty":            &ics.isEmpty,
+	}
+}
+
 func (ics *IncludeControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	if ics.isEmpty {
 		return nil
@@ -38,39 +82,41 @@ func (ics *IncludeControlStructure) Execute(r *exec.Renderer, tag *nodes.Control
 		return errors.Wrap(filenameValue, `Unable to evaluate filename`)
 	}
 
-	filename, err := r.Loader.Resolve(filenameValue.String())
+	included, loader, err := loadTemplate(r, filenameValue, "include", tag)
 	if err != nil {
 		if ics.ignoreMissing {
 			return nil
-		} else {
-			return errors.Errorf("failed to resolve filename: %s", err)
 		}
+		return err
 	}
 
-	loader, err := r.Loader.Inherit(filename)
-	if err != nil {
-		if ics.ignoreMissing {
-			return nil
-		} else {
-			return errors.Errorf("failed to inherit loader: %s", err)
-		}
+	// The included template is rendered with its own context, so that variables it sets do not leak
+	sub := r.Inherit()
+	if !ics.withContext {
+		sub = r.Isolate()
 	}
-
-	included, err := exec.NewTemplate(filename, r.Config, loader, r.Environment)
-	if err != nil {
-		if ics.ignoreMissing {
-			return nil
-		} else {
-			return fmt.Errorf("unable to load template '%s': %s", filename, err)
+	if ics.variables != nil {
+		variables := r.Eval(ics.variables)
+		if variables.IsError() {
+			return errors.Wrap(variables, `Unable to evaluate variables`)
 		}
+		if !variables.IsDict() {
+			return errors.Errorf("variables given to the included template must be a dict, not %s", variables.String())
+		}
+		variables.Iterate(func(idx, count int, key, value *exec.Value) bool {
+			sub.Environment.Context.Set(key.String(), value.Interface())
+			return true
+		}, func() {})
 	}
 
-	return exec.NewRenderer(r.Environment, r.Output, r.Config.Inherit(), loader, included).Execute()
+	filename := included.Root().Identifier
+	return r.WithFrame(sub.Spawn(included, loader).Execute(), "include", filename, tag.Location)
 }
 
 func includeParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
 	cs := &IncludeControlStructure{
-		location: p.Current(),
+		location:    p.Current(),
+		withContext: true,
 	}
 
 	filenameExpression, err := args.ParseExpression()
@@ -88,12 +134,23 @@ func includeParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructur
 	}
 
 	if tok := args.MatchName("with", "without"); tok != nil {
-		if args.MatchName("context") != nil {
+		switch {
+		case args.MatchName("context") != nil:
 			cs.withContext = tok.Val == "with"
-		} else {
+		case tok.Val == "with":
+			variables, err := args.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			cs.variables = variables
+		default:
 			args.Stream().Backup()
 		}
 	}
+	if cs.withContext && args.MatchName("only") != nil {
+		cs.withContext = false
+		cs.only = true
+	}
 
 	if !args.End() {
 		return nil, args.Error("Malformed 'include'-tag args.", nil)
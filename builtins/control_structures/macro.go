@@ -44,6 +44,38 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 		return nil, args.Error("Expected '('.", nil)
 	}
 
+	kwargs, err := parseMacroArguments(p, args)
+	if err != nil {
+		return nil, err
+	}
+	macro.Kwargs = kwargs
+
+	if !args.End() {
+		return nil, args.Error("Malformed macro-tag.", nil)
+	}
+
+	// Macros are called out of the loops enclosing their definition
+	restore := p.WithLoopDepth(0)
+	wrapper, endargs, err := p.WrapUntil("endmacro")
+	restore()
+	if err != nil {
+		return nil, err
+	}
+	macro.Wrapper = wrapper
+
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	p.Template.Macros[macro.Name] = macro
+
+	return &MacroControlStructure{macro}, nil
+}
+
+// parseMacroArguments parses a parenthesized list of macro arguments with their
+// optional default values, the opening parenthesis having already been consumed
+func parseMacroArguments(p *parser.Parser, args *parser.Parser) ([]*nodes.Pair, error) {
+	kwargs := []*nodes.Pair{}
 	for args.Match(tokens.RightParenthesis) == nil {
 		argName := args.Match(tokens.Name)
 		if argName == nil {
@@ -55,7 +87,7 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 			if err != nil {
 				return nil, err
 			}
-			macro.Kwargs = append(macro.Kwargs, &nodes.Pair{
+			kwargs = append(kwargs, &nodes.Pair{
 				Key: &nodes.String{
 					Location: argName,
 					Val:      argName.Val,
@@ -79,7 +111,7 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 					Location: argName,
 				}
 			}
-			macro.Kwargs = append(macro.Kwargs, arg)
+			kwargs = append(kwargs, arg)
 		}
 
 		if args.Match(tokens.RightParenthesis) != nil {
@@ -89,22 +121,5 @@ func macroParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 			return nil, args.Error("Expected ',' or ')'.", nil)
 		}
 	}
-
-	if !args.End() {
-		return nil, args.Error("Malformed macro-tag.", nil)
-	}
-
-	wrapper, endargs, err := p.WrapUntil("endmacro")
-	if err != nil {
-		return nil, err
-	}
-	macro.Wrapper = wrapper
-
-	if !endargs.End() {
-		return nil, endargs.Error("Arguments not allowed here.", nil)
-	}
-
-	p.Template.Macros[macro.Name] = macro
-
-	return &MacroControlStructure{macro}, nil
+	return kwargs, nil
 }
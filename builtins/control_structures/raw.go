@@ -11,7 +11,8 @@ import (
 )
 
 type RawControlStructure struct {
-	data *nodes.Data
+	data    *nodes.Data
+	wrapper *nodes.Wrapper
 }
 
 func (rcs *RawControlStructure) Position() *tokens.Token {
@@ -22,6 +23,27 @@ func (rcs *RawControlStructure) String() string {
 	return fmt.Sprintf("RawControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Data returns the content of the raw block
+func (rcs *RawControlStructure) Data() *nodes.Data {
+	return rcs.data
+}
+
+// Body returns the wrapper of the raw block, holding its end tag
+func (rcs *RawControlStructure) Body() *nodes.Wrapper {
+	return rcs.wrapper
+}
+
+func (rcs *RawControlStructure) Apply(f nodes.ApplyFunc) error {
+	return nodes.ApplyTo(f, &rcs.data)
+}
+
+func (rcs *RawControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"data":    &rcs.data,
+		"wrapper": &rcs.wrapper,
+	}
+}
+
 func (rcs *RawControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	_, err := io.WriteString(r.Output, rcs.data.Data.Val)
 	return err
@@ -34,6 +56,7 @@ func rawParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, e
 	if err != nil {
 		return nil, err
 	}
+	cs.wrapper = wrapper
 	node := wrapper.Nodes[0]
 	data, ok := node.(*nodes.Data)
 	if ok {
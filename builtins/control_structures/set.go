@@ -26,6 +26,35 @@ func (scs *SetControlStructure) String() string {
 	return fmt.Sprintf("SetControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Target returns the assigned name, attribute or item
+func (scs *SetControlStructure) Target() nodes.Expression {
+	return scs.target
+}
+
+// Expression returns the assigned expression, along with its condition and alternative if any
+func (scs *SetControlStructure) Expression() (expression, condition, alternative nodes.Expression) {
+	return scs.expression, scs.condition, scs.alternative
+}
+
+func (scs *SetControlStructure) Apply(f nodes.ApplyFunc) error {
+	for _, field := range []*nodes.Expression{&scs.target, &scs.expression, &scs.condition, &scs.alternative} {
+		if err := nodes.ApplyTo(f, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (scs *SetControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location":    &scs.location,
+		"target":      &scs.target,
+		"expression":  &scs.expression,
+		"condition":   &scs.condition,
+		"alternative": &scs.alternative,
+	}
+}
+
 func (scs *SetControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	var value *exec.Value
 	// Evaluate expression
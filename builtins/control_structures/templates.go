@@ -0,0 +1,57 @@
+package controlStructures
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+)
+
+// loadTemplate loads the template named by the value of the filename expression of an include, extends or
+// import tag. When it is a list, the first template which can be loaded is selected like
+// exec.Environment.SelectTemplate does. The template is returned with the loader to render it with.
+func loadTemplate(r *exec.Renderer, value *exec.Value, kind string, tag *nodes.ControlStructureBlock) (*exec.Template, loaders.Loader, error) {
+	if !value.IsList() {
+		return loadNamedTemplate(r, value.String(), kind, tag)
+	}
+	var names []string
+	value.Iterate(func(idx, count int, item, _ *exec.Value) bool {
+		names = append(names, item.String())
+		return true
+	}, func() {})
+
+	var errs []error
+	for _, name := range names {
+		template, loader, err := loadNamedTemplate(r, name, kind, tag)
+		if err == nil {
+			return template, loader, nil
+		}
+		// Only missing templates are skipped, not broken ones
+		if errors.As(err, new(*exec.TemplateError)) {
+			return nil, nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, nil, &exec.TemplateNotFoundError{Names: names, Errs: errs}
+}
+
+func loadNamedTemplate(r *exec.Renderer, name, kind string, tag *nodes.ControlStructureBlock) (*exec.Template, loaders.Loader, error) {
+	filename, err := r.Loader.Resolve(name)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to resolve filename: %s", err)
+	}
+
+	loader, err := r.Loader.Inherit(filename)
+	if err != nil {
+		return nil, nil, errors.Errorf("failed to inherit loader: %s", err)
+	}
+
+	template, err := r.LoadTemplate(filename, loader)
+	if err != nil {
+		return nil, nil, r.WithFrame(fmt.Errorf("unable to load template '%s': %w", filename, err), kind, filename, tag.Location)
+	}
+	return template, loader, nil
+}
@@ -0,0 +1,209 @@
+package controlStructures
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+type TransControlStructure struct {
+	location *tokens.Token
+	// Singular and Plural are the messages to translate, holding %(name)s placeholders for the variables
+	Singular string
+	Plural   string
+	// Count is the name of the variable selecting the plural form, if any
+	Count string
+	// Variables are the variables declared in the tag, by name
+	Variables map[string]nodes.Expression
+	// Referenced are the names used in the messages without being declared in the tag
+	Referenced []*nodes.Name
+	// Formatted tells whether the messages hold placeholders, otherwise they are rendered as is
+	Formatted bool
+	// Declared are the names of the variables declared in the tag, in order of declaration
+	Declared []string
+	// Trimmed tells whether the whitespaces of the messages are collapsed
+	Trimmed bool
+	// Wrappers hold the nodes of the singular message and of the plural one, if any
+	Wrappers []*nodes.Wrapper
+}
+
+func (tcs *TransControlStructure) Position() *tokens.Token {
+	return tcs.location
+}
+func (tcs *TransControlStructure) String() string {
+	t := tcs.Position()
+	return fmt.Sprintf("TransControlStructure(Line=%d Col=%d)", t.Line, t.Col)
+}
+
+func (tcs *TransControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyToMap(f, tcs.Variables); err != nil {
+		return err
+	}
+	return nodes.ApplyToEach(f, tcs.Referenced)
+}
+
+func (tcs *TransControlStructure) EncodedFields() map[string]any {
+	return map[string]any{"location": &tcs.location}
+}
+
+func (tcs *TransControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	var variables map[string]*exec.Value
+	if tcs.Formatted || tcs.Plural != "" {
+		variables = map[string]*exec.Value{}
+		for name, expression := range tcs.Variables {
+			value := r.Eval(expression)
+			if value.IsError() {
+				return errors.Wrapf(value, `unable to evaluate variable %s`, name)
+			}
+			variables[name] = value
+		}
+		for _, name := range tcs.Referenced {
+			value := r.Eval(name)
+			if value.IsError() {
+				return errors.Wrapf(value, `unable to evaluate variable %s`, name.Name.Val)
+			}
+			variables[name.Name.Val] = value
+		}
+	}
+
+	var translated *exec.Value
+	if tcs.Plural == "" {
+		translated = r.Evaluator().Gettext(tcs.Singular, variables)
+	} else {
+		count := variables[tcs.Count]
+		if !count.IsInteger() {
+			return errors.Errorf(`count variable %s must be an integer, got %s`, tcs.Count, count.String())
+		}
+		translated = r.Evaluator().NGettext(tcs.Singular, tcs.Plural, count.Integer(), variables)
+	}
+	if translated.IsError() {
+		return errors.Wrapf(translated, `unable to translate message at line %d`, tcs.location.Line)
+	}
+
+	_, err := io.WriteString(r.Output, translated.String())
+	return err
+}
+
+var transWhitespaces = regexp.MustCompile(`\s*\n\s*`)
+
+// transMessage builds the message of the wrapped nodes, replacing outputs of variables with placeholders
+func transMessage(args *parser.Parser, wrapper *nodes.Wrapper, trimmed bool, referenced map[string]*nodes.Name) (string, error) {
+	var message strings.Builder
+	for _, node := range wrapper.Nodes {
+		switch n := node.(type) {
+		case *nodes.Data:
+			message.WriteString(strings.ReplaceAll(n.Text(), "%", "%%"))
+		case *nodes.Output:
+			name, ok := n.Expression.(*nodes.Name)
+			if !ok || n.Condition != nil {
+				return "", args.Error("Only simple variables are allowed in trans blocks.", n.Position())
+			}
+			if _, exists := referenced[name.Name.Val]; !exists {
+				referenced[name.Name.Val] = name
+			}
+			message.WriteString("%(" + name.Name.Val + ")s")
+		case *nodes.Comment:
+		default:
+			return "", args.Error("Control structures are not allowed in trans blocks.", node.Position())
+		}
+	}
+	if !trimmed {
+		return message.String(), nil
+	}
+	return strings.TrimSpace(transWhitespaces.ReplaceAllString(message.String(), " ")), nil
+}
+
+func transParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	cs := &TransControlStructure{
+		location:  p.Current(),
+		Variables: map[string]nodes.Expression{},
+	}
+
+	for !args.End() {
+		key := args.Match(tokens.Name)
+		if key == nil {
+			return nil, args.Error("Expected an identifier.", args.Current())
+		}
+		switch {
+		case key.Val == "trimmed" || key.Val == "notrimmed":
+			cs.Trimmed = key.Val == "trimmed"
+		case args.Match(tokens.Assign) != nil:
+			value, err := args.ParseExpression()
+			if err != nil {
+				return nil, err
+			}
+			cs.Variables[key.Val] = value
+			cs.Declared = append(cs.Declared, key.Val)
+		default:
+			cs.Variables[key.Val] = &nodes.Name{Name: key}
+			cs.Declared = append(cs.Declared, key.Val)
+		}
+		if args.Match(tokens.Comma) == nil {
+			break
+		}
+	}
+	if !args.End() {
+		return nil, args.Error("Malformed trans controlStructure args.", nil)
+	}
+
+	referenced := map[string]*nodes.Name{}
+	wrapper, endargs, err := p.WrapUntil("pluralize", "endtrans")
+	if err != nil {
+		return nil, err
+	}
+	cs.Wrappers = append(cs.Wrappers, wrapper)
+	if cs.Singular, err = transMessage(args, wrapper, cs.Trimmed, referenced); err != nil {
+		return nil, err
+	}
+
+	if wrapper.EndTag == "pluralize" {
+		if count := endargs.Match(tokens.Name); count != nil {
+			cs.Count = count.Val
+			if _, declared := cs.Variables[count.Val]; !declared {
+				referenced[count.Val] = &nodes.Name{Name: count}
+			}
+		} else if len(cs.Declared) > 0 {
+			cs.Count = cs.Declared[0]
+		} else {
+			return nil, endargs.Error("A count variable is required to pluralize.", nil)
+		}
+		if !endargs.End() {
+			return nil, endargs.Error("Malformed pluralize args.", nil)
+		}
+
+		wrapper, endargs, err = p.WrapUntil("endtrans")
+		if err != nil {
+			return nil, err
+		}
+		cs.Wrappers = append(cs.Wrappers, wrapper)
+		if cs.Plural, err = transMessage(args, wrapper, cs.Trimmed, referenced); err != nil {
+			return nil, err
+		}
+	}
+	if !endargs.End() {
+		return nil, endargs.Error("Arguments not allowed here.", nil)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(referenced)) {
+		if _, declared := cs.Variables[name]; !declared {
+			cs.Referenced = append(cs.Referenced, referenced[name])
+		}
+	}
+	cs.Formatted = len(referenced) > 0
+	if !cs.Formatted && cs.Plural == "" {
+		// Messages without placeholders are rendered as is, just like the gettext function without variables
+		cs.Singular = strings.ReplaceAll(cs.Singular, "%%", "%")
+	}
+
+	return cs, nil
+}
@@ -14,7 +14,9 @@ import (
 type WithControlStructure struct {
 	location *tokens.Token
 	pairs    map[string]nodes.Expression
-	wrapper  *nodes.Wrapper
+	// names of the pairs, in their source order
+	names   []string
+	wrapper *nodes.Wrapper
 }
 
 func (wcs *WithControlStructure) Position() *tokens.Token {
@@ -25,10 +27,42 @@ func (wcs *WithControlStructure) String() string {
 	return fmt.Sprintf("WithControlStructure(Line=%d Col=%d)", t.Line, t.Col)
 }
 
+// Pairs returns the expressions of the variables set for the body, by name
+func (wcs *WithControlStructure) Pairs() map[string]nodes.Expression {
+	return wcs.pairs
+}
+
+// Names returns the names of the variables set for the body, in their source order
+func (wcs *WithControlStructure) Names() []string {
+	return wcs.names
+}
+
+// Body returns the nodes rendered with the variables set
+func (wcs *WithControlStructure) Body() *nodes.Wrapper {
+	return wcs.wrapper
+}
+
+func (wcs *WithControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyToMap(f, wcs.pairs); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &wcs.wrapper)
+}
+
+func (wcs *WithControlStructure) EncodedFields() map[string]any {
+	return map[string]any{
+		"location": &wcs.location,
+		"pairs":    &wcs.pairs,
+		"names":    &wcs.names,
+		"wrapper":  &wcs.wrapper,
+	}
+}
+
 func (wcs *WithControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
 	sub := r.Inherit()
 
-	for key, value := range wcs.pairs {
+	for _, key := range wcs.names {
+		value := wcs.pairs[key]
 		val := r.Eval(value)
 		if val.IsError() {
 			return errors.Wrapf(val, `unable to evaluate parameter %s`, value)
@@ -67,6 +101,9 @@ func withParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure,
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := cs.pairs[key.Val]; !ok {
+			cs.names = append(cs.names, key.Val)
+		}
 		cs.pairs[key.Val] = value
 
 		if args.Match(tokens.Comma) == nil {
@@ -0,0 +1,58 @@
+package builtins
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/utils"
+)
+
+// Escapers exports all builtins escaping strategies
+var Escapers = exec.NewEscaperSet(map[string]exec.Escaper{
+	"html":  utils.Escape,
+	"xml":   utils.Escape,
+	"json":  escapeJSON,
+	"yaml":  escapeJSON,
+	"shell": escapeShell,
+	"sql":   escapeSQL,
+	"latex": escapeLaTeX,
+})
+
+// escapeJSON renders the string as a double quoted JSON string, which is also a valid YAML scalar
+func escapeJSON(in string) string {
+	out, err := json.Marshal(in)
+	if err != nil {
+		// Marshalling a string can not fail, invalid UTF-8 being replaced
+		return `""`
+	}
+	return string(out)
+}
+
+// escapeShell renders the string as a single quoted POSIX shell word
+func escapeShell(in string) string {
+	return "'" + strings.ReplaceAll(in, "'", `'"'"'`) + "'"
+}
+
+// escapeSQL renders the string as a single quoted standard SQL string literal
+func escapeSQL(in string) string {
+	return "'" + strings.ReplaceAll(in, "'", "''") + "'"
+}
+
+var latexReplacer = strings.NewReplacer(
+	`\`, `\textbackslash{}`,
+	`{`, `\{`,
+	`}`, `\}`,
+	`$`, `\$`,
+	`&`, `\&`,
+	`#`, `\#`,
+	`%`, `\%`,
+	`_`, `\_`,
+	`^`, `\textasciicircum{}`,
+	`~`, `\textasciitilde{}`,
+)
+
+// escapeLaTeX escapes the characters having a special meaning in LaTeX text
+func escapeLaTeX(in string) string {
+	return latexReplacer.Replace(in)
+}
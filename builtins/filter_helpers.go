@@ -7,6 +7,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/pkg/errors"
+
 	"github.com/nikolalohinski/gonja/v2/exec"
 	"github.com/nikolalohinski/gonja/v2/utils"
 )
@@ -71,24 +73,24 @@ func stringifyFilterValue(value *exec.Value) string {
 	return value.String()
 }
 
-func escapeFilterValue(value *exec.Value) string {
+func escapeFilterValue(e *exec.Evaluator, value *exec.Value) string {
 	if value == nil || value.IsNil() {
 		return "None"
 	}
-	return utils.Escape(value.String())
+	return e.Escape(value).String()
 }
 
-func resolveAttributeValue(value *exec.Value, attribute *exec.Value, defaultValue *exec.Value) (*exec.Value, bool) {
+func resolveAttributeValue(e *exec.Evaluator, value *exec.Value, attribute *exec.Value, defaultValue *exec.Value) (*exec.Value, bool) {
 	if attribute == nil || attribute.IsNil() {
 		return value, true
 	}
 	if attribute.IsInteger() {
 		return resolveAttributeIndex(value, attribute.Integer(), defaultValue)
 	}
-	return resolveAttributePath(value, attribute.String(), defaultValue)
+	return resolveAttributePath(e, value, attribute.String(), defaultValue)
 }
 
-func resolveAttributePath(value *exec.Value, path string, defaultValue *exec.Value) (*exec.Value, bool) {
+func resolveAttributePath(e *exec.Evaluator, value *exec.Value, path string, defaultValue *exec.Value) (*exec.Value, bool) {
 	current := value
 	if path == "" {
 		return current, true
@@ -114,7 +116,10 @@ func resolveAttributePath(value *exec.Value, path string, defaultValue *exec.Val
 			continue
 		}
 
-		next, found := current.Get(part)
+		next, found := e.Get(current, part)
+		if !found && isDenied(next) {
+			return next, false
+		}
 		if !found {
 			if defaultValue != nil {
 				return defaultValue, true
@@ -127,6 +132,12 @@ func resolveAttributePath(value *exec.Value, path string, defaultValue *exec.Val
 	return current, true
 }
 
+// isDenied tells whether the value is the error returned when the security policy forbids
+// accessing an attribute, which filters return instead of taking the attribute as missing
+func isDenied(value *exec.Value) bool {
+	return value != nil && value.IsError() && errors.As(value, new(*exec.SecurityError))
+}
+
 func resolveAttributeIndex(value *exec.Value, index int, defaultValue *exec.Value) (*exec.Value, bool) {
 	if value == nil || value.IsNil() {
 		if defaultValue != nil {
@@ -109,7 +109,7 @@ func filterAttr(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 	); err != nil {
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
-	value, _ := in.GetAttribute(name)
+	value, _ := e.GetAttribute(in, name)
 	return value
 }
 
@@ -261,10 +261,7 @@ func filterEscape(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 	if err := params.Take(); err != nil {
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
-	if in.Safe {
-		return in
-	}
-	return exec.AsSafeValue(in.Escaped())
+	return e.Escape(in)
 }
 
 var (
@@ -352,7 +349,7 @@ func filterForceEscape(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs)
 	if err := params.Take(); err != nil {
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
-	return exec.AsSafeValue(in.Escaped())
+	return exec.AsEscapedValue(in.Escaped(), "html")
 }
 
 func filterFormat(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
@@ -389,15 +386,20 @@ func filterGroupBy(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exe
 		return true
 	}, func() {})
 
+	for _, item := range items {
+		if key, _ := resolveAttributeValue(e, item, attribute, defaultValue); isDenied(key) {
+			return key
+		}
+	}
 	sort.SliceStable(items, func(i, j int) bool {
-		left, _ := resolveAttributeValue(items[i], attribute, defaultValue)
-		right, _ := resolveAttributeValue(items[j], attribute, defaultValue)
+		left, _ := resolveAttributeValue(e, items[i], attribute, defaultValue)
+		right, _ := resolveAttributeValue(e, items[j], attribute, defaultValue)
 		return compareValues(left, right, caseSensitive) < 0
 	})
 
 	out := make([]groupTupleValue, 0)
 	for _, item := range items {
-		key, found := resolveAttributeValue(item, attribute, defaultValue)
+		key, found := resolveAttributeValue(e, item, attribute, defaultValue)
 		if !found {
 			continue
 		}
@@ -467,7 +469,7 @@ func filterIndent(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 	}
 	out := strings.Join(lines, "\n")
 	if in.Safe {
-		return exec.AsSafeValue(out)
+		return exec.AsEscapedValue(out, in.Escaper)
 	}
 	return exec.AsValue(out)
 }
@@ -551,15 +553,20 @@ func filterJoin(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 		return in
 	}
 	delimiter := stringifyFilterValue(d)
-	if e.Config.AutoEscape && !d.Safe {
-		delimiter = escapeFilterValue(d)
+	if e.Config.AutoEscape {
+		delimiter = escapeFilterValue(e, d)
 	}
 
 	parts := make([]string, 0)
+	var denied *exec.Value
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		item := key
 		if !attribute.IsNil() {
-			resolved, found := resolveAttributeValue(item, attribute, nil)
+			resolved, found := resolveAttributeValue(e, item, attribute, nil)
+			if isDenied(resolved) {
+				denied = resolved
+				return false
+			}
 			if found {
 				item = resolved
 			} else {
@@ -567,20 +574,19 @@ func filterJoin(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 			}
 		}
 		if e.Config.AutoEscape {
-			if item.Safe {
-				parts = append(parts, stringifyFilterValue(item))
-			} else {
-				parts = append(parts, escapeFilterValue(item))
-			}
+			parts = append(parts, escapeFilterValue(e, item))
 		} else {
 			parts = append(parts, stringifyFilterValue(item))
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	joined := strings.Join(parts, delimiter)
 	if e.Config.AutoEscape {
-		return exec.AsSafeValue(joined)
+		return exec.AsEscapedValue(joined, e.EscaperName())
 	}
 	return exec.AsValue(joined)
 }
@@ -694,10 +700,15 @@ func filterMap(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 	}
 
 	out := make([]any, 0)
+	var denied *exec.Value
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		val := key
 		if !attribute.IsNil() {
-			attr, found := resolveAttributeValue(val, attribute, defaultVal)
+			attr, found := resolveAttributeValue(e, val, attribute, defaultVal)
+			if isDenied(attr) {
+				denied = attr
+				return false
+			}
 			if found {
 				val = attr
 			} else {
@@ -710,6 +721,9 @@ func filterMap(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		out = append(out, val.Interface())
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 	return exec.AsValue(out)
 }
 
@@ -728,11 +742,15 @@ func filterMax(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
 
-	var max *exec.Value
+	var max, denied *exec.Value
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		val := key
 		if attribute != nil && !attribute.IsNil() {
-			attr, found := resolveAttributeValue(val, attribute, nil)
+			attr, found := resolveAttributeValue(e, val, attribute, nil)
+			if isDenied(attr) {
+				denied = attr
+				return false
+			}
 			if found {
 				val = attr
 			} else {
@@ -762,6 +780,9 @@ func filterMax(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	if max == nil {
 		return exec.AsValue("")
@@ -784,11 +805,15 @@ func filterMin(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
 
-	var min *exec.Value
+	var min, denied *exec.Value
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		val := key
 		if attribute != nil && !attribute.IsNil() {
-			attr, found := resolveAttributeValue(val, attribute, nil)
+			attr, found := resolveAttributeValue(e, val, attribute, nil)
+			if isDenied(attr) {
+				denied = attr
+				return false
+			}
 			if found {
 				val = attr
 			} else {
@@ -818,6 +843,9 @@ func filterMin(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	if min == nil {
 		return exec.AsValue("")
@@ -835,11 +863,14 @@ func filterPPrint(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 	); err != nil {
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
+	if err := e.CheckFields(in); err != nil {
+		return exec.AsValue(err)
+	}
 	b, err := json.MarshalIndent(in.Interface(), "", "  ")
 	if err != nil {
 		return exec.AsValue(errors.Wrapf(err, `Unable to pretty print '%s'`, in.String()))
 	}
-	return exec.AsSafeValue(string(b))
+	return exec.AsEscapedValue(string(b), "html")
 }
 
 func filterRandom(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
@@ -909,9 +940,14 @@ func filterRejectAttr(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *
 	}
 
 	out := make([]any, 0)
+	var denied *exec.Value
 
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
-		attr, _ := resolveAttributeValue(key, attribute, nil)
+		attr, _ := resolveAttributeValue(e, key, attribute, nil)
+		if isDenied(attr) {
+			denied = attr
+			return false
+		}
 		keep := false
 		if name == "" {
 			keep = !attr.IsTrue()
@@ -923,6 +959,9 @@ func filterRejectAttr(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	return exec.AsValue(out)
 }
@@ -1011,6 +1050,7 @@ func filterSafe(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
 	in.Safe = true
+	in.Escaper = ""
 	return in // nothing to do here, just to keep track of the safe application
 }
 
@@ -1115,6 +1155,15 @@ func filterSort(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 		return true
 	}, func() {})
 
+	if !attribute.IsNil() {
+		for _, item := range items {
+			for _, attr := range strings.Split(attribute.String(), ",") {
+				if value, _ := resolveAttributePath(e, item, attr, nil); isDenied(value) {
+					return value
+				}
+			}
+		}
+	}
 	sort.SliceStable(items, func(i, j int) bool {
 		if attribute.IsNil() {
 			comparison := compareValues(items[i], items[j], caseSensitive)
@@ -1125,8 +1174,8 @@ func filterSort(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.V
 		}
 
 		for _, attr := range strings.Split(attribute.String(), ",") {
-			left, _ := resolveAttributePath(items[i], attr, nil)
-			right, _ := resolveAttributePath(items[j], attr, nil)
+			left, _ := resolveAttributePath(e, items[i], attr, nil)
+			right, _ := resolveAttributePath(e, items[j], attr, nil)
 			comparison := compareValues(left, right, caseSensitive)
 			if comparison == 0 {
 				continue
@@ -1192,11 +1241,16 @@ func filterSum(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
 	sum := start
+	var denied *exec.Value
 
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		val := key
 		if attribute != nil && !attribute.IsNil() {
-			resolved, found := resolveAttributeValue(key, attribute, nil)
+			resolved, found := resolveAttributeValue(e, key, attribute, nil)
+			if isDenied(resolved) {
+				denied = resolved
+				return false
+			}
 			if !found {
 				return true
 			}
@@ -1207,6 +1261,9 @@ func filterSum(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Va
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	if sum == math.Trunc(sum) {
 		return exec.AsValue(int64(sum))
@@ -1259,6 +1316,9 @@ func filterToJSON(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
 	_ = ensureASCII // Accepted for compatibility, ignored (Go handles unicode differently than Python)
+	if err := e.CheckFields(in); err != nil {
+		return exec.AsValue(err)
+	}
 
 	marshalJSON := func(value any) (string, error) {
 		if indent.IsNil() {
@@ -1289,7 +1349,8 @@ func filterToJSON(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 			return exec.AsValue(errors.Wrap(err, "Unable to marhsall to json"))
 		}
 	}
-	return exec.AsSafeValue(out)
+	// The JSON is only safe to be embedded in HTML, like in Jinja
+	return exec.AsEscapedValue(out, "html")
 }
 
 func filterTruncate(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec.Value {
@@ -1351,11 +1412,16 @@ func filterUnique(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 
 	out := make([]any, 0)
 	tracker := map[any]bool{}
+	var denied *exec.Value
 
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
 		val := key
 		if !attribute.IsNil() {
-			nested, found := resolveAttributeValue(key, attribute, nil)
+			nested, found := resolveAttributeValue(e, key, attribute, nil)
+			if isDenied(nested) {
+				denied = nested
+				return false
+			}
 			if !found {
 				return true
 			}
@@ -1371,6 +1437,9 @@ func filterUnique(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	return exec.AsValue(out)
 }
@@ -1501,7 +1570,7 @@ func filterUrlize(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *exec
 	}
 
 	if e.Config.AutoEscape {
-		return exec.AsSafeValue(s)
+		return exec.AsEscapedValue(s, "html")
 	}
 	return exec.AsValue(s)
 }
@@ -1614,9 +1683,14 @@ func filterSelectAttr(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *
 	}
 
 	out := make([]any, 0)
+	var denied *exec.Value
 
 	in.Iterate(func(idx, count int, key, value *exec.Value) bool {
-		attr, _ := resolveAttributeValue(key, attribute, nil)
+		attr, _ := resolveAttributeValue(e, key, attribute, nil)
+		if isDenied(attr) {
+			denied = attr
+			return false
+		}
 		matched := false
 		if name == "" {
 			matched = attr.IsTrue()
@@ -1628,6 +1702,9 @@ func filterSelectAttr(e *exec.Evaluator, in *exec.Value, params *exec.VarArgs) *
 		}
 		return true
 	}, func() {})
+	if denied != nil {
+		return denied
+	}
 
 	return exec.AsValue(out)
 }
@@ -1,6 +1,8 @@
 package builtins
 
 import (
+	"maps"
+
 	"github.com/nikolalohinski/gonja/v2/exec"
 	"github.com/nikolalohinski/gonja/v2/utils"
 	"github.com/pkg/errors"
@@ -9,13 +11,16 @@ import (
 var GlobalFunctions = exec.NewContext(map[string]any{
 	"cycler":    cyclerFunction,
 	"dict":      dictFunction,
+	"_":         gettextFunction,
+	"gettext":   gettextFunction,
 	"joiner":    joinerFunction,
 	"lipsum":    lipSumFunction,
 	"namespace": namespaceFunction,
+	"ngettext":  ngettextFunction,
 	"range":     rangeFunction,
 })
 
-func rangeFunction(_ *exec.Evaluator, params *exec.VarArgs) (<-chan int, error) {
+func rangeFunction(e *exec.Evaluator, params *exec.VarArgs) (<-chan int, error) {
 	var (
 		start = 0
 		stop  = -1
@@ -39,18 +44,29 @@ func rangeFunction(_ *exec.Evaluator, params *exec.VarArgs) (<-chan int, error)
 		return nil, exec.ErrInvalidCall(errors.New("step cannot be 0"))
 	}
 
+	length := 0
+	if step > 0 && stop > start {
+		length = (stop - start + step - 1) / step
+	} else if step < 0 && start > stop {
+		length = (start - stop - step - 1) / -step
+	}
+	if err := e.CheckRangeLength(length); err != nil {
+		return nil, err
+	}
+
+	// Stop producing values as soon as the execution is cancelled,
+	// otherwise the goroutine would leak on large ranges
+	done := e.Context().Done()
 	channel := make(chan int)
 	go func() {
-		if step > 0 {
-			for i := start; i < stop; i += step {
-				channel <- i
-			}
-		} else {
-			for i := start; i > stop; i += step {
-				channel <- i
+		defer close(channel)
+		for i := start; step > 0 && i < stop || step < 0 && i > stop; i += step {
+			select {
+			case channel <- i:
+			case <-done:
+				return
 			}
 		}
-		close(channel)
 	}()
 	return channel, nil
 }
@@ -149,5 +165,41 @@ func lipSumFunction(_ *exec.Evaluator, params *exec.VarArgs) *exec.Value {
 	); err != nil {
 		return exec.AsValue(exec.ErrInvalidCall(err))
 	}
-	return exec.AsSafeValue(utils.Lipsum(n, html, min, max))
+	return exec.AsEscapedValue(utils.Lipsum(n, html, min, max), "html")
+}
+
+// gettextFunction translates a message, replacing its placeholders with the keyword arguments
+func gettextFunction(e *exec.Evaluator, params *exec.VarArgs) *exec.Value {
+	var message string
+	positional := &exec.VarArgs{Args: params.Args, KwArgs: map[string]*exec.Value{}}
+	if err := positional.Take(
+		exec.PositionalArgument("message", nil, exec.StringArgument(&message)),
+	); err != nil {
+		return exec.AsValue(exec.ErrInvalidCall(err))
+	}
+	// Messages are always formatted, so that '%' is consistently written '%%' in messages
+	variables := map[string]*exec.Value{}
+	maps.Copy(variables, params.KwArgs)
+	return e.Gettext(message, variables)
+}
+
+// ngettextFunction translates the singular or plural form of a message depending on a count,
+// replacing its placeholders with the keyword arguments, the count being available as 'num'
+func ngettextFunction(e *exec.Evaluator, params *exec.VarArgs) *exec.Value {
+	var (
+		singular string
+		plural   string
+		n        int
+	)
+	positional := &exec.VarArgs{Args: params.Args, KwArgs: map[string]*exec.Value{}}
+	if err := positional.Take(
+		exec.PositionalArgument("singular", nil, exec.StringArgument(&singular)),
+		exec.PositionalArgument("plural", nil, exec.StringArgument(&plural)),
+		exec.PositionalArgument("n", nil, exec.IntArgument(&n)),
+	); err != nil {
+		return exec.AsValue(exec.ErrInvalidCall(err))
+	}
+	variables := map[string]*exec.Value{}
+	maps.Copy(variables, params.KwArgs)
+	return e.NGettext(singular, plural, n, variables)
 }
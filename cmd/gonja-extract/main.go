@@ -0,0 +1,68 @@
+// Command gonja-extract extracts the translatable messages of all the templates of a directory, from their
+// trans blocks and their calls to the gettext functions, into a gettext .pot template. Translators then create
+// the .po files of their locale from it, with msginit or msgmerge:
+//
+//	gonja-extract -dir templates -ext .html,.txt -out messages.pot
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/i18n"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory holding the templates to extract messages from")
+	out := flag.String("out", "messages.pot", "file to write the catalog to, '-' for the standard output")
+	ext := flag.String("ext", "", "comma separated list of extensions of the templates to extract messages from, like '.j2,.html' (all files by default)")
+	flag.Parse()
+
+	if err := run(*dir, *out, *ext); err != nil {
+		fmt.Fprintf(os.Stderr, "gonja-extract: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(dir, out, ext string) error {
+	loader, err := loaders.NewFileSystemLoader(dir)
+	if err != nil {
+		return err
+	}
+	names, err := loader.(loaders.Lister).List()
+	if err != nil {
+		return err
+	}
+	if ext != "" {
+		extensions := strings.Split(ext, ",")
+		names = slices.DeleteFunc(names, func(name string) bool {
+			return !slices.Contains(extensions, path.Ext(name))
+		})
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no template found in '%s'", dir)
+	}
+
+	catalog, err := i18n.Extract(gonja.DefaultConfig, loader, gonja.DefaultEnvironment, names...)
+	if err != nil {
+		return err
+	}
+
+	var output io.Writer = os.Stdout
+	if out != "-" {
+		file, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	return catalog.WritePOT(output)
+}
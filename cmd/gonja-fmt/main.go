@@ -0,0 +1,104 @@
+// Command gonja-fmt formats the templates of a directory, normalizing the spaces within delimiters and indenting
+// the tags of nested control structures whose leading whitespaces are stripped by a '-' marker:
+//
+//	gonja-fmt -dir templates -ext .html,.txt -w
+//
+// Formatted templates are written to the standard output, unless -w rewrites them in place. With -check, the
+// names of the templates which are not formatted are listed instead, and it exits with status 1 if there are any.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/format"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory holding the templates to format")
+	ext := flag.String("ext", "", "comma separated list of extensions of the templates to format, like '.j2,.html' (all files by default)")
+	check := flag.Bool("check", false, "list the templates which are not formatted instead of formatting them, exiting with status 1 if any")
+	write := flag.Bool("w", false, "write the formatted templates to their files instead of the standard output")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gonja-fmt [flags] [template ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	unformatted, err := run(os.Stdout, *dir, *ext, *check, *write, flag.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gonja-fmt: %s\n", err)
+		os.Exit(2)
+	}
+	if *check && unformatted {
+		os.Exit(1)
+	}
+}
+
+// run formats the named templates, telling whether some of them were not formatted
+func run(w io.Writer, dir, ext string, check, write bool, names []string) (bool, error) {
+	loader, err := loaders.NewFileSystemLoader(dir)
+	if err != nil {
+		return false, err
+	}
+	if len(names) == 0 {
+		if names, err = loader.(loaders.Lister).List(); err != nil {
+			return false, err
+		}
+		if ext != "" {
+			extensions := strings.Split(ext, ",")
+			names = slices.DeleteFunc(names, func(name string) bool {
+				return !slices.Contains(extensions, path.Ext(name))
+			})
+		}
+		if len(names) == 0 {
+			return false, fmt.Errorf("no template found in '%s'", dir)
+		}
+	}
+
+	unformatted := false
+	for _, name := range names {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		source, err := os.ReadFile(file)
+		if err != nil {
+			return false, err
+		}
+		formatted, err := format.Source(name, string(source), gonja.DefaultConfig, loader, gonja.DefaultEnvironment)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case check:
+			if formatted != string(source) {
+				unformatted = true
+				if _, err := fmt.Fprintln(w, name); err != nil {
+					return false, err
+				}
+			}
+		case write:
+			if formatted == string(source) {
+				continue
+			}
+			info, err := os.Stat(file)
+			if err != nil {
+				return false, err
+			}
+			if err := os.WriteFile(file, []byte(formatted), info.Mode().Perm()); err != nil {
+				return false, err
+			}
+		default:
+			if _, err := io.WriteString(w, formatted); err != nil {
+				return false, err
+			}
+		}
+	}
+	return unformatted, nil
+}
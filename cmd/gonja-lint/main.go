@@ -0,0 +1,103 @@
+// Command gonja-lint reports common mistakes in the templates of a directory, like unknown filters and tests,
+// extends tags which are not the first tag of their template, unused macros and variables, shadowed loop variables,
+// default filters applied to constants and blocks defined twice:
+//
+//	gonja-lint -dir templates -ext .html,.txt -format json
+//
+// Diagnostics are written to the standard output either as text, as a JSON array, or as GitHub Actions workflow
+// commands annotating pull requests. It exits with status 1 when diagnostics are reported, and 2 when it fails.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/lint"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory holding the templates to lint")
+	ext := flag.String("ext", "", "comma separated list of extensions of the templates to lint, like '.j2,.html' (all files by default)")
+	format := flag.String("format", "text", "output format, either 'text', 'json' or 'github'")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: gonja-lint [flags] [template ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	diagnostics, err := run(*dir, *ext, flag.Args())
+	if err == nil {
+		err = write(os.Stdout, *format, diagnostics)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gonja-lint: %s\n", err)
+		os.Exit(2)
+	}
+	if len(diagnostics) > 0 {
+		os.Exit(1)
+	}
+}
+
+func run(dir, ext string, names []string) ([]lint.Diagnostic, error) {
+	loader, err := loaders.NewFileSystemLoader(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		if names, err = loader.(loaders.Lister).List(); err != nil {
+			return nil, err
+		}
+		if ext != "" {
+			extensions := strings.Split(ext, ",")
+			names = slices.DeleteFunc(names, func(name string) bool {
+				return !slices.Contains(extensions, path.Ext(name))
+			})
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no template found in '%s'", dir)
+		}
+	}
+
+	return lint.New(gonja.DefaultConfig, loader, gonja.DefaultEnvironment).Lint(names...)
+}
+
+func write(w io.Writer, format string, diagnostics []lint.Diagnostic) error {
+	switch format {
+	case "text":
+		for _, diagnostic := range diagnostics {
+			if _, err := fmt.Fprintln(w, diagnostic); err != nil {
+				return err
+			}
+		}
+	case "json":
+		if diagnostics == nil {
+			diagnostics = []lint.Diagnostic{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(diagnostics)
+	case "github":
+		for _, diagnostic := range diagnostics {
+			_, err := fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,title=%s::%s\n",
+				diagnostic.Severity, escape(diagnostic.File), diagnostic.Line, diagnostic.Column, diagnostic.Rule, escape(diagnostic.Message))
+			if err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unknown format '%s'", format)
+	}
+	return nil
+}
+
+// escape escapes the characters of workflow command values, see
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+var escape = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace
@@ -0,0 +1,39 @@
+// Command gonja-precompile parses all the templates of a directory and writes them to a bundle which
+// can be loaded back with gonja.LoadPrecompiled, skipping lexing and parsing at runtime. Templates are
+// parsed with gonja.DefaultConfig and gonja.DefaultEnvironment, which they must be loaded back with.
+// It is meant to be used from a go:generate directive next to a go:embed one:
+//
+//	//go:generate go run github.com/nikolalohinski/gonja/v2/cmd/gonja-precompile -dir templates -out templates.json
+//	//go:embed templates.json
+//	var bundle []byte
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory holding the templates to precompile")
+	out := flag.String("out", "templates.json", "file to write the precompiled templates to")
+	ext := flag.String("ext", "", "comma separated list of extensions of the templates to precompile, like '.j2,.html' (all files by default)")
+	flag.Parse()
+
+	var extensions []string
+	if *ext != "" {
+		extensions = strings.Split(*ext, ",")
+	}
+	bundle, err := gonja.PrecompileDirectory(*dir, gonja.DefaultConfig, gonja.DefaultEnvironment, extensions...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gonja-precompile: %s\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, bundle, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "gonja-precompile: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -1,6 +1,11 @@
 // Package config provides configuration types for the template engine.
 package config
 
+import (
+	"path"
+	"strings"
+)
+
 // Config holds plexer and parser parameters
 type Config struct {
 	// The string marking the beginning of a block. Defaults to '{%'
@@ -17,9 +22,17 @@ type Config struct {
 	CommentEndString string
 	// If set to True the XML/HTML autoescaping feature is enabled by default.
 	// For more details about autoescaping see Markup.
-	// This can also be a callable that is passed the template name
-	// and has to return True or False depending on autoescape should be enabled by default.
 	AutoEscape bool
+	// If set, this function is passed the identifier of every template, including the included,
+	// imported and extended ones, and has to return True or False depending on whether autoescape
+	// should be enabled by default for it, overriding AutoEscape. See SelectAutoEscape.
+	AutoEscapeFunc func(identifier string) bool
+	// The name of the escaping strategy applied when autoescaping is enabled, like 'html',
+	// 'yaml' or 'shell', among the escapers of the environment. Defaults to 'html'.
+	Escaper string
+	// Escaping strategies enabling autoescaping for the templates whose name ends
+	// with the given extensions, like {".sh": "shell", ".yaml": "yaml"}
+	EscapersByExtension map[string]string
 	// Whether to be strict about undefined attribute or item in an object and return error
 	// or return a nil value on missing data and ignore it entirely
 	StrictUndefined bool
@@ -46,6 +59,7 @@ func New() *Config {
 		CommentStartString:  "{#",
 		CommentEndString:    "#}",
 		AutoEscape:          false,
+		Escaper:             "html",
 		StrictUndefined:     false,
 		TrimBlocks:          false,
 		LeftStripBlocks:     false,
@@ -65,6 +79,9 @@ func (c *Config) Inherit() *Config {
 		CommentStartString:  c.CommentStartString,
 		CommentEndString:    c.CommentEndString,
 		AutoEscape:          c.AutoEscape,
+		AutoEscapeFunc:      c.AutoEscapeFunc,
+		Escaper:             c.Escaper,
+		EscapersByExtension: c.EscapersByExtension,
 		StrictUndefined:     c.StrictUndefined,
 		TrimBlocks:          c.TrimBlocks,
 		LeftStripBlocks:     c.LeftStripBlocks,
@@ -74,3 +91,50 @@ func (c *Config) Inherit() *Config {
 		LineCommentPrefix:   c.LineCommentPrefix,
 	}
 }
+
+// ForTemplate returns a copy of the configuration with autoescaping selected for the template
+// with the given identifier, according to AutoEscapeFunc and EscapersByExtension
+func (c *Config) ForTemplate(identifier string) *Config {
+	selected := c.Inherit()
+	if c.AutoEscapeFunc != nil {
+		selected.AutoEscape = c.AutoEscapeFunc(identifier)
+	}
+	if escaper, ok := c.EscapersByExtension[path.Ext(identifier)]; ok {
+		selected.AutoEscape = true
+		selected.Escaper = escaper
+	}
+	return selected
+}
+
+// SelectAutoEscape returns a function to be used as AutoEscapeFunc, like select_autoescape in Jinja.
+// It enables autoescaping for the templates whose identifier ends with one of the enabled extensions,
+// disables it for the ones ending with one of the disabled extensions, and returns the fallback value
+// for the other ones. Extensions are matched case insensitively, with or without their leading dot.
+//
+// Example:
+//
+//	cfg.AutoEscapeFunc = config.SelectAutoEscape([]string{"html", "xml"}, []string{"txt", "yaml"}, false)
+func SelectAutoEscape(enabled []string, disabled []string, fallback bool) func(identifier string) bool {
+	normalize := func(extensions []string) []string {
+		normalized := make([]string, len(extensions))
+		for i, extension := range extensions {
+			normalized[i] = "." + strings.ToLower(strings.TrimLeft(extension, "."))
+		}
+		return normalized
+	}
+	enabled, disabled = normalize(enabled), normalize(disabled)
+	return func(identifier string) bool {
+		identifier = strings.ToLower(identifier)
+		for _, extension := range enabled {
+			if strings.HasSuffix(identifier, extension) {
+				return true
+			}
+		}
+		for _, extension := range disabled {
+			if strings.HasSuffix(identifier, extension) {
+				return false
+			}
+		}
+		return fallback
+	}
+}
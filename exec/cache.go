@@ -0,0 +1,239 @@
+package exec
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+)
+
+// TemplateCache holds parsed templates by identifier, evicting the least
+// recently used ones once its size limit is reached. It is safe for concurrent use.
+//
+// Templates are only returned by environments to the templates loaded from the same loader
+// with the same syntax, so a cache should not be shared by environments using different
+// loaders or configurations: it would still be correct, but templates would keep replacing
+// each other. Loaders whose values are not comparable, like structs holding maps, bypass caches.
+type TemplateCache struct {
+	// AutoReload makes the cache check whether templates, and the ones they extend, changed in their
+	// loader before returning them, to parse them again if so. It requires loaders to implement loaders.Versioner
+	AutoReload bool
+
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+	lock    sync.Mutex
+}
+
+type cacheEntry struct {
+	identifier string
+	template   *Template
+}
+
+// NewTemplateCache creates a cache holding at most size templates. A size lower
+// or equal to zero means that the number of cached templates is not limited
+func NewTemplateCache(size int) *TemplateCache {
+	return &TemplateCache{
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Get returns the cached template with the given identifier, if any
+func (c *TemplateCache) Get(identifier string) (*Template, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	element, ok := c.entries[identifier]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).template, true
+}
+
+// Add stores the template with the given identifier, evicting the
+// least recently used template if the cache is full
+func (c *TemplateCache) Add(identifier string, template *Template) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[identifier]; ok {
+		element.Value.(*cacheEntry).template = template
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[identifier] = c.order.PushFront(&cacheEntry{
+		identifier: identifier,
+		template:   template,
+	})
+	if c.size > 0 && c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).identifier)
+	}
+}
+
+// Remove evicts the template with the given identifier from the cache
+func (c *TemplateCache) Remove(identifier string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if element, ok := c.entries[identifier]; ok {
+		c.order.Remove(element)
+		delete(c.entries, identifier)
+	}
+}
+
+// Purge evicts all the templates from the cache
+func (c *TemplateCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// Len returns the number of cached templates
+func (c *TemplateCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.order.Len()
+}
+
+// GetTemplate returns the template with the given name, resolved and loaded using the loader and
+// the configuration of the environment. Parsed templates are kept in the cache of the environment, if any.
+func (e *Environment) GetTemplate(name string) (*Template, error) {
+	if e.Loader == nil {
+		return nil, errors.New("environment has no loader to get templates from")
+	}
+	cfg := e.Config
+	if cfg == nil {
+		cfg = config.New()
+	}
+	identifier, err := e.Loader.Resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve template '%s': %s", name, err)
+	}
+	loader, err := e.Loader.Inherit(identifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inherit loader from '%s': %s", identifier, err)
+	}
+	return e.loadTemplate(identifier, cfg, loader, e.Loader)
+}
+
+// SelectTemplate returns the first of the named templates which can be loaded like GetTemplate does, letting
+// applications fall back to default templates. Templates which can not be resolved nor read are skipped,
+// but a template failing to parse is reported. If none is found, a *TemplateNotFoundError is returned.
+func (e *Environment) SelectTemplate(names ...string) (*Template, error) {
+	var errs []error
+	for _, name := range names {
+		template, err := e.GetTemplate(name)
+		if err == nil {
+			return template, nil
+		}
+		if errors.As(err, new(*TemplateError)) {
+			return nil, err
+		}
+		errs = append(errs, err)
+	}
+	return nil, &TemplateNotFoundError{Names: names, Errs: errs}
+}
+
+// LoadTemplate behaves like NewTemplate, but returns the template from the cache of the
+// environment if it was already parsed from the same loader with the same syntax, and adds it to the cache otherwise
+func (e *Environment) LoadTemplate(identifier string, config *config.Config, loader loaders.Loader) (*Template, error) {
+	return e.loadTemplate(identifier, config, loader, loader)
+}
+
+// loadTemplate loads a template through the cache within the given scope, being the loader
+// of the template rendered first when loading the templates it includes, imports or extends
+func (e *Environment) loadTemplate(identifier string, config *config.Config, loader, scope loaders.Loader) (*Template, error) {
+	if e.Cache == nil || !isComparable(scope) {
+		return newTemplate(identifier, config, loader, e, scope)
+	}
+	if template, ok := e.Cache.Get(identifier); ok && template.scope == scope && sameSyntax(template.config, config) {
+		if !e.Cache.AutoReload || template.upToDate() {
+			return template, nil
+		}
+	}
+	var version string
+	if versioner, ok := loader.(loaders.Versioner); ok && e.Cache.AutoReload {
+		// The version is read before the source so that changes happening in between trigger a reload
+		version, _ = versioner.Version(identifier)
+	}
+	template, err := newTemplate(identifier, config, loader, e, scope)
+	if err != nil {
+		e.Cache.Remove(identifier)
+		return nil, err
+	}
+	template.version = version
+	e.Cache.Add(identifier, template)
+	return template, nil
+}
+
+// isComparable tells whether the given loader can be compared to the scope of cached templates
+func isComparable(loader loaders.Loader) bool {
+	return loader != nil && reflect.TypeOf(loader).Comparable()
+}
+
+// sameSyntax tells whether templates parsed with the given configurations are parsed the same way
+func sameSyntax(a, b *config.Config) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return a.BlockStartString == b.BlockStartString &&
+		a.BlockEndString == b.BlockEndString &&
+		a.VariableStartString == b.VariableStartString &&
+		a.VariableEndString == b.VariableEndString &&
+		a.CommentStartString == b.CommentStartString &&
+		a.CommentEndString == b.CommentEndString &&
+		a.StrictUndefined == b.StrictUndefined &&
+		a.TrimBlocks == b.TrimBlocks &&
+		a.LeftStripBlocks == b.LeftStripBlocks &&
+		a.KeepTrailingNewline == b.KeepTrailingNewline &&
+		a.NewlineSequence == b.NewlineSequence &&
+		a.LineStatementPrefix == b.LineStatementPrefix &&
+		a.LineCommentPrefix == b.LineCommentPrefix
+}
+
+// upToDate returns true if neither the source of the template nor
+// the ones of the templates it extends changed since it was parsed
+func (t *Template) upToDate() bool {
+	if !loaders.IsUpToDate(t.loader, t.root.Identifier, t.version) {
+		return false
+	}
+	for _, parent := range t.extended {
+		if !parent.upToDate() {
+			return false
+		}
+	}
+	return true
+}
+
+// templateLoader lets the parser load extended templates through the cache of
+// the environment, keeping track of them to know when the template is out of date
+type templateLoader struct {
+	environment *Environment
+	template    *Template
+}
+
+func (t *templateLoader) LoadTemplate(identifier string, config *config.Config, loader loaders.Loader) (*nodes.Template, error) {
+	extended, err := t.environment.loadTemplate(identifier, config, loader, t.template.scope)
+	if err != nil {
+		return nil, err
+	}
+	t.template.extended = append(t.template.extended, extended)
+	return extended.root, nil
+}
+
+// LoadTemplate loads a template referenced by the one being rendered, like an included or
+// imported one, through the environment of the executed template and thus its cache
+func (r *Renderer) LoadTemplate(identifier string, loader loaders.Loader) (*Template, error) {
+	return r.Template.environment.loadTemplate(identifier, r.base, loader, r.Template.scope)
+}
@@ -0,0 +1,87 @@
+package exec_test
+
+import (
+	"github.com/nikolalohinski/gonja/v2/exec"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("template cache", func() {
+	var (
+		cache = new(*exec.TemplateCache)
+
+		first  = &exec.Template{}
+		second = &exec.Template{}
+		third  = &exec.Template{}
+
+		get = func(identifier string) *exec.Template {
+			template, ok := (*cache).Get(identifier)
+			Expect(ok).To(BeTrue())
+			return template
+		}
+	)
+	BeforeEach(func() {
+		*cache = exec.NewTemplateCache(2)
+		(*cache).Add("first", first)
+		(*cache).Add("second", second)
+	})
+	It("should return the cached templates", func() {
+		template, ok := (*cache).Get("first")
+		Expect(ok).To(BeTrue())
+		Expect(template).To(BeIdenticalTo(first))
+		Expect((*cache).Len()).To(Equal(2))
+	})
+	It("should not return unknown templates", func() {
+		_, ok := (*cache).Get("unknown")
+		Expect(ok).To(BeFalse())
+	})
+	Context("when the cache is full", func() {
+		BeforeEach(func() {
+			(*cache).Get("first")
+			(*cache).Add("third", third)
+		})
+		It("should evict the least recently used template", func() {
+			By("keeping the size within the limit")
+			Expect((*cache).Len()).To(Equal(2))
+			By("evicting the least recently used template")
+			_, ok := (*cache).Get("second")
+			Expect(ok).To(BeFalse())
+			By("keeping the recently used templates")
+			Expect(get("first")).To(BeIdenticalTo(first))
+			Expect(get("third")).To(BeIdenticalTo(third))
+		})
+	})
+	Context("when replacing a template", func() {
+		BeforeEach(func() {
+			(*cache).Add("first", third)
+		})
+		It("should return the new template", func() {
+			Expect((*cache).Len()).To(Equal(2))
+			Expect(get("first")).To(BeIdenticalTo(third))
+		})
+	})
+	Context("when removing templates", func() {
+		BeforeEach(func() {
+			(*cache).Remove("first")
+		})
+		It("should not return them anymore", func() {
+			_, ok := (*cache).Get("first")
+			Expect(ok).To(BeFalse())
+			Expect((*cache).Len()).To(Equal(1))
+			(*cache).Purge()
+			Expect((*cache).Len()).To(Equal(0))
+		})
+	})
+	Context("when the size is not limited", func() {
+		BeforeEach(func() {
+			*cache = exec.NewTemplateCache(0)
+			for _, identifier := range []string{"a", "b", "c", "d"} {
+				(*cache).Add(identifier, first)
+			}
+		})
+		It("should keep all templates", func() {
+			Expect((*cache).Len()).To(Equal(4))
+		})
+	})
+})
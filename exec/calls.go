@@ -9,8 +9,30 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Callable is implemented by objects which are not functions but can still
+// be called from within a template, like the 'loop' object of recursive loops
+type Callable interface {
+	Call(params *VarArgs) *Value
+}
+
 func (e *Evaluator) evalCall(node *nodes.Call) *Value {
+	if err := e.Cancelled(); err != nil {
+		return AsValue(err)
+	}
 	fn := e.Eval(node.Func)
+	callable, isCallable := fn.Interface().(Callable)
+	if isCallable || fn.IsCallable() {
+		if err := e.checkCallable(fn, node.Func.String()); err != nil {
+			return AsValue(err)
+		}
+	}
+	if isCallable {
+		params, err := e.evalVarArgs(node)
+		if err != nil {
+			return AsValue(errors.Wrapf(err, `unable to evaluate parameters`))
+		}
+		return callable.Call(params[0].Interface().(*VarArgs))
+	}
 	if !fn.IsCallable() {
 		getAttributeNode, ok := node.Func.(*nodes.GetAttribute)
 		if node.Parent == nil || !ok {
@@ -24,6 +46,7 @@ func (e *Evaluator) evalCall(node *nodes.Call) *Value {
 
 	var current reflect.Value
 	var isSafe bool
+	var escaper string
 
 	var params []reflect.Value
 	var err error
@@ -56,7 +79,7 @@ func (e *Evaluator) evalCall(node *nodes.Call) *Value {
 				return AsValue(fmt.Errorf("second return value of function '%s' is not an error", functionName))
 			}
 			if err, ok := err.(ErrInvalidCall); ok && err != nil {
-				return AsValue(fmt.Errorf("invalid call to function '%s': %s", functionName, err.Error()))
+				return AsValue(fmt.Errorf("invalid call to function '%s': %w", functionName, err))
 			} else if err != nil {
 				return AsValue(err)
 			}
@@ -69,16 +92,24 @@ func (e *Evaluator) evalCall(node *nodes.Call) *Value {
 		// Return the function call value
 		current = rv.Interface().(*Value).Val
 		isSafe = rv.Interface().(*Value).Safe
+		escaper = rv.Interface().(*Value).Escaper
 	}
 
 	if !current.IsValid() {
 		// Value is not valid (e. g. NIL value)
 		return AsValue(nil)
 	}
-	value := &Value{Val: current, Safe: isSafe}
+	value := &Value{Val: current, Safe: isSafe, Escaper: escaper}
 	if value.IsError() {
+		if (t == reflect.TypeFor[Macro]() || t == reflect.TypeFor[Caller]()) && e.renderer != nil {
+			macroName := functionName
+			if getAttributeNode, ok := node.Func.(*nodes.GetAttribute); ok {
+				macroName = getAttributeNode.Attribute
+			}
+			value = AsValue(e.renderer.WithFrame(value.Interface().(error), "macro", macroName, node.Location))
+		}
 		if err, ok := value.Interface().(ErrInvalidCall); ok {
-			return AsValue(fmt.Errorf("invalid call to function '%s': %s", functionName, err.Error()))
+			return AsValue(fmt.Errorf("invalid call to function '%s': %w", functionName, err))
 		}
 	}
 	return value
@@ -105,6 +136,9 @@ func (e *Evaluator) evalMethod(parentNode nodes.Node, method string, args []node
 		}
 		parameters.KwArgs[key] = value
 	}
+	if policy := e.policy(); policy != nil && !policy.IsSafeAttribute(parent, method) {
+		return AsValue(e.execution.denied(method))
+	}
 	var result any
 	err := fmt.Errorf("unknown method '%s' for '%s'", method, parent.String())
 	switch {
@@ -153,7 +187,7 @@ func (e *Evaluator) evalMethod(parentNode nodes.Node, method string, args []node
 	}
 	if err != nil {
 		if callErr, ok := err.(ErrInvalidCall); ok {
-			return AsValue(fmt.Errorf("invalid call to method '%s' of %s: %s", method, parent.String(), callErr.Error()))
+			return AsValue(fmt.Errorf("invalid call to method '%s' of %s: %w", method, parent.String(), callErr))
 		}
 		return AsValue(err)
 	}
@@ -6,6 +6,8 @@ import (
 	"reflect"
 	"sync"
 
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/loaders"
 	"github.com/nikolalohinski/gonja/v2/parser"
 	"github.com/pkg/errors"
 )
@@ -14,8 +16,18 @@ type Environment struct {
 	Filters           *FilterSet
 	ControlStructures *ControlStructureSet
 	Tests             *TestSet
+	Escapers          *EscaperSet
 	Context           *Context
 	Methods           Methods
+	Sandbox           *Sandbox
+	// Translator translates the messages of trans blocks and of the gettext functions, if set
+	Translator Translator
+
+	// Loader and Config are used by GetTemplate to load templates by name
+	Loader loaders.Loader
+	Config *config.Config
+	// Cache keeps parsed templates, including included, extended and imported ones, when set
+	Cache *TemplateCache
 }
 
 type FilterSet struct {
@@ -0,0 +1,130 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// TemplateError is an error located in a template, returned when parsing or rendering a template fails.
+// It carries the identifier of the template, the line, column and source line of the error, and the includes,
+// extends, imports and macro calls leading to it. It is usually wrapped with some context, so it must be
+// retrieved with errors.As:
+//
+//	var templateError *exec.TemplateError
+//	if errors.As(err, &templateError) {
+//		fmt.Println(templateError.Details())
+//	}
+type TemplateError = parser.TemplateError
+
+// Frame is a step of the stack leading to a TemplateError
+type Frame = parser.Frame
+
+// TemplateNotFoundError is returned when none of the templates selected from a list can be loaded,
+// holding the error met for each of them
+type TemplateNotFoundError struct {
+	Names []string
+	Errs  []error
+}
+
+func (e *TemplateNotFoundError) Error() string {
+	if len(e.Names) == 0 {
+		return "no template to select from an empty list"
+	}
+	reasons := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		reasons = append(reasons, err.Error())
+	}
+	return fmt.Sprintf("none of the templates '%s' could be loaded: %s", strings.Join(e.Names, "', '"), strings.Join(reasons, "; "))
+}
+
+func (e *TemplateNotFoundError) Unwrap() []error {
+	return e.Errs
+}
+
+// ErrLoopBreak is returned when executing a 'break' control structure and
+// is caught by the closest enclosing loop to stop iterating
+var ErrLoopBreak = errors.New("'break' used outside of a loop")
+
+// ErrLoopContinue is returned when executing a 'continue' control structure and
+// is caught by the closest enclosing loop to skip the rest of the current iteration
+var ErrLoopContinue = errors.New("'continue' used outside of a loop")
+
+// stopLoopControls returns a plain error in place of a 'break' or a 'continue' escaping a macro or a template,
+// so that it fails the rendering instead of controlling a loop around the macro call or the include
+func stopLoopControls(err error) error {
+	if errors.Is(err, ErrLoopBreak) || errors.Is(err, ErrLoopContinue) {
+		return errors.New(err.Error())
+	}
+	return err
+}
+
+// withSource sets the source line of the template error held by err, if any and not known yet. It is
+// only looked up once rendering failed, since errors like the ones of loop controls are caught on the way.
+func (t *Template) withSource(err error) error {
+	var templateError *TemplateError
+	if !errors.As(err, &templateError) || templateError.Source != "" || templateError.Line == 0 {
+		return err
+	}
+	templateError.Source = parser.SourceLine(t.sourceOf(templateError.Template), templateError.Line)
+	return err
+}
+
+// sourceOf returns the source of the template or of one of the templates it extends, if known
+func (t *Template) sourceOf(identifier string) string {
+	for _, template := range append([]*Template{t}, t.extended...) {
+		if template.root != nil && template.root.Identifier == identifier && template.tokens != nil {
+			return template.tokens.Source
+		}
+	}
+	if cache := t.environment.Cache; cache != nil {
+		if template, ok := cache.Get(identifier); ok && template.scope == t.scope && template.tokens != nil {
+			return template.tokens.Source
+		}
+	}
+	// Otherwise templates extended, included or imported are read again from the loader as a best effort
+	if t.loader == nil {
+		return ""
+	}
+	input, err := t.loader.Read(identifier)
+	if err != nil {
+		return ""
+	}
+	source, err := io.ReadAll(input)
+	if err != nil {
+		return ""
+	}
+	return strings.ReplaceAll(string(source), "\r\n", "\n")
+}
+
+// Locate returns the error located at the given position of the template being rendered,
+// unless it is already located in a template
+func (r *Renderer) Locate(err error, position *tokens.Token) error {
+	return parser.NewTemplateError(r.identifier, "", position, err)
+}
+
+// WithFrame records that the error occurred within the template or macro with the given name, included,
+// extended, imported or called at the given position of the template being rendered
+func (r *Renderer) WithFrame(err error, kind, name string, position *tokens.Token) error {
+	frame := Frame{Kind: kind, Name: name, Template: r.identifier}
+	if position != nil {
+		frame.Line = position.Line
+		frame.Column = position.Col
+	}
+	return parser.WithFrame(err, frame)
+}
+
+// extendsPosition returns the position of the extends tag of the template, if any
+func extendsPosition(template *nodes.Template) *tokens.Token {
+	for _, node := range template.Nodes {
+		if block, ok := node.(*nodes.ControlStructureBlock); ok && block.Name == "extends" {
+			return block.Location
+		}
+	}
+	return nil
+}
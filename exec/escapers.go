@@ -0,0 +1,120 @@
+package exec
+
+import (
+	"maps"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	u "github.com/nikolalohinski/gonja/v2/utils"
+)
+
+// DefaultEscaper is the name of the escaping strategy used when the configuration does not select any
+const DefaultEscaper = "html"
+
+// Escaper escapes a string so that it can be safely written into a given kind of document, like HTML or a shell script
+type Escaper func(in string) string
+
+// EscaperSet maps the names of escaping strategies to their Escaper
+type EscaperSet struct {
+	escapers map[string]Escaper
+	lock     sync.Mutex
+}
+
+func NewEscaperSet(escapers map[string]Escaper) *EscaperSet {
+	return &EscaperSet{
+		escapers: escapers,
+	}
+}
+
+// Exists returns true if the given escaper is already registered
+func (s *EscaperSet) Exists(name string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	_, existing := s.escapers[name]
+	return existing
+}
+
+// Get returns true and the named escaper if it is already registered
+func (s *EscaperSet) Get(name string) (Escaper, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	escaper, ok := s.escapers[name]
+	return escaper, ok
+}
+
+// Register registers a new escaper. If there's already an escaper with the same name, Register will error out.
+func (s *EscaperSet) Register(name string, fn Escaper) error {
+	if s.Exists(name) {
+		return errors.Errorf("escaper with name '%s' is already registered", name)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.escapers[name] = fn
+	return nil
+}
+
+// Replace replaces an already registered escaper with a new implementation. Use this
+// function with caution since it allows you to change existing escaper behaviour.
+func (s *EscaperSet) Replace(name string, fn Escaper) error {
+	if !s.Exists(name) {
+		return errors.Errorf("escaper with name '%s' does not exist (therefore cannot be overridden)", name)
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.escapers[name] = fn
+	return nil
+}
+
+func (s *EscaperSet) Update(other *EscaperSet) *EscaperSet {
+	if other == nil {
+		return s
+	}
+	s.lock.Lock()
+	other.lock.Lock()
+	defer s.lock.Unlock()
+	defer other.lock.Unlock()
+	maps.Copy(s.escapers, other.escapers)
+	return s
+}
+
+// escaper returns the escaper registered with the given name in the environment,
+// falling back to HTML escaping for environments without any escaper set
+func (e *Environment) escaper(name string) (Escaper, error) {
+	if e.Escapers != nil {
+		if escaper, ok := e.Escapers.Get(name); ok {
+			return escaper, nil
+		}
+	}
+	if name == DefaultEscaper {
+		return u.Escape, nil
+	}
+	return nil, errors.Errorf("escaper '%s' not found", name)
+}
+
+// EscaperName returns the name of the escaping strategy in use, selected
+// by the configuration of the template or an autoescape control structure
+func (e *Evaluator) EscaperName() string {
+	if e.Config == nil || e.Config.Escaper == "" {
+		return DefaultEscaper
+	}
+	return e.Config.Escaper
+}
+
+// Escape escapes the value with the escaping strategy in use, unless it is already safe for it
+func (e *Evaluator) Escape(value *Value) *Value {
+	return e.EscapeWith(value, e.EscaperName())
+}
+
+// EscapeWith escapes the value with the named escaping strategy, unless it is already safe for it.
+// The returned value is only marked as safe for that strategy.
+func (e *Evaluator) EscapeWith(value *Value, name string) *Value {
+	if value.IsSafeFor(name) {
+		return value
+	}
+	escaper, err := e.Environment.escaper(name)
+	if err != nil {
+		return AsValue(err)
+	}
+	return AsEscapedValue(escaper(value.String()), name)
+}
@@ -1,6 +1,7 @@
 package exec
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"reflect"
@@ -24,6 +25,21 @@ type Evaluator struct {
 	Config      *config.Config
 	Environment *Environment
 	Loader      loaders.Loader
+
+	// renderer the evaluator was created by, if any, used to locate errors
+	renderer  *Renderer
+	execution *execution
+}
+
+// Context returns the Go context of the current execution
+func (e *Evaluator) Context() context.Context {
+	return e.execution.context()
+}
+
+// Cancelled returns a *CancelledError if the context of the current execution
+// has been cancelled or its deadline exceeded, and nil otherwise
+func (e *Evaluator) Cancelled() error {
+	return e.execution.cancelled()
 }
 
 func (e *Evaluator) Eval(node nodes.Expression) *Value {
@@ -122,7 +138,7 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 		}
 
 		if left.IsString() || right.IsString() {
-			return AsValue(left.String() + right.String())
+			return e.concatenate(left.String(), right.String())
 		}
 
 		// Result will be an integer
@@ -135,21 +151,25 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 		// Result will be an integer
 		return AsValue(left.Integer() - right.Integer())
 	case tokens.Multiply:
-		if left.IsFloat() || right.IsFloat() {
-			// Result will be float
-			return AsValue(left.Float() * right.Float())
-		}
-		if left.IsString() {
+		// Strings are repeated by integral floats too, like the ones returned by powers as in 'x' * 10**3
+		if left.IsString() && (!right.IsFloat() || right.Float() == math.Trunc(right.Float())) {
 			count := right.Integer()
 			if count < 0 {
 				count = 0
 			}
 			resultLen := int64(len(left.String())) * int64(count)
+			if err := e.checkStringSize(resultLen); err != nil {
+				return AsValue(err)
+			}
 			if resultLen > maxStringRepeatBytes {
 				return AsValue(fmt.Errorf("string repeat would produce %d bytes, exceeding limit of %d", resultLen, maxStringRepeatBytes))
 			}
 			return AsValue(strings.Repeat(left.String(), count))
 		}
+		if left.IsFloat() || right.IsFloat() {
+			// Result will be float
+			return AsValue(left.Float() * right.Float())
+		}
 		// Result will be int
 		return AsValue(left.Integer() * right.Integer())
 	case tokens.Division:
@@ -164,7 +184,7 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 	case tokens.Power:
 		return AsValue(math.Pow(left.Float(), right.Float()))
 	case tokens.Tilde:
-		return AsValue(strings.Join([]string{left.String(), right.String()}, ""))
+		return e.concatenate(left.String(), right.String())
 	case tokens.And:
 		// Python/Jinja2 semantics: `x and y` returns x if x is falsy,
 		// otherwise y. The result is the operand value, not a coerced bool,
@@ -235,6 +255,14 @@ func (e *Evaluator) evalBinaryExpression(node *nodes.BinaryExpression) *Value {
 	}
 }
 
+// concatenate joins two strings, unless the result goes over the maximum output size of the sandbox
+func (e *Evaluator) concatenate(left, right string) *Value {
+	if err := e.checkStringSize(int64(len(left)) + int64(len(right))); err != nil {
+		return AsValue(err)
+	}
+	return AsValue(left + right)
+}
+
 func (e *Evaluator) evalUnaryExpression(expr *nodes.UnaryExpression) *Value {
 	result := e.Eval(expr.Term)
 	if result.IsError() {
@@ -335,7 +363,7 @@ func (e *Evaluator) evalGetItem(node *nodes.GetItem) *Value {
 
 	item, found := value.GetItem(key)
 	if !found && argument.IsString() {
-		item, found = value.GetAttribute(argument.String())
+		item, found = e.GetAttribute(value, argument.String())
 	}
 	if !found {
 		if item.IsError() {
@@ -396,8 +424,8 @@ func (e *Evaluator) evalGetAttribute(node *nodes.GetAttribute) *Value {
 	}
 
 	if node.Attribute != "" {
-		attr, found := value.GetAttribute(node.Attribute)
-		if !found {
+		attr, found := e.GetAttribute(value, node.Attribute)
+		if !found && !isDenied(attr) {
 			attr, found = value.GetItem(node.Attribute)
 		}
 		if !found {
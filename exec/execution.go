@@ -0,0 +1,65 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+)
+
+// CancelledError is returned when the execution of a template is stopped
+// because its context was cancelled or its deadline exceeded
+type CancelledError struct {
+	// Template is the identifier of the template being executed
+	Template string
+	// Cause is the error returned by the context, either context.Canceled or context.DeadlineExceeded
+	Cause error
+}
+
+func (e *CancelledError) Error() string {
+	return fmt.Sprintf("execution of template '%s' was stopped: %s", e.Template, e.Cause)
+}
+
+func (e *CancelledError) Unwrap() error {
+	return e.Cause
+}
+
+// execution holds the state shared by all the renderers and evaluators involved
+// in a single template execution, including the ones of included templates
+type execution struct {
+	ctx      context.Context
+	template string
+	sandbox  *Sandbox
+
+	depth      int
+	iterations int
+}
+
+func newExecution(ctx context.Context, template string, sandbox *Sandbox) *execution {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &execution{
+		ctx:      ctx,
+		template: template,
+		sandbox:  sandbox,
+	}
+}
+
+func (x *execution) context() context.Context {
+	if x == nil {
+		return context.Background()
+	}
+	return x.ctx
+}
+
+func (x *execution) cancelled() error {
+	if x == nil {
+		return nil
+	}
+	if err := x.ctx.Err(); err != nil {
+		return &CancelledError{
+			Template: x.template,
+			Cause:    err,
+		}
+	}
+	return nil
+}
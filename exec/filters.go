@@ -49,6 +49,9 @@ func (e *Evaluator) ExecuteFilter(fc *nodes.FilterCall, v *Value) *Value {
 
 // ExecuteFilterByName executes a filter given its name
 func (e *Evaluator) ExecuteFilterByName(name string, in *Value, params *VarArgs) *Value {
+	if err := e.Cancelled(); err != nil {
+		return AsValue(err)
+	}
 	filter, ok := e.Environment.Filters.Get(name)
 	if !e.Environment.Filters.Exists(name) || !ok {
 		return AsValue(errors.Errorf("filter '%s' not found", name))
@@ -57,7 +60,7 @@ func (e *Evaluator) ExecuteFilterByName(name string, in *Value, params *VarArgs)
 	if returnedValue.IsError() {
 		err, ok := returnedValue.Interface().(ErrInvalidCall)
 		if ok {
-			return AsValue(fmt.Errorf("invalid call to filter '%s': %s", name, err.Error()))
+			return AsValue(fmt.Errorf("invalid call to filter '%s': %w", name, err))
 		}
 	}
 
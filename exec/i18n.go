@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Translator translates the messages of templates, like a gettext catalog loaded from .po or .mo files for
+// a given locale. Messages hold named placeholders like 'Hello %(name)s!', replaced once translated.
+type Translator interface {
+	// Gettext returns the translation of the message
+	Gettext(message string) string
+	// NGettext returns the translation of the singular or the plural form of the message, depending on n
+	NGettext(singular, plural string, n int) string
+}
+
+// NullTranslator returns messages untranslated, using the plural form of messages unless n is 1.
+// It is used when the environment has no translator.
+type NullTranslator struct{}
+
+func (NullTranslator) Gettext(message string) string {
+	return message
+}
+
+func (NullTranslator) NGettext(singular, plural string, n int) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
+
+func (e *Evaluator) translator() Translator {
+	if e.Environment == nil || e.Environment.Translator == nil {
+		return NullTranslator{}
+	}
+	return e.Environment.Translator
+}
+
+// Gettext translates the message with the translator of the environment and replaces its placeholders with the
+// given variables, see FormatMessage. When variables is nil, the translated message is returned as is.
+func (e *Evaluator) Gettext(message string, variables map[string]*Value) *Value {
+	return e.FormatMessage(e.translator().Gettext(message), variables)
+}
+
+// NGettext translates the singular or plural form of the message depending on n like Gettext.
+// The 'num' variable is set to n unless variables already define it.
+func (e *Evaluator) NGettext(singular, plural string, n int, variables map[string]*Value) *Value {
+	if variables == nil {
+		variables = map[string]*Value{}
+	}
+	if _, ok := variables["num"]; !ok {
+		variables["num"] = AsValue(n)
+	}
+	return e.FormatMessage(e.translator().NGettext(singular, plural, n), variables)
+}
+
+// FormatMessage replaces the %(name)s placeholders of a translated message with the given variables, and
+// '%%' with '%'. Variables are escaped when autoescaping is enabled, the message itself being trusted.
+// When variables is nil, the message is returned as is.
+func (e *Evaluator) FormatMessage(message string, variables map[string]*Value) *Value {
+	if variables == nil {
+		return e.trusted(message)
+	}
+	original := message
+	var out strings.Builder
+	for {
+		index := strings.IndexByte(message, '%')
+		if index < 0 || index == len(message)-1 {
+			out.WriteString(message)
+			break
+		}
+		out.WriteString(message[:index])
+		message = message[index+1:]
+		if message[0] == '%' {
+			out.WriteByte('%')
+			message = message[1:]
+			continue
+		}
+		end := strings.IndexByte(message, ')')
+		if message[0] != '(' || end < 0 || end == len(message)-1 || !strings.ContainsRune("sd", rune(message[end+1])) {
+			return AsValue(fmt.Errorf("invalid placeholder in message '%s', expected %%(name)s or %%%%", original))
+		}
+		name := message[1:end]
+		value, ok := variables[name]
+		if !ok {
+			return AsValue(fmt.Errorf("message has no variable named '%s'", name))
+		}
+		if e.Config != nil && e.Config.AutoEscape {
+			value = e.Escape(value)
+			if value.IsError() {
+				return value
+			}
+		}
+		out.WriteString(value.String())
+		message = message[end+2:]
+	}
+	return e.trusted(out.String())
+}
+
+// trusted returns the string as a value marked as safe when autoescaping is enabled
+func (e *Evaluator) trusted(s string) *Value {
+	if e.Config != nil && e.Config.AutoEscape {
+		return AsEscapedValue(s, e.EscaperName())
+	}
+	return AsValue(s)
+}
@@ -8,9 +8,17 @@ import (
 	"github.com/pkg/errors"
 )
 
+// CallerKeyword is the name of the keyword argument used by call blocks to pass
+// their body to the macro being called, available as 'caller()' within the macro
+const CallerKeyword = "caller"
+
 // Macro is the type macro functions must fulfill
 type Macro func(params *VarArgs) *Value
 
+// Caller is the type of the macro passed by call blocks as the 'caller' keyword argument,
+// telling it apart from values given explicitly to macros which do not declare it
+type Caller Macro
+
 type MacroSet map[string]Macro
 
 // Exists returns true if the given filter is already registered
@@ -43,9 +51,14 @@ func (ms *MacroSet) Replace(name string, fn Macro) error {
 
 func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (Macro, error) {
 	return func(params *VarArgs) *Value {
+		if err := r.execution.enter(); err != nil {
+			return AsValue(err)
+		}
+		defer r.execution.leave()
+
 		var out strings.Builder
 		sub := r.Inherit()
-		sub.Output = &out
+		sub.Buffer(&out)
 
 		macroArguments := make([]*Pair, len(node.Kwargs))
 		for i, positionalArgument := range params.Args {
@@ -79,6 +92,11 @@ func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (Macro, error) {
 					continue kwargs
 				}
 			}
+			if _, isCaller := argument.Interface().(Caller); keyword == CallerKeyword && isCaller {
+				// The caller is passed implicitly by call blocks, unless explicitly declared as an argument
+				sub.Environment.Context.Set(CallerKeyword, argument)
+				continue kwargs
+			}
 			return AsValue(fmt.Errorf("macro '%s' takes no keyword argument '%s'", node.Name, keyword))
 		}
 		for i, defaultArgument := range node.Kwargs {
@@ -100,10 +118,10 @@ func MacroNodeToFunc(node *nodes.Macro, r *Renderer) (Macro, error) {
 		for _, arg := range macroArguments {
 			sub.Environment.Context.Set(arg.Key.String(), arg.Value)
 		}
-		err := sub.ExecuteWrapper(node.Wrapper)
+		err := stopLoopControls(sub.ExecuteWrapper(node.Wrapper))
 		if err != nil {
 			return AsValue(errors.Wrapf(err, `Unable to execute macro '%s'`, node.Name))
 		}
-		return AsSafeValue(out.String())
+		return sub.RenderedValue(out.String())
 	}, nil
 }
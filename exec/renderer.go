@@ -1,16 +1,21 @@
 package exec
 
 import (
+	"context"
 	"io"
-	"strings"
+	"slices"
 
 	"github.com/pkg/errors"
 
 	"github.com/nikolalohinski/gonja/v2/config"
 	"github.com/nikolalohinski/gonja/v2/loaders"
 	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/tokens"
 )
 
+// errExtended stops the rendering of a template once the template it extends at render time is rendered
+var errExtended = errors.New("template extended")
+
 // Renderer is a node visitor in charge of rendering
 type Renderer struct {
 	Config      *config.Config
@@ -19,22 +24,80 @@ type Renderer struct {
 	Template    *Template
 	RootNode    *nodes.Template
 	Output      io.Writer
+
+	// identifier of the template whose nodes are rendered, used to locate errors
+	identifier string
+	execution  *execution
+	// base is the configuration autoescaping is selected from for the templates loaded at render time,
+	// so that they do not inherit the one selected for the current template or set by autoescape tags
+	base *config.Config
+	// block is the name of the block rendered on its own by ExecuteBlock, if any
+	block string
 }
 
 // NewRenderer initializes a new renderer
 func NewRenderer(environment *Environment, wr io.Writer, config *config.Config, loader loaders.Loader, template *Template) *Renderer {
 	r := &Renderer{
-		Config:      config.Inherit(),
+		Config:      config.ForTemplate(template.root.Identifier),
 		Environment: environment,
 		Template:    template,
 		RootNode:    template.root,
 		Output:      wr,
 		Loader:      loader,
+		identifier:  template.root.Identifier,
+		execution:   newExecution(context.Background(), template.root.Identifier, environment.Sandbox),
+		base:        config,
 	}
-	r.Environment.Context.Set("self", Self(r))
+	r.Environment.Context.Set("self", SelfValues(r))
 	return r
 }
 
+// Spawn creates a new renderer for another template, like an included one, sharing the environment,
+// output and execution state of the current renderer. Autoescaping is selected for the template from
+// the base configuration, not inherited from the current template
+func (r *Renderer) Spawn(template *Template, loader loaders.Loader) *Renderer {
+	sub := NewRenderer(r.Environment, r.Output, r.base, loader, template)
+	sub.execution = r.execution
+	return sub
+}
+
+// Isolate creates a new sub renderer whose context only holds the global variables of the environment of
+// the rendered template, for templates which are not given the context of the current one, like the ones
+// imported or included without context
+func (r *Renderer) Isolate() *Renderer {
+	sub := r.Inherit()
+	sub.Environment.Context = r.Template.environment.Context.Inherit()
+	return sub
+}
+
+// ForTemplate returns a copy of the renderer rendering the nodes of another template, like an
+// extended or imported one, with autoescaping selected for it as described by config.Config.ForTemplate
+func (r *Renderer) ForTemplate(identifier string) *Renderer {
+	sub := *r
+	sub.Config = r.Config.ForTemplate(identifier)
+	sub.identifier = identifier
+	return &sub
+}
+
+// ForLoaded returns a copy of the renderer rendering the nodes of a template loaded at render time, like an
+// imported one, with autoescaping selected for it from the base configuration like for Spawn
+func (r *Renderer) ForLoaded(identifier string) *Renderer {
+	sub := r.ForTemplate(identifier)
+	sub.Config = r.base.ForTemplate(identifier)
+	return sub
+}
+
+// Context returns the Go context of the current execution
+func (r *Renderer) Context() context.Context {
+	return r.execution.context()
+}
+
+// Cancelled returns a *CancelledError if the context of the current execution
+// has been cancelled or its deadline exceeded, and nil otherwise
+func (r *Renderer) Cancelled() error {
+	return r.execution.cancelled()
+}
+
 // Inherit creates a new sub renderer
 func (r *Renderer) Inherit() *Renderer {
 	sub := &Renderer{
@@ -45,45 +108,42 @@ func (r *Renderer) Inherit() *Renderer {
 			Filters:           r.Environment.Filters,
 			ControlStructures: r.Environment.ControlStructures,
 			Methods:           r.Environment.Methods,
+			Escapers:          r.Environment.Escapers,
+			Sandbox:           r.Environment.Sandbox,
+			Translator:        r.Environment.Translator,
+			Loader:            r.Environment.Loader,
+			Config:            r.Environment.Config,
+			Cache:             r.Environment.Cache,
 		},
-		Template: r.Template,
-		RootNode: r.RootNode,
-		Output:   r.Output,
-		Loader:   r.Loader,
+		Template:   r.Template,
+		RootNode:   r.RootNode,
+		Output:     r.Output,
+		Loader:     r.Loader,
+		identifier: r.identifier,
+		execution:  r.execution,
+		base:       r.base,
+		block:      r.block,
 	}
 	return sub
 }
 
 // Visit implements the nodes.Visitor interface
 func (r *Renderer) Visit(node nodes.Node) (nodes.Visitor, error) {
+	if err := r.Cancelled(); err != nil {
+		return nil, err
+	}
 	switch n := node.(type) {
 	case *nodes.Comment:
 		return nil, nil
 	case *nodes.Data:
-		output := n.Data.Val
-		if n.RemoveFirstLineReturn {
-			output = strings.TrimPrefix(output, "\r\n")
-			output = strings.TrimPrefix(output, "\n")
-		}
-		if n.Trim.Left {
-			output = strings.TrimLeft(output, " \r\n\t")
-		}
-		if n.Trim.Right {
-			output = strings.TrimRight(output, " \r\n\t")
-		}
-		if n.RemoveTrailingWhiteSpaceFromLastLine {
-			lines := strings.Split(output, "\n")
-			lines = append(lines[0:len(lines)-1], strings.TrimRight(lines[len(lines)-1], " \n\t\r"))
-			output = strings.Join(lines, "\n")
-		}
-		_, err := io.WriteString(r.Output, output)
+		_, err := io.WriteString(r.Output, n.Text())
 		return nil, err
 	case *nodes.Output:
 		var value *Value
 		if n.Condition != nil {
 			condition := r.Eval(n.Condition)
 			if condition.IsError() {
-				return nil, errors.Wrapf(condition, `Unable to render condition at line %d: %s`, n.Condition.Position().Line, n.Condition)
+				return nil, r.Locate(errors.Wrapf(condition, `Unable to render condition at line %d: %s`, n.Condition.Position().Line, n.Condition), n.Condition.Position())
 			}
 			if !condition.IsNil() && condition.IsTrue() {
 				value = r.Eval(n.Expression)
@@ -94,17 +154,21 @@ func (r *Renderer) Visit(node nodes.Node) (nodes.Visitor, error) {
 					return nil, nil
 				}
 			} else {
-				return nil, errors.Wrapf(condition, `Unable to evaluation condition as boolean at line %d: %s`, n.Condition.Position().Line, n.Condition)
+				return nil, r.Locate(errors.Wrapf(condition, `Unable to evaluation condition as boolean at line %d: %s`, n.Condition.Position().Line, n.Condition), n.Condition.Position())
 			}
 		} else {
 			value = r.Eval(n.Expression)
 		}
 		if value.IsError() {
-			return nil, errors.Wrapf(value, `Unable to render expression at line %d: %s`, n.Expression.Position().Line, n.Expression)
+			return nil, r.Locate(errors.Wrapf(value, `Unable to render expression at line %d: %s`, n.Expression.Position().Line, n.Expression), n.Expression.Position())
 		}
 		var err error
-		if r.Config.AutoEscape && value.IsString() && !value.Safe {
-			_, err = io.WriteString(r.Output, value.Escaped())
+		if r.Config.AutoEscape && value.IsString() {
+			escaped := r.Evaluator().Escape(value)
+			if escaped.IsError() {
+				return nil, r.Locate(errors.Wrapf(escaped, `Unable to escape expression at line %d: %s`, n.Expression.Position().Line, n.Expression), n.Expression.Position())
+			}
+			_, err = io.WriteString(r.Output, escaped.String())
 		} else {
 			_, err = io.WriteString(r.Output, value.String())
 		}
@@ -113,7 +177,7 @@ func (r *Renderer) Visit(node nodes.Node) (nodes.Visitor, error) {
 		controlStructure, ok := n.ControlStructure.(ControlStructure)
 		if ok {
 			if err := controlStructure.Execute(r, n); err != nil {
-				return nil, errors.Wrapf(err, `Unable to execute controlStructure at line %d: %s`, n.ControlStructure.Position().Line, n.ControlStructure)
+				return nil, r.Locate(errors.Wrapf(err, `Unable to execute controlStructure at line %d: %s`, n.ControlStructure.Position().Line, n.ControlStructure), n.ControlStructure.Position())
 			}
 		}
 		return nil, nil
@@ -138,13 +202,176 @@ func (r *Renderer) ExecuteIfWrapper(wrapper *nodes.Wrapper) error {
 }
 
 func (r *Renderer) Execute() error {
+	if err := r.execution.enter(); err != nil {
+		return err
+	}
+	defer r.execution.leave()
+
 	// Determine the parent to be executed (for template inheritance)
 	root := r.RootNode
 	for root.Parent != nil {
 		root = root.Parent
 	}
+	if root != r.RootNode {
+		// The definitions of the extending templates are evaluated first, so that the blocks they override can use them
+		for template := r.RootNode; template != root; template = template.Parent {
+			if err := r.ForTemplate(template.Identifier).define(template.Nodes); err != nil {
+				return err
+			}
+		}
+		err := r.ForTemplate(root.Identifier).walk(root)
+		if err != nil {
+			// Record the extends tags from the topmost parent down to the rendered template
+			var extending []*nodes.Template
+			for template := r.RootNode; template.Parent != nil; template = template.Parent {
+				extending = append(extending, template)
+			}
+			for i := len(extending) - 1; i >= 0; i-- {
+				err = r.ForTemplate(extending[i].Identifier).WithFrame(err, "extends", extending[i].Parent.Identifier, extendsPosition(extending[i]))
+			}
+		}
+		return err
+	}
+
+	return r.walk(root)
+}
+
+// definitions are the control structures evaluated at the top level of the templates before rendering their
+// parent or one of their blocks on its own, since they define the variables the blocks may use
+var definitions = []string{"macro", "import", "from", "set"}
 
-	return nodes.Walk(r, root)
+// ExecuteBlock renders the named block as overridden by the rendered template. The macros, imports and
+// assignments at the top level of the templates are evaluated beforehand, from the rendered template up to
+// its topmost parent, without writing their output. The extends tags selecting a parent at render time are
+// evaluated along with them, so that the block is looked up in and overrides the blocks of these parents too.
+func (r *Renderer) ExecuteBlock(name string) error {
+	if err := r.execution.enter(); err != nil {
+		return err
+	}
+	defer r.execution.leave()
+
+	sub := *r
+	sub.block = name
+	return sub.executeBlock(r.RootNode)
+}
+
+// executeBlock evaluates the definitions of the given template and of its parents, then renders the block
+func (r *Renderer) executeBlock(from *nodes.Template) error {
+	for template := from; template != nil; template = template.Parent {
+		if err := r.ForTemplate(template.Identifier).define(template.Nodes); err != nil {
+			if errors.Is(err, errExtended) {
+				// The block was rendered along with the parent selected at render time
+				return nil
+			}
+			return err
+		}
+	}
+
+	template, tag := findBlock(r.RootNode, r.block)
+	if tag == nil {
+		return errors.Errorf("no such block in template '%s'", r.RootNode.Identifier)
+	}
+	return nodes.Walk(r.ForTemplate(template.Identifier), tag)
+}
+
+// findBlock returns the tag of the named block, in the closest template defining it along with that template
+func findBlock(root *nodes.Template, name string) (*nodes.Template, *nodes.ControlStructureBlock) {
+	for template := root; template != nil; template = template.Parent {
+		if _, ok := template.Blocks[name]; !ok {
+			continue
+		}
+		var tag *nodes.ControlStructureBlock
+		nodes.Inspect(template, func(node nodes.Node) bool {
+			if block, ok := node.(*nodes.ControlStructureBlock); ok && block.Name == "block" {
+				if named, ok := block.ControlStructure.(interface{ Name() string }); ok && named.Name() == name {
+					tag = block
+				}
+			}
+			return tag == nil
+		})
+		if tag != nil {
+			return template, tag
+		}
+	}
+	return nil, nil
+}
+
+// define evaluates the definitions among the given top level nodes of a template, without writing their output.
+// When executing a block, the extends tags selecting a parent at render time are evaluated too.
+func (r *Renderer) define(list []nodes.Node) error {
+	discarded := *r
+	discarded.Output = io.Discard
+	for _, node := range list {
+		block, ok := node.(*nodes.ControlStructureBlock)
+		switch {
+		case !ok:
+		case slices.Contains(definitions, block.Name):
+			if err := nodes.Walk(&discarded, block); err != nil {
+				return err
+			}
+		case block.Name == "extends" && r.block != "":
+			if err := nodes.Walk(r, block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// walk renders the nodes of a template, up to the extends tag selecting its parent at render time if any
+func (r *Renderer) walk(root *nodes.Template) error {
+	if err := nodes.Walk(r, root); err != nil && !errors.Is(err, errExtended) {
+		return stopLoopControls(err)
+	}
+	return nil
+}
+
+// Extend renders the given template in place of the one being rendered, whose blocks override the ones
+// of the extended template, as done by an extends tag at the given position selecting its parent at render time.
+// The definitions following the tag at the top level of the current template are evaluated beforehand.
+// Once it returns, the rendering of the current template must stop, which is done by returning its error as is.
+func (r *Renderer) Extend(parent *Template, position *tokens.Token) error {
+	// The templates extending the one being rendered are copied to be linked to the parent of this rendering only
+	var chain []*nodes.Template
+	for template := r.RootNode; template != nil; template = template.Parent {
+		copied := *template
+		if len(chain) > 0 {
+			chain[len(chain)-1].Parent = &copied
+		}
+		chain = append(chain, &copied)
+		if template.Identifier == r.identifier {
+			break
+		}
+	}
+	current := chain[len(chain)-1]
+	current.Parent = parent.root
+
+	for i, node := range current.Nodes {
+		if tag, ok := node.(*nodes.ControlStructureBlock); ok && tag.Location == position {
+			if err := r.define(current.Nodes[i+1:]); err != nil {
+				return err
+			}
+			break
+		}
+	}
+
+	sub := r.Inherit()
+	sub.RootNode = chain[0]
+	sub.Environment.Context.Set("self", SelfValues(sub))
+	if r.block != "" {
+		if err := sub.executeBlock(parent.root); err != nil {
+			return r.WithFrame(err, "extends", parent.root.Identifier, position)
+		}
+		return errExtended
+	}
+	root := parent.root
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	if err := sub.ForTemplate(root.Identifier).walk(root); err != nil {
+		return r.WithFrame(err, "extends", parent.root.Identifier, position)
+	}
+	return errExtended
 }
 
 func (r *Renderer) Evaluator() *Evaluator {
@@ -152,9 +379,17 @@ func (r *Renderer) Evaluator() *Evaluator {
 		Environment: r.Environment,
 		Config:      r.Config,
 		Loader:      r.Template.parser.Loader,
+		renderer:    r,
+		execution:   r.execution,
 	}
 }
 
+// RenderedValue returns content rendered in memory by the renderer, like the output of a macro, as a value
+// which is safe for the escaping strategy of the renderer only
+func (r *Renderer) RenderedValue(content string) *Value {
+	return AsEscapedValue(content, r.Evaluator().EscaperName())
+}
+
 func (r *Renderer) Eval(node nodes.Expression) *Value {
 	e := r.Evaluator()
 	return e.Eval(node)
@@ -0,0 +1,345 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Sandbox restricts the resources a template can consume while being executed, which
+// is required when rendering untrusted templates. A zero value for any of the limits
+// means that this limit is not enforced.
+type Sandbox struct {
+	// MaxOutputSize is the maximum number of bytes a template execution can write to its output. It also
+	// limits the content rendered in memory, like the output of macros, and the strings built by expressions
+	MaxOutputSize int
+	// MaxLoopIterations is the maximum number of loop iterations over a whole template execution
+	MaxLoopIterations int
+	// MaxRecursionDepth is the maximum nesting depth of templates and macro calls,
+	// the executed template itself being the first level
+	MaxRecursionDepth int
+	// MaxRangeLength is the maximum number of items a call to range() can produce
+	MaxRangeLength int
+	// Policy decides which attributes and callables of Go values are reachable from templates
+	Policy SecurityPolicy
+}
+
+// SecurityPolicy is asked by the evaluator before every attribute read and call,
+// like the is_safe_attribute and is_safe_callable methods of Jinja's SandboxedEnvironment
+type SecurityPolicy interface {
+	// IsSafeAttribute returns true if the attribute, field or method of the given object can be accessed
+	IsSafeAttribute(object *Value, attribute string) bool
+	// IsSafeCallable returns true if the given function or callable object can be called
+	IsSafeCallable(callable *Value) bool
+}
+
+// AllowList is a SecurityPolicy restricting the attributes, fields and methods of the listed Go types to the
+// given names. Struct types missing from the list are denied entirely, except the ones of the values created by
+// gonja itself, like dicts or the loop object of for loops. Values of other types missing from the list, like maps,
+// slices, strings or functions, are not restricted. Methods are controlled through the attribute they are accessed
+// with, and calling a value is accessing its "Call" attribute, which callable objects of struct types must list.
+type AllowList map[reflect.Type][]string
+
+func (a AllowList) IsSafeAttribute(object *Value, attribute string) bool {
+	if object == nil {
+		return true
+	}
+	val := object.Val
+	for val.IsValid() && val.Kind() == reflect.Interface {
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return true
+	}
+	t := val.Type()
+	allowed, restricted := a[t]
+	if !restricted && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		allowed, restricted = a[t]
+	}
+	if !restricted {
+		return t.Kind() != reflect.Struct || isEngineType(t)
+	}
+	return slices.Contains(allowed, attribute)
+}
+
+func (a AllowList) IsSafeCallable(callable *Value) bool {
+	return a.IsSafeAttribute(callable, "Call")
+}
+
+// enginePackages are the packages defining the types of the values created by gonja itself
+var enginePackages = []string{
+	reflect.TypeFor[Value]().PkgPath(),
+	path.Join(path.Dir(reflect.TypeFor[Value]().PkgPath()), "builtins"),
+}
+
+func isEngineType(t reflect.Type) bool {
+	for _, pkg := range enginePackages {
+		if t.PkgPath() == pkg || strings.HasPrefix(t.PkgPath(), pkg+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Names of the limits reported in a SecurityError
+const (
+	LimitOutputSize     = "MaxOutputSize"
+	LimitLoopIterations = "MaxLoopIterations"
+	LimitRecursionDepth = "MaxRecursionDepth"
+	LimitRangeLength    = "MaxRangeLength"
+)
+
+// SecurityError is returned when a template goes over one of the limits of the sandbox,
+// or tries to access an attribute or a callable forbidden by its security policy
+type SecurityError struct {
+	// Template is the identifier of the template being executed
+	Template string
+	// Limit is the name of the limit that was exceeded, if any
+	Limit string
+	// Max is the value configured for the exceeded limit
+	Max int
+	// Attribute is the attribute or callable refused by the security policy, if any
+	Attribute string
+}
+
+func (e *SecurityError) Error() string {
+	if e.Limit == "" {
+		return fmt.Sprintf("template '%s' is not allowed to access '%s'", e.Template, e.Attribute)
+	}
+	return fmt.Sprintf("template '%s' exceeded the sandbox limit %s of %d", e.Template, e.Limit, e.Max)
+}
+
+func (x *execution) violation(limit string, maximum int) error {
+	template := ""
+	if x != nil {
+		template = x.template
+	}
+	return &SecurityError{
+		Template: template,
+		Limit:    limit,
+		Max:      maximum,
+	}
+}
+
+func (x *execution) denied(attribute string) error {
+	template := ""
+	if x != nil {
+		template = x.template
+	}
+	return &SecurityError{
+		Template:  template,
+		Attribute: attribute,
+	}
+}
+
+// enter records a new nesting level of templates or macro calls and fails
+// when the maximum recursion depth is exceeded. It must be balanced by leave
+func (x *execution) enter() error {
+	if x == nil {
+		return nil
+	}
+	x.depth++
+	if x.sandbox != nil && x.sandbox.MaxRecursionDepth > 0 && x.depth > x.sandbox.MaxRecursionDepth {
+		x.depth--
+		return x.violation(LimitRecursionDepth, x.sandbox.MaxRecursionDepth)
+	}
+	return nil
+}
+
+func (x *execution) leave() {
+	if x != nil {
+		x.depth--
+	}
+}
+
+// limitedWriter fails writes going over the maximum output size of the sandbox
+type limitedWriter struct {
+	output    io.Writer
+	execution *execution
+	written   int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	maxSize := w.execution.sandbox.MaxOutputSize
+	if w.written+len(p) > maxSize {
+		return 0, w.execution.violation(LimitOutputSize, maxSize)
+	}
+	n, err := w.output.Write(p)
+	w.written += n
+	return n, err
+}
+
+// Flush flushes the output, which flush tags must reach through the limit
+func (w *limitedWriter) Flush() error {
+	return flush(w.output)
+}
+
+// limit returns the given output limited to the maximum output size of the sandbox, if any
+func (x *execution) limit(output io.Writer) io.Writer {
+	if x == nil || x.sandbox == nil || x.sandbox.MaxOutputSize <= 0 {
+		return output
+	}
+	return &limitedWriter{
+		output:    output,
+		execution: x,
+	}
+}
+
+// Buffer redirects the output of the renderer to the given buffer, for content rendered in memory
+// like the body of macros or filter blocks, whose size is limited by the sandbox like the output
+func (r *Renderer) Buffer(buffer io.Writer) {
+	r.Output = r.execution.limit(buffer)
+}
+
+// CountLoopIteration records a loop iteration and returns a *SecurityError
+// when the maximum number of loop iterations of the sandbox is exceeded
+func (r *Renderer) CountLoopIteration() error {
+	x := r.execution
+	if x == nil || x.sandbox == nil || x.sandbox.MaxLoopIterations <= 0 {
+		return nil
+	}
+	x.iterations++
+	if x.iterations > x.sandbox.MaxLoopIterations {
+		return x.violation(LimitLoopIterations, x.sandbox.MaxLoopIterations)
+	}
+	return nil
+}
+
+// CheckRangeLength returns a *SecurityError when the sandbox
+// forbids producing a range with the given number of items
+func (e *Evaluator) CheckRangeLength(length int) error {
+	if e.Environment == nil || e.Environment.Sandbox == nil {
+		return nil
+	}
+	maxLength := e.Environment.Sandbox.MaxRangeLength
+	if maxLength > 0 && length > maxLength {
+		return e.execution.violation(LimitRangeLength, maxLength)
+	}
+	return nil
+}
+
+// checkStringSize returns a *SecurityError when a string of the given size built by an
+// expression, like a repetition or a concatenation, would go over the maximum output size
+func (e *Evaluator) checkStringSize(size int64) error {
+	if e.Environment == nil || e.Environment.Sandbox == nil {
+		return nil
+	}
+	maxSize := e.Environment.Sandbox.MaxOutputSize
+	if maxSize > 0 && size > int64(maxSize) {
+		return e.execution.violation(LimitOutputSize, maxSize)
+	}
+	return nil
+}
+
+func (e *Evaluator) policy() SecurityPolicy {
+	if e == nil || e.Environment == nil || e.Environment.Sandbox == nil {
+		return nil
+	}
+	return e.Environment.Sandbox.Policy
+}
+
+// GetAttribute returns the attribute of the given value like Value.GetAttribute, but
+// returns a *SecurityError value when the security policy forbids accessing it. The policy
+// is asked first, so that the getters of denied attributes are never run
+func (e *Evaluator) GetAttribute(value *Value, name string) (*Value, bool) {
+	if policy := e.policy(); policy != nil && !policy.IsSafeAttribute(value, name) {
+		return AsValue(e.execution.denied(name)), false
+	}
+	return value.GetAttribute(name)
+}
+
+// Get returns the attribute or item of the given value like Value.Get,
+// while enforcing the security policy like GetAttribute
+func (e *Evaluator) Get(value *Value, key string) (*Value, bool) {
+	attribute, found := e.GetAttribute(value, key)
+	if !found && !isDenied(attribute) {
+		return value.GetItem(key)
+	}
+	return attribute, found
+}
+
+func (e *Evaluator) checkCallable(callable *Value, name string) error {
+	if policy := e.policy(); policy != nil && !policy.IsSafeCallable(callable) {
+		return e.execution.denied(name)
+	}
+	return nil
+}
+
+// CheckFields returns a *SecurityError when the security policy forbids accessing one of the fields of
+// the structs nested in the given value, for filters serializing whole values at once like tojson
+func (e *Evaluator) CheckFields(value *Value) error {
+	policy := e.policy()
+	if policy == nil || value == nil {
+		return nil
+	}
+	return e.checkFields(policy, value.Val, map[uintptr]bool{})
+}
+
+func (e *Evaluator) checkFields(policy SecurityPolicy, val reflect.Value, visited map[uintptr]bool) error {
+	for val.IsValid() && (val.Kind() == reflect.Interface || val.Kind() == reflect.Pointer) {
+		if val.IsNil() {
+			return nil
+		}
+		if val.Kind() == reflect.Pointer {
+			if visited[val.Pointer()] {
+				return nil
+			}
+			visited[val.Pointer()] = true
+			if value, ok := val.Interface().(*Value); ok {
+				return e.checkFields(policy, value.Val, visited)
+			}
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() {
+		return nil
+	}
+	switch val.Kind() {
+	case reflect.Struct:
+		if dict, ok := val.Interface().(Dict); ok {
+			for _, pair := range dict.Pairs {
+				if err := e.checkFields(policy, reflect.ValueOf(pair.Value), visited); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for i := 0; i < val.NumField(); i++ {
+			field := val.Type().Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			if !policy.IsSafeAttribute(&Value{Val: val}, field.Name) {
+				return e.execution.denied(field.Name)
+			}
+			if err := e.checkFields(policy, val.Field(i), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			if err := e.checkFields(policy, iter.Value(), visited); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			if err := e.checkFields(policy, val.Index(i), visited); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isDenied(value *Value) bool {
+	denied := new(SecurityError)
+	return value.IsError() && errors.As(value, &denied)
+}
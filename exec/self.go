@@ -1,32 +1,59 @@
 package exec
 
 import (
-	"maps"
 	"strings"
 
 	"github.com/nikolalohinski/gonja/v2/nodes"
 )
 
-func getBlocks(tpl *nodes.Template) map[string]*nodes.Wrapper {
+// getBlocks returns the templates defining the blocks of the given template, by name of block
+func getBlocks(tpl *nodes.Template) map[string]*nodes.Template {
 	if tpl == nil {
-		return map[string]*nodes.Wrapper{}
+		return map[string]*nodes.Template{}
 	}
 	blocks := getBlocks(tpl.Parent)
-	maps.Copy(blocks, tpl.Blocks)
+	for name := range tpl.Blocks {
+		blocks[name] = tpl
+	}
 	return blocks
 }
 
+// Self returns the blocks of the rendered template as functions rendering them to strings.
+// Use SelfValues for the 'self' variable, so that rendered blocks are not escaped twice.
 func Self(r *Renderer) map[string]func() string {
 	blocks := map[string]func() string{}
-	for name, b := range getBlocks(r.RootNode) {
-		block := b
+	for name, render := range SelfValues(r) {
 		blocks[name] = func() string {
-			sub := r.Inherit()
-			var out strings.Builder
-			sub.Output = &out
-			sub.ExecuteWrapper(block)
-			return out.String()
+			return render().String()
 		}
 	}
 	return blocks
 }
+
+// SelfValues returns the blocks of the rendered template as functions rendering them, like the 'self' variable
+// in Jinja. The rendered blocks are marked as safe since they were already escaped if need be.
+func SelfValues(r *Renderer) map[string]func() *Value {
+	blocks := map[string]func() *Value{}
+	for name := range getBlocks(r.RootNode) {
+		blocks[name] = renderBlocks(r, r.RootNode.GetBlocks(name), r.RootNode.GetBlockTemplates(name))
+	}
+	return blocks
+}
+
+// renderBlocks returns a function rendering the first of the given blocks, overriding the next ones which
+// are rendered by the 'super' function, each with the autoescaping of the template defining it
+func renderBlocks(r *Renderer, blocks []*nodes.Wrapper, templates []*nodes.Template) func() *Value {
+	return func() *Value {
+		if len(blocks) == 0 {
+			return AsSafeValue("")
+		}
+		sub := r.ForTemplate(templates[0].Identifier).Inherit()
+		var out strings.Builder
+		sub.Buffer(&out)
+		sub.Environment.Context.Set("super", renderBlocks(r, blocks[1:], templates[1:]))
+		if err := sub.ExecuteWrapper(blocks[0]); err != nil {
+			return AsValue(err)
+		}
+		return sub.RenderedValue(out.String())
+	}
+}
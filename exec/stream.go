@@ -0,0 +1,95 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"iter"
+)
+
+// StreamChunkSize is the size from which the output of a template rendered by Template.Stream is yielded,
+// bounding the memory it uses besides the values being rendered
+const StreamChunkSize = 4096
+
+// errStreamStopped stops the rendering of a template once the consumer of its stream stopped iterating
+var errStreamStopped = errors.New("stream stopped by its consumer")
+
+// Stream executes the template like ExecuteContext, yielding the rendered content by chunks of about
+// StreamChunkSize bytes as it is rendered, along with what is rendered before each flush tag. If rendering
+// fails, the content rendered so far is yielded before the error. Rendering stops when the iteration does.
+//
+//	for chunk, err := range template.Stream(ctx, data) {
+//		if err != nil {
+//			return err
+//		}
+//		io.WriteString(w, chunk)
+//	}
+func (t *Template) Stream(ctx context.Context, data *Context) iter.Seq2[string, error] {
+	return func(yield func(string, error) bool) {
+		output := &streamWriter{yield: yield, buffer: make([]byte, 0, StreamChunkSize)}
+		err := t.ExecuteContext(ctx, output, data)
+		if output.stopped {
+			return
+		}
+		if err := output.Flush(); err != nil {
+			return
+		}
+		if err != nil {
+			yield("", err)
+		}
+	}
+}
+
+// streamWriter buffers the output of a template, yielding it once it reaches StreamChunkSize or is flushed
+type streamWriter struct {
+	yield   func(string, error) bool
+	buffer  []byte
+	stopped bool
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.stopped {
+		return 0, errStreamStopped
+	}
+	w.buffer = append(w.buffer, p...)
+	if len(w.buffer) >= StreamChunkSize {
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush yields the buffered output, if any
+func (w *streamWriter) Flush() error {
+	if w.stopped {
+		return errStreamStopped
+	}
+	if len(w.buffer) == 0 {
+		return nil
+	}
+	chunk := string(w.buffer)
+	w.buffer = w.buffer[:0]
+	if !w.yield(chunk, nil) {
+		w.stopped = true
+		return errStreamStopped
+	}
+	return nil
+}
+
+// flush sends what was written to the output to its consumer, if it supports it like
+// http.ResponseWriter, bufio.Writer or the output of Template.Stream
+func flush(output io.Writer) error {
+	switch o := output.(type) {
+	case interface{ Flush() error }:
+		return o.Flush()
+	case interface{ Flush() }:
+		o.Flush()
+	}
+	return nil
+}
+
+// Flush sends what was rendered so far to the consumer of the output, as done by flush tags
+func (r *Renderer) Flush() error {
+	return flush(r.Output)
+}
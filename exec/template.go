@@ -2,6 +2,7 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strings"
@@ -23,10 +24,21 @@ type Template struct {
 	tokens      *tokens.Stream
 	parser      *parser.Parser
 	root        *nodes.Template
+
+	// version of the source and extended templates, used by caches to tell whether the template is up to date
+	version  string
+	extended []*Template
+	// scope is the loader given to load the template rendered first, the ones it includes, imports
+	// or extends being loaded from loaders inherited from it. Caches only share templates within a scope
+	scope loaders.Loader
 }
 
 // NewTemplate creates a gonja template instance that can be executed with a given context later on
 func NewTemplate(identifier string, config *config.Config, loader loaders.Loader, environment *Environment) (*Template, error) {
+	return newTemplate(identifier, config, loader, environment, loader)
+}
+
+func newTemplate(identifier string, config *config.Config, loader loaders.Loader, environment *Environment, scope loaders.Loader) (*Template, error) {
 	input, err := loader.Read(identifier)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reader template '%s': %s", identifier, err)
@@ -34,7 +46,7 @@ func NewTemplate(identifier string, config *config.Config, loader loaders.Loader
 
 	source := new(strings.Builder)
 	if _, err := io.Copy(source, input); err != nil {
-		return nil, fmt.Errorf("failed to copy '%s' to string buffer: %s", source, err)
+		return nil, fmt.Errorf("failed to copy '%s' to string buffer: %s", identifier, err)
 	}
 
 	t := &Template{
@@ -43,21 +55,74 @@ func NewTemplate(identifier string, config *config.Config, loader loaders.Loader
 		loader:      loader,
 		tokens:      tokens.LexAll(source.String(), config),
 		environment: environment,
+		scope:       scope,
 	}
 
 	t.parser = parser.NewParser(identifier, t.tokens, config, loader, environment.ControlStructures)
+	if environment.Cache != nil {
+		t.parser.Templates = &templateLoader{environment: environment, template: t}
+	}
 
 	root, err := t.parser.Parse()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse template '%s': %s", source, err)
+		return nil, fmt.Errorf("failed to parse template '%s': %w", identifier, parser.NewTemplateError(identifier, t.tokens.Source, nil, err))
 	}
 	t.root = root
 
 	return t, nil
 }
 
+// NewTemplateFromRoot creates a gonja template instance from an already parsed template, like one
+// loaded back with nodes.Template.UnmarshalJSON, without reading nor parsing its source again.
+// The loader is still used for templates resolved at render time, like included or imported ones.
+func NewTemplateFromRoot(root *nodes.Template, config *config.Config, loader loaders.Loader, environment *Environment) *Template {
+	return &Template{
+		config:      config,
+		loader:      loader,
+		environment: environment,
+		parser:      parser.NewParser(root.Identifier, nil, config, loader, environment.ControlStructures),
+		root:        root,
+		scope:       loader,
+	}
+}
+
 // Execute executes the template and returns the rendered content in the provided writer
 func (t *Template) Execute(wr io.Writer, data *Context) error {
+	return t.ExecuteContext(context.Background(), wr, data)
+}
+
+// ExecuteContext executes the template like Execute, but stops rendering as soon as possible
+// once the given context is cancelled or its deadline exceeded, returning a *CancelledError
+func (t *Template) ExecuteContext(ctx context.Context, wr io.Writer, data *Context) error {
+	renderer := t.newRenderer(ctx, wr, data)
+	err := renderer.Execute()
+	if err != nil {
+		return errors.Wrap(t.withSource(err), "unable to execute template")
+	}
+
+	return nil
+}
+
+// ExecuteBlock executes the named block of the template only, like for the partial updates of a page. The
+// block is rendered as overridden by the template, super() rendering the one of the template it extends and self
+// its other blocks, including the templates extended at render time.
+func (t *Template) ExecuteBlock(name string, wr io.Writer, data *Context) error {
+	return t.ExecuteBlockContext(context.Background(), name, wr, data)
+}
+
+// ExecuteBlockContext executes the named block of the template like ExecuteBlock, but stops rendering as soon
+// as possible once the given context is cancelled or its deadline exceeded, returning a *CancelledError
+func (t *Template) ExecuteBlockContext(ctx context.Context, name string, wr io.Writer, data *Context) error {
+	renderer := t.newRenderer(ctx, wr, data)
+	if err := renderer.ExecuteBlock(name); err != nil {
+		return errors.Wrapf(t.withSource(err), "unable to execute block '%s'", name)
+	}
+
+	return nil
+}
+
+// newRenderer returns the renderer executing the template with the given data
+func (t *Template) newRenderer(ctx context.Context, wr io.Writer, data *Context) *Renderer {
 	if data == nil {
 		data = EmptyContext()
 	}
@@ -68,14 +133,17 @@ func (t *Template) Execute(wr io.Writer, data *Context) error {
 		ControlStructures: t.environment.ControlStructures,
 		Context:           t.environment.Context.Inherit().Update(data),
 		Methods:           t.environment.Methods,
+		Escapers:          t.environment.Escapers,
+		Sandbox:           t.environment.Sandbox,
+		Translator:        t.environment.Translator,
+		Loader:            t.environment.Loader,
+		Config:            t.environment.Config,
+		Cache:             t.environment.Cache,
 	}, wr, t.config, t.loader, t)
+	renderer.execution = newExecution(ctx, t.root.Identifier, t.environment.Sandbox)
+	renderer.Output = renderer.execution.limit(wr)
 
-	err := renderer.Execute()
-	if err != nil {
-		return errors.Wrap(err, "unable to execute template")
-	}
-
-	return nil
+	return renderer
 }
 
 // ExecuteToString executes the template and returns the rendered content as a string
@@ -18,6 +18,9 @@ import (
 type Value struct {
 	Val  reflect.Value
 	Safe bool // used to indicate whether a Value needs explicit escaping in the template
+	// Escaper is the escaping strategy a safe Value was escaped with. A safe Value
+	// without any is safe for all of them, like the ones marked with the 'safe' filter
+	Escaper string
 }
 
 type AttributeGetter interface {
@@ -41,7 +44,8 @@ func AsValue(i any) *Value {
 	}
 }
 
-// AsSafeValue works like AsValue, but does not apply the 'escape' filter.
+// AsSafeValue works like AsValue, but does not apply the 'escape' filter. The value is trusted
+// by every escaping strategy, so AsEscapedValue must be used for content escaped for one of them.
 func AsSafeValue(i any) *Value {
 	return &Value{
 		Val:  reflect.ValueOf(i),
@@ -49,6 +53,16 @@ func AsSafeValue(i any) *Value {
 	}
 }
 
+// AsEscapedValue works like AsSafeValue, but the value is only
+// considered safe for the given escaping strategy
+func AsEscapedValue(i any, escaper string) *Value {
+	return &Value{
+		Val:     reflect.ValueOf(i),
+		Safe:    true,
+		Escaper: escaper,
+	}
+}
+
 func ValueError(err error) *Value {
 	return &Value{Val: reflect.ValueOf(err)}
 }
@@ -129,6 +143,15 @@ func (v *Value) Error() string {
 	return ""
 }
 
+// Unwrap returns the underlying error if any, so that errors carried by values
+// can be inspected using errors.Is and errors.As
+func (v *Value) Unwrap() error {
+	if v.IsError() {
+		return v.Interface().(error)
+	}
+	return nil
+}
+
 func (v *Value) ToGoSimpleType(allowInterfaceKeys bool) any {
 	switch {
 	case v.IsError():
@@ -376,11 +399,17 @@ func ensureFloatSuffix(formatted string) string {
 	return formatted + ".0"
 }
 
-// Escaped returns the escaped version of String()
+// Escaped returns the HTML escaped version of String()
 func (v *Value) Escaped() string {
 	return u.Escape(v.String())
 }
 
+// IsSafeFor returns true if the value does not need to be escaped with the given escaping strategy, being
+// the case of values escaped for it and of the ones trusted by every strategy, like the output of 'safe'
+func (v *Value) IsSafeFor(escaper string) bool {
+	return v.Safe && (v.Escaper == "" || v.Escaper == escaper)
+}
+
 // Integer returns the underlying value as an integer (converts the underlying
 // value, if necessary). If it's not possible to convert the underlying value,
 // it will return 0.
@@ -918,7 +947,10 @@ func (v *Value) Items() []*Pair {
 }
 
 func ToValue(data any) *Value {
-	var isSafe bool
+	var (
+		isSafe  bool
+		escaper string
+	)
 	// if data == nil {
 	// 	return AsValue(nil), nil
 	// }
@@ -965,13 +997,14 @@ func ToValue(data any) *Value {
 		tmpValue := val.Interface().(*Value)
 		val = tmpValue.Val
 		isSafe = tmpValue.Safe
+		escaper = tmpValue.Escaper
 	}
 
 	if !val.IsValid() {
 		// Value is not valid (e.g. nil value)
 		return AsValue(nil)
 	}
-	return &Value{Val: val, Safe: isSafe}
+	return &Value{Val: val, Safe: isSafe, Escaper: escaper}
 }
 
 func (v *Value) GetAttribute(name string) (*Value, bool) {
@@ -0,0 +1,225 @@
+package format
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Precedences of expressions, from the loosest to the tightest binding
+const (
+	precedenceOr = iota + 1
+	precedenceAnd
+	precedenceNot
+	precedenceTest
+	precedenceCompare
+	precedenceAddition
+	precedenceConcatenation
+	precedenceMultiplication
+	precedencePower
+	precedenceUnary
+	precedencePrimary
+)
+
+var binaryPrecedences = map[tokens.Type]int{
+	tokens.Or:                 precedenceOr,
+	tokens.And:                precedenceAnd,
+	tokens.Equals:             precedenceCompare,
+	tokens.Ne:                 precedenceCompare,
+	tokens.GreaterThan:        precedenceCompare,
+	tokens.GreaterThanOrEqual: precedenceCompare,
+	tokens.LowerThan:          precedenceCompare,
+	tokens.LowerThanOrEqual:   precedenceCompare,
+	tokens.Addition:           precedenceAddition,
+	tokens.Subtraction:        precedenceAddition,
+	tokens.Tilde:              precedenceConcatenation,
+	tokens.Multiply:           precedenceMultiplication,
+	tokens.Division:           precedenceMultiplication,
+	tokens.FloorDivision:      precedenceMultiplication,
+	tokens.Modulo:             precedenceMultiplication,
+	tokens.Power:              precedencePower,
+}
+
+// conditional writes an expression followed by its optional condition and alternative
+func (p *printer) conditional(expression, condition, alternative nodes.Expression) string {
+	source := p.expression(expression, 0)
+	if condition != nil {
+		source += " if " + p.expression(condition, 0)
+		if alternative != nil {
+			source += " else " + p.expression(alternative, 0)
+		}
+	}
+	return source
+}
+
+// expression writes an expression, within parentheses when it binds looser than the given precedence
+func (p *printer) expression(expression nodes.Node, precedence int) string {
+	source, own := p.operation(expression)
+	if own < precedence {
+		return "(" + source + ")"
+	}
+	return source
+}
+
+// operation writes an expression, returning its precedence
+func (p *printer) operation(expression nodes.Node) (string, int) {
+	switch n := expression.(type) {
+	case nil:
+		return "", precedencePrimary
+	case *nodes.String:
+		value := n.Val
+		if n.Location != nil && n.Location.Type == tokens.String {
+			// The token holds the escape sequences of the source
+			value = n.Location.Val
+		}
+		return quote(value), precedencePrimary
+	case *nodes.Integer:
+		if n.Location != nil {
+			return n.Location.Val, precedencePrimary
+		}
+		return strconv.Itoa(n.Val), precedencePrimary
+	case *nodes.Float:
+		if n.Location != nil {
+			return n.Location.Val, precedencePrimary
+		}
+		return strconv.FormatFloat(n.Val, 'f', -1, 64), precedencePrimary
+	case *nodes.Bool:
+		if n.Location != nil {
+			return n.Location.Val, precedencePrimary
+		}
+		return strconv.FormatBool(n.Val), precedencePrimary
+	case *nodes.None:
+		if n.Location != nil && n.Location.Type == tokens.Name {
+			return n.Location.Val, precedencePrimary
+		}
+		return "None", precedencePrimary
+	case *nodes.Name:
+		return n.Name.Val, precedencePrimary
+	case *nodes.List:
+		return "[" + p.list(n.Val) + "]", precedencePrimary
+	case *nodes.Tuple:
+		if len(n.Val) == 1 {
+			return "(" + p.list(n.Val) + ",)", precedencePrimary
+		}
+		return "(" + p.list(n.Val) + ")", precedencePrimary
+	case *nodes.Dict:
+		pairs := make([]string, 0, len(n.Pairs))
+		for _, pair := range n.Pairs {
+			pairs = append(pairs, p.expression(pair, 0))
+		}
+		return "{" + strings.Join(pairs, ", ") + "}", precedencePrimary
+	case *nodes.Pair:
+		return p.expression(n.Key, 0) + ": " + p.expression(n.Value, 0), precedencePrimary
+	case *nodes.Variable:
+		var source strings.Builder
+		for i, part := range n.Parts {
+			if i > 0 {
+				source.WriteString(".")
+			}
+			if part.Type == nodes.VarTypeInt {
+				source.WriteString(strconv.Itoa(part.I))
+			} else {
+				source.WriteString(part.S)
+			}
+			if part.IsFunctionCall {
+				source.WriteString("(" + p.arguments(part.Args, part.Kwargs) + ")")
+			}
+		}
+		return source.String(), precedencePrimary
+	case *nodes.Call:
+		return p.expression(n.Func, precedencePrimary) + "(" + p.arguments(n.Args, n.Kwargs) + ")", precedencePrimary
+	case *nodes.GetAttribute:
+		attribute := n.Attribute
+		if attribute == "" {
+			attribute = strconv.Itoa(n.Index)
+		}
+		return p.expression(n.Node, precedencePrimary) + "." + attribute, precedencePrimary
+	case *nodes.GetItem:
+		return p.expression(n.Node, precedencePrimary) + "[" + p.expression(n.Arg, 0) + "]", precedencePrimary
+	case *nodes.GetSlice:
+		return p.expression(n.Node, precedencePrimary) + "[" + p.expression(n.Start, 0) + ":" + p.expression(n.End, 0) + "]", precedencePrimary
+	case *nodes.FilteredExpression:
+		source := p.expression(n.Expression, precedenceUnary)
+		for _, filter := range n.Filters {
+			source += " | " + p.filter(filter)
+		}
+		return source, precedenceUnary
+	case *nodes.UnaryExpression:
+		return n.Operator.Val + p.expression(n.Term, precedencePrimary), precedenceUnary
+	case *nodes.TestExpression:
+		return p.test(n, false), precedenceTest
+	case *nodes.Negation:
+		if test, ok := n.Term.(*nodes.TestExpression); ok {
+			return p.test(test, true), precedenceTest
+		}
+		return "not " + p.expression(n.Term, precedenceTest), precedenceNot
+	case *nodes.BinaryExpression:
+		precedence, ok := binaryPrecedences[n.Operator.Token.Type]
+		if !ok {
+			p.fail(expression, "unable to format operator '%s'", n.Operator.Token.Val)
+		}
+		// Operators are left associative
+		return p.expression(n.Left, precedence) + " " + n.Operator.Token.Val + " " + p.expression(n.Right, precedence+1), precedence
+	}
+	p.fail(expression, "unable to format expression %s", expression)
+	return "", precedencePrimary
+}
+
+// test writes a test expression, with 'in' being written as an operator
+func (p *printer) test(expression *nodes.TestExpression, negated bool) string {
+	source := p.expression(expression.Expression, precedenceCompare)
+	test := expression.Test
+	switch {
+	case test.Name == "in" && negated:
+		source += " not in"
+	case test.Name == "in":
+		source += " in"
+	case negated:
+		source += " is not " + test.Name
+	default:
+		source += " is " + test.Name
+	}
+	for _, argument := range test.Args {
+		source += " " + p.expression(argument, precedencePrimary)
+	}
+	return source
+}
+
+func (p *printer) filter(filter *nodes.FilterCall) string {
+	if len(filter.Args) == 0 && len(filter.Kwargs) == 0 {
+		return filter.Name
+	}
+	return filter.Name + "(" + p.arguments(filter.Args, filter.Kwargs) + ")"
+}
+
+func (p *printer) list(expressions []nodes.Expression) string {
+	sources := make([]string, 0, len(expressions))
+	for _, expression := range expressions {
+		sources = append(sources, p.expression(expression, 0))
+	}
+	return strings.Join(sources, ", ")
+}
+
+// arguments writes the arguments of a call, followed by its keyword arguments sorted by name
+func (p *printer) arguments(args []nodes.Expression, kwargs map[string]nodes.Expression) string {
+	arguments := p.list(args)
+	for _, name := range slices.Sorted(maps.Keys(kwargs)) {
+		if arguments != "" {
+			arguments += ", "
+		}
+		arguments += name + "=" + p.expression(kwargs[name], 0)
+	}
+	return arguments
+}
+
+// quote quotes a string with double quotes, unless it holds some and no single quote
+func quote(value string) string {
+	if strings.Contains(value, `"`) && !strings.Contains(value, `'`) {
+		return `'` + value + `'`
+	}
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
@@ -0,0 +1,353 @@
+// Package format regenerates the canonical source of parsed templates: the expressions within delimiters are
+// written with a single space around operators and inside delimiters, and the tags of nested control structures
+// are indented when the whitespaces preceding them are stripped. Text, comments, raw blocks and whitespace control
+// markers are kept as they are.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Indent is written once per level of nesting before the tags starting a line
+const Indent = "  "
+
+// Source parses the source of a template with the control structures of the environment and formats it.
+// Whitespace control is disabled while parsing so that the text of the template is kept as is, the config
+// only providing the delimiters and whether the whitespaces before tags are stripped with LeftStripBlocks.
+// The templates it extends are loaded with the loader.
+func Source(identifier, source string, cfg *config.Config, loader loaders.Loader, environment *exec.Environment) (string, error) {
+	if cfg.LineStatementPrefix != "" || cfg.LineCommentPrefix != "" {
+		return "", fmt.Errorf("unable to format template '%s': line statements and line comments are not supported", identifier)
+	}
+	leftStrip := cfg.LeftStripBlocks
+	cfg = cfg.Inherit()
+	cfg.TrimBlocks = false
+	cfg.LeftStripBlocks = false
+	cfg.KeepTrailingNewline = true
+
+	root, err := parser.NewParser(identifier, tokens.LexAll(source, cfg), cfg, loader, environment.ControlStructures).Parse()
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template '%s': %w", identifier, parser.NewTemplateError(identifier, source, nil, err))
+	}
+	return format(root, cfg, leftStrip)
+}
+
+// Format returns the canonical source of a parsed template. The template must have been parsed with the
+// TrimBlocks and LeftStripBlocks options disabled and KeepTrailingNewline enabled, otherwise the whitespaces
+// removed by the lexer are missing from its source, which Source takes care of.
+//
+// The whitespaces before the tags starting a line are replaced with the indentation of their nesting level
+// when they are stripped by a '-' marker, so that the rendered output is kept.
+func Format(root *nodes.Template, cfg *config.Config) (string, error) {
+	return format(root, cfg, false)
+}
+
+// format returns the canonical source of a parsed template, reindenting the tags starting a line unless
+// they have a '+' marker when leftStrip tells the whitespaces before them are stripped by LeftStripBlocks
+func format(root *nodes.Template, cfg *config.Config, leftStrip bool) (string, error) {
+	p := &printer{config: cfg, leftStrip: leftStrip}
+	p.nodes(root.Nodes)
+	if p.err != nil {
+		return "", p.err
+	}
+	return p.out.String(), nil
+}
+
+// printer writes the source of nodes, recording the first node it fails to format
+type printer struct {
+	config *config.Config
+	out    bytes.Buffer
+	err    error
+	// leftStrip tells whether the whitespaces before tags are stripped when rendering, like with LeftStripBlocks
+	leftStrip bool
+	// depth is the nesting level of the nodes being written, and verbatim is positive within
+	// the bodies whose whitespaces must not be reindented
+	depth    int
+	verbatim int
+}
+
+func (p *printer) fail(node nodes.Node, format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	err := fmt.Errorf(format, args...)
+	if position := node.Position(); position != nil {
+		err = fmt.Errorf("line %d col %d: %w", position.Line, position.Col, err)
+	}
+	p.err = err
+}
+
+func (p *printer) nodes(list []nodes.Node) {
+	for _, node := range list {
+		p.node(node)
+	}
+}
+
+// body writes the nodes of a wrapper one level deeper
+func (p *printer) body(wrapper *nodes.Wrapper) {
+	p.depth++
+	p.nodes(wrapper.Nodes)
+	p.depth--
+}
+
+func (p *printer) node(node nodes.Node) {
+	switch n := node.(type) {
+	case *nodes.Data:
+		p.out.WriteString(n.Data.Val)
+	case *nodes.Comment:
+		p.indent(marker(n.Start, p.config.CommentStartString, ""))
+		p.out.WriteString(n.Start.Val + n.Text + n.End.Val)
+	case *nodes.Output:
+		p.out.WriteString(p.config.VariableStartString)
+		p.out.WriteString(marker(n.Start, p.config.VariableStartString, ""))
+		p.out.WriteString(" " + p.conditional(n.Expression, n.Condition, n.Alternative) + " ")
+		p.out.WriteString(marker(n.End, "", p.config.VariableEndString))
+		p.out.WriteString(p.config.VariableEndString)
+	case *nodes.Wrapper:
+		p.nodes(n.Nodes)
+	case *nodes.ControlStructureBlock:
+		p.controlStructure(n)
+	default:
+		p.fail(node, "unable to format node %s", node)
+	}
+}
+
+// marker returns the whitespace control marker of a delimiter token, if any
+func marker(token *tokens.Token, prefix, suffix string) string {
+	if token == nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(token.Val), prefix), suffix))
+}
+
+// indent replaces the whitespaces preceding a tag with the indentation of the current level when the tag starts
+// a line, provided they are stripped when rendering given the whitespace control marker opening the tag
+func (p *printer) indent(opening string) {
+	if p.verbatim > 0 || opening != "-" && (!p.leftStrip || opening == "+") {
+		return
+	}
+	written := p.out.Bytes()
+	start := bytes.LastIndexByte(written, '\n') + 1
+	if len(bytes.Trim(written[start:], " \t")) > 0 {
+		return
+	}
+	p.out.Truncate(start)
+	p.out.WriteString(strings.Repeat(Indent, p.depth))
+}
+
+// tag writes a tag delimited by the given tokens, holding the given arguments
+func (p *printer) tag(begin, end *tokens.Token, arguments ...string) {
+	opening := marker(begin, p.config.BlockStartString, "")
+	p.indent(opening)
+	p.out.WriteString(p.config.BlockStartString + opening + " ")
+	for _, argument := range arguments {
+		if argument != "" {
+			p.out.WriteString(argument + " ")
+		}
+	}
+	p.out.WriteString(marker(end, "", p.config.BlockEndString) + p.config.BlockEndString)
+}
+
+// end writes the tag ending a wrapper
+func (p *printer) end(wrapper *nodes.Wrapper, arguments ...string) {
+	p.tag(wrapper.EndTagBegin, wrapper.EndTagEnd, append([]string{wrapper.EndTag}, arguments...)...)
+}
+
+func (p *printer) controlStructure(block *nodes.ControlStructureBlock) {
+	open := func(arguments ...string) {
+		p.tag(block.Location, block.End, append([]string{block.Name}, arguments...)...)
+	}
+	switch cs := block.ControlStructure.(type) {
+	case *controlStructures.IfControlStructure:
+		open(p.expression(cs.Conditions[0], 0))
+		for i, wrapper := range cs.Wrappers {
+			p.body(wrapper)
+			if wrapper.EndTag == "elif" {
+				p.end(wrapper, p.expression(cs.Conditions[i+1], 0))
+			} else {
+				p.end(wrapper)
+			}
+		}
+	case *controlStructures.ForControlStructure:
+		variables := cs.Key
+		if cs.Value != "" {
+			variables += ", " + cs.Value
+		}
+		var condition, recursive string
+		if cs.IfCondition != nil {
+			condition = "if " + p.expression(cs.IfCondition, 0)
+		}
+		if cs.Recursive {
+			recursive = "recursive"
+		}
+		open(variables, "in", p.expression(cs.ObjectEvaluator, 0), condition, recursive)
+		p.body(cs.BodyWrapper)
+		p.end(cs.BodyWrapper)
+		if cs.EmptyWrapper != nil {
+			p.body(cs.EmptyWrapper)
+			p.end(cs.EmptyWrapper)
+		}
+	case *controlStructures.MacroControlStructure:
+		open(cs.Name + "(" + p.parameters(cs.Macro) + ")")
+		p.body(cs.Wrapper)
+		p.end(cs.Wrapper)
+	case *controlStructures.CallControlStructure:
+		name := block.Name
+		if len(cs.Caller().Kwargs) > 0 {
+			name += "(" + p.parameters(cs.Caller()) + ")"
+		}
+		p.tag(block.Location, block.End, name, p.expression(cs.Call(), 0))
+		p.body(cs.Caller().Wrapper)
+		p.end(cs.Caller().Wrapper)
+	case *controlStructures.FilterControlStructure:
+		filters := make([]string, 0, len(cs.Filters()))
+		for _, filter := range cs.Filters() {
+			filters = append(filters, p.filter(filter))
+		}
+		open(strings.Join(filters, " | "))
+		p.body(cs.Body())
+		p.end(cs.Body())
+	case *controlStructures.BlockControlStructure:
+		open(cs.Name())
+		p.body(cs.Body())
+		p.end(cs.Body())
+	case *controlStructures.WithControlStructure:
+		pairs := cs.Pairs()
+		assignments := make([]string, 0, len(pairs))
+		for _, name := range cs.Names() {
+			assignments = append(assignments, name+" = "+p.expression(pairs[name], 0))
+		}
+		open(strings.Join(assignments, ", "))
+		p.body(cs.Body())
+		p.end(cs.Body())
+	case *controlStructures.AutoescapeControlStructure:
+		mode := strconv.FormatBool(cs.Autoescape)
+		if cs.Escaper != "" {
+			mode = quote(cs.Escaper)
+		}
+		open(mode)
+		p.body(cs.Wrapper)
+		p.end(cs.Wrapper)
+	case *controlStructures.RawControlStructure:
+		open()
+		p.out.WriteString(cs.Data().Data.Val)
+		p.verbatim++
+		p.end(cs.Body())
+		p.verbatim--
+	case *controlStructures.TransControlStructure:
+		p.trans(cs, open)
+	case *controlStructures.SetControlStructure:
+		expression, condition, alternative := cs.Expression()
+		open(p.expression(cs.Target(), 0), "=", p.conditional(expression, condition, alternative))
+	case *controlStructures.DoControlStructure:
+		open(p.conditional(cs.Expression()))
+	case *controlStructures.BreakControlStructure, *controlStructures.ContinueControlStructure, *controlStructures.FlushControlStructure:
+		open()
+	case *controlStructures.ExtendsControlStructure:
+		if expression, condition, alternative := cs.Expression(); expression != nil {
+			open(p.conditional(expression, condition, alternative), withContext(cs.WithContext()))
+		} else {
+			open(quote(cs.Filename()), withContext(cs.WithContext()))
+		}
+	case *controlStructures.IncludeControlStructure:
+		var ignoreMissing string
+		if cs.IgnoreMissing() {
+			ignoreMissing = "ignore missing"
+		}
+		// Included templates are given the context unless told otherwise
+		var context string
+		switch variables, only := cs.Variables(); {
+		case variables != nil && only:
+			context = "with " + p.expression(variables, 0) + " only"
+		case variables != nil:
+			context = "with " + p.expression(variables, 0)
+		case !cs.WithContext():
+			context = "without context"
+		}
+		open(p.expression(cs.FilenameExpression(), 0), ignoreMissing, context)
+	case *controlStructures.ImportControlStructure:
+		open(p.expression(cs.FilenameExpression(), 0), "as", cs.As(), withContext(cs.WithContext()))
+	case *controlStructures.FromImportControlStructure:
+		names := make([]string, 0, len(cs.Aliases))
+		for _, alias := range cs.Aliases {
+			if name := cs.As[alias]; name != alias {
+				names = append(names, name+" as "+alias)
+			} else {
+				names = append(names, name)
+			}
+		}
+		open(p.expression(cs.FilenameExpression, 0), "import", strings.Join(names, ", "), withContext(cs.WithContext))
+	default:
+		p.fail(block, "unable to format control structure '%s'", block.Name)
+	}
+}
+
+func withContext(enabled bool) string {
+	if enabled {
+		return "with context"
+	}
+	return ""
+}
+
+// trans writes a trans block, whose messages are written as is since their whitespaces matter
+func (p *printer) trans(cs *controlStructures.TransControlStructure, open func(...string)) {
+	// Trimming is declared along with variables, separated with commas
+	var arguments []string
+	if cs.Trimmed {
+		arguments = append(arguments, "trimmed")
+	}
+	for _, name := range cs.Declared {
+		if variable, ok := cs.Variables[name].(*nodes.Name); ok && variable.Name.Val == name {
+			arguments = append(arguments, name)
+		} else {
+			arguments = append(arguments, name+" = "+p.expression(cs.Variables[name], 0))
+		}
+	}
+	open(strings.Join(arguments, ", "))
+
+	p.verbatim++
+	defer func() { p.verbatim-- }()
+	for _, wrapper := range cs.Wrappers {
+		p.nodes(wrapper.Nodes)
+		if wrapper.EndTag == "pluralize" && (len(cs.Declared) == 0 || cs.Count != cs.Declared[0]) {
+			// The count defaults to the first variable declared
+			p.end(wrapper, cs.Count)
+		} else {
+			p.end(wrapper)
+		}
+	}
+}
+
+// parameters writes the parameters of a macro with their default values
+func (p *printer) parameters(macro *nodes.Macro) string {
+	parameters := make([]string, 0, len(macro.Kwargs))
+	for _, kwarg := range macro.Kwargs {
+		name := kwarg.Key.(*nodes.String)
+		switch value := kwarg.Value.(type) {
+		case *nodes.Error:
+			// Parameters without default values are required with StrictUndefined
+			parameters = append(parameters, name.Val)
+		case *nodes.None:
+			// and default to None otherwise, located at their name
+			if value.Location == name.Location {
+				parameters = append(parameters, name.Val)
+				continue
+			}
+			parameters = append(parameters, name.Val+"="+p.expression(value, 0))
+		default:
+			parameters = append(parameters, name.Val+"="+p.expression(value, 0))
+		}
+	}
+	return strings.Join(parameters, ", ")
+}
@@ -25,6 +25,7 @@ var (
 		Tests:             builtins.Tests,
 		ControlStructures: builtins.ControlStructures,
 		Methods:           builtins.Methods,
+		Escapers:          builtins.Escapers,
 	}
 )
 
@@ -0,0 +1,145 @@
+// Package i18n provides the extraction of the translatable messages of templates into gettext catalogs.
+package i18n
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Reference is a location of a message in the templates
+type Reference struct {
+	File string
+	Line int
+}
+
+// Message is a translatable message, with its plural form if any, and where it is used
+type Message struct {
+	Singular   string
+	Plural     string
+	References []Reference
+}
+
+// Catalog holds the messages extracted from templates, in order of first appearance
+type Catalog struct {
+	Messages []*Message
+	index    map[[2]string]*Message
+}
+
+// NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{
+		index: map[[2]string]*Message{},
+	}
+}
+
+// Add adds a message to the catalog, or a reference to it when it is already known
+func (c *Catalog) Add(singular, plural string, reference Reference) {
+	key := [2]string{singular, plural}
+	message, ok := c.index[key]
+	if !ok {
+		message = &Message{Singular: singular, Plural: plural}
+		c.index[key] = message
+		c.Messages = append(c.Messages, message)
+	}
+	message.References = append(message.References, reference)
+}
+
+// Extract parses the templates of the loader with the given names, or all the templates it holds if it implements
+// loaders.Lister and no name is given, and returns the messages of their trans blocks and of their calls to the
+// gettext functions with literal strings.
+func Extract(cfg *config.Config, loader loaders.Loader, environment *exec.Environment, names ...string) (*Catalog, error) {
+	if len(names) == 0 {
+		lister, ok := loader.(loaders.Lister)
+		if !ok {
+			return nil, fmt.Errorf("templates must be named since the loader can not list them")
+		}
+		var err error
+		if names, err = lister.List(); err != nil {
+			return nil, fmt.Errorf("failed to list templates: %s", err)
+		}
+	}
+	catalog := NewCatalog()
+	for _, name := range names {
+		input, err := loader.Read(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template '%s': %s", name, err)
+		}
+		source, err := io.ReadAll(input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template '%s': %s", name, err)
+		}
+		root, err := parser.NewParser(name, tokens.LexAll(string(source), cfg), cfg, loader, environment.ControlStructures).Parse()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse template '%s': %s", name, err)
+		}
+		catalog.AddTemplate(name, root)
+	}
+	return catalog, nil
+}
+
+// AddTemplate adds the messages of a parsed template to the catalog, referenced with the given file name.
+// The templates it extends are not walked.
+func (c *Catalog) AddTemplate(file string, root *nodes.Template) {
+	var found []extracted
+	nodes.Inspect(root, func(node nodes.Node) bool {
+		if message, ok := extract(node); ok {
+			found = append(found, message)
+		}
+		return true
+	})
+	// Some control structures walk their children from maps, hence sorting messages by location
+	// for the catalogs to be deterministic
+	slices.SortStableFunc(found, func(a, b extracted) int {
+		return cmp.Or(cmp.Compare(a.line, b.line), cmp.Compare(a.column, b.column), cmp.Compare(a.singular, b.singular))
+	})
+	for _, message := range found {
+		c.Add(message.singular, message.plural, Reference{File: file, Line: message.line})
+	}
+}
+
+type extracted struct {
+	singular string
+	plural   string
+	line     int
+	column   int
+}
+
+// extract returns the message of a trans block or of a call to a gettext function with literal strings
+func extract(node nodes.Node) (extracted, bool) {
+	switch n := node.(type) {
+	case *controlStructures.TransControlStructure:
+		return extracted{singular: n.Singular, plural: n.Plural, line: n.Position().Line, column: n.Position().Col}, true
+	case *nodes.Call:
+		name, ok := n.Func.(*nodes.Name)
+		if !ok {
+			return extracted{}, false
+		}
+		switch name.Name.Val {
+		case "_", "gettext":
+			if len(n.Args) > 0 {
+				if singular, ok := n.Args[0].(*nodes.String); ok {
+					return extracted{singular: singular.Val, line: singular.Location.Line, column: singular.Location.Col}, true
+				}
+			}
+		case "ngettext":
+			if len(n.Args) > 1 {
+				singular, singularOk := n.Args[0].(*nodes.String)
+				plural, pluralOk := n.Args[1].(*nodes.String)
+				if singularOk && pluralOk {
+					return extracted{singular: singular.Val, plural: plural.Val, line: singular.Location.Line, column: singular.Location.Col}, true
+				}
+			}
+		}
+	}
+	return extracted{}, false
+}
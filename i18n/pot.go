@@ -0,0 +1,54 @@
+package i18n
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var poReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+
+// poString quotes a string for a .po file, splitting it after its line breaks
+func poString(s string) string {
+	if !strings.Contains(strings.TrimSuffix(s, "\n"), "\n") {
+		return `"` + poReplacer.Replace(s) + `"`
+	}
+	lines := []string{`""`}
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			lines = append(lines, `"`+poReplacer.Replace(line)+`"`)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// WritePOT writes the catalog as a gettext .pot template, from which translators create the .po files of their locale
+func (c *Catalog) WritePOT(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	fmt.Fprintln(out, `msgid ""`)
+	fmt.Fprintln(out, `msgstr ""`)
+	fmt.Fprintln(out, `"MIME-Version: 1.0\n"`)
+	fmt.Fprintln(out, `"Content-Type: text/plain; charset=UTF-8\n"`)
+	fmt.Fprintln(out, `"Content-Transfer-Encoding: 8bit\n"`)
+	for _, message := range c.Messages {
+		fmt.Fprintln(out)
+		references := make([]string, 0, len(message.References))
+		for _, reference := range message.References {
+			references = append(references, fmt.Sprintf("%s:%d", reference.File, reference.Line))
+		}
+		fmt.Fprintf(out, "#: %s\n", strings.Join(references, " "))
+		if strings.Contains(message.Singular+message.Plural, "%(") {
+			fmt.Fprintln(out, "#, python-format")
+		}
+		fmt.Fprintf(out, "msgid %s\n", poString(message.Singular))
+		if message.Plural == "" {
+			fmt.Fprintln(out, `msgstr ""`)
+			continue
+		}
+		fmt.Fprintf(out, "msgid_plural %s\n", poString(message.Plural))
+		fmt.Fprintln(out, `msgstr[0] ""`)
+		fmt.Fprintln(out, `msgstr[1] ""`)
+	}
+	return out.Flush()
+}
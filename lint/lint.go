@@ -0,0 +1,370 @@
+// Package lint finds common mistakes in templates without rendering them, like unknown filters
+// or unused variables.
+package lint
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Severity tells whether a diagnostic prevents a template from rendering
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Rules reported by the linter
+const (
+	RuleSyntax               = "syntax"
+	RuleDuplicateBlock       = "duplicate-block"
+	RuleUnknownFilter        = "unknown-filter"
+	RuleUnknownTest          = "unknown-test"
+	RuleExtendsNotFirst      = "extends-not-first"
+	RuleUnusedMacro          = "unused-macro"
+	RuleUnusedSet            = "unused-set"
+	RuleShadowedLoopVariable = "shadowed-loop-variable"
+	RuleConstantDefault      = "constant-default"
+)
+
+// Diagnostic is a mistake found in a template
+type Diagnostic struct {
+	File     string   `json:"file"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Severity Severity `json:"severity"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s (%s)", d.File, d.Line, d.Column, d.Severity, d.Message, d.Rule)
+}
+
+// Linter lints the templates of a loader, using the filters, tests and control structures of an environment
+type Linter struct {
+	Config      *config.Config
+	Loader      loaders.Loader
+	Environment *exec.Environment
+}
+
+// New returns a linter of the templates of the loader
+func New(cfg *config.Config, loader loaders.Loader, environment *exec.Environment) *Linter {
+	return &Linter{
+		Config:      cfg,
+		Loader:      loader,
+		Environment: environment,
+	}
+}
+
+// Lint lints the templates of the loader with the given names, or all the templates it holds if it implements
+// loaders.Lister and no name is given. Diagnostics are sorted by file and position.
+//
+// Macros and variables set at the top level of a template are not reported as unused when they are imported by
+// one of the linted templates. Variables set in templates extending other templates or including them with
+// context are not reported either, since those can read them.
+func (l *Linter) Lint(names ...string) ([]Diagnostic, error) {
+	if len(names) == 0 {
+		lister, ok := l.Loader.(loaders.Lister)
+		if !ok {
+			return nil, fmt.Errorf("templates must be named since the loader can not list them")
+		}
+		var err error
+		if names, err = lister.List(); err != nil {
+			return nil, fmt.Errorf("failed to list templates: %s", err)
+		}
+	}
+
+	templates := make([]*template, 0, len(names))
+	imported := map[string][]string{}
+	for _, name := range names {
+		t, err := l.parse(name)
+		if err != nil {
+			return nil, err
+		}
+		t.check()
+		for _, i := range t.imports {
+			if identifier, err := l.Loader.Resolve(i.filename); err == nil {
+				imported[identifier] = append(imported[identifier], i.names...)
+			}
+		}
+		templates = append(templates, t)
+	}
+
+	var diagnostics []Diagnostic
+	for _, t := range templates {
+		identifier, _ := l.Loader.Resolve(t.name)
+		t.checkUnused(imported[identifier])
+		for _, diagnostic := range t.diagnostics {
+			// Syntax errors of extended templates are reported by each template extending them
+			if !slices.Contains(diagnostics, diagnostic) {
+				diagnostics = append(diagnostics, diagnostic)
+			}
+		}
+	}
+	slices.SortStableFunc(diagnostics, func(a, b Diagnostic) int {
+		return cmp.Or(cmp.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+	})
+	return diagnostics, nil
+}
+
+func (l *Linter) parse(name string) (*template, error) {
+	input, err := l.Loader.Read(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template '%s': %s", name, err)
+	}
+	source, err := io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template '%s': %s", name, err)
+	}
+
+	t := &template{
+		name:        name,
+		environment: l.Environment,
+		used:        map[string]bool{},
+		targets:     map[*nodes.Name]bool{},
+	}
+	t.root, err = parser.NewParser(name, tokens.LexAll(string(source), l.Config), l.Config, l.Loader, l.Environment.ControlStructures).ParseAll()
+
+	var syntaxErrors parser.ErrorList
+	var templateError *parser.TemplateError
+	switch {
+	case errors.As(err, &syntaxErrors):
+	case errors.As(err, &templateError):
+		syntaxErrors = parser.ErrorList{templateError}
+	case err != nil:
+		syntaxErrors = parser.ErrorList{{Template: name, Err: err}}
+	}
+	for _, syntaxError := range syntaxErrors {
+		rule := RuleSyntax
+		if errors.As(syntaxError, new(*controlStructures.DuplicateBlockError)) {
+			rule = RuleDuplicateBlock
+		}
+		t.diagnostics = append(t.diagnostics, Diagnostic{
+			File:     syntaxError.Template,
+			Line:     syntaxError.Line,
+			Column:   syntaxError.Column,
+			Severity: SeverityError,
+			Rule:     rule,
+			Message:  syntaxError.Err.Error(),
+		})
+	}
+	return t, nil
+}
+
+// template holds what is found while linting a template
+type template struct {
+	name        string
+	root        *nodes.Template
+	environment *exec.Environment
+	diagnostics []Diagnostic
+
+	// used are the names read by the template, targets the names assigned by set tags
+	used    map[string]bool
+	targets map[*nodes.Name]bool
+	// sets and macros are the definitions of the template, to be reported when unused
+	sets   []definition
+	macros []definition
+	// imports are the templates imported, with the names imported from them
+	imports []importing
+	// inherits tells whether the template includes or extends others, which can read its variables
+	inherits bool
+}
+
+type definition struct {
+	name     string
+	position *tokens.Token
+}
+
+type importing struct {
+	filename string
+	names    []string
+}
+
+func (t *template) report(position *tokens.Token, severity Severity, rule, format string, args ...any) {
+	diagnostic := Diagnostic{
+		File:     t.name,
+		Severity: severity,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	}
+	if position != nil {
+		diagnostic.Line = position.Line
+		diagnostic.Column = position.Col
+	}
+	t.diagnostics = append(t.diagnostics, diagnostic)
+}
+
+// check walks the template, reporting the mistakes found in nodes and collecting definitions and usages
+func (t *template) check() {
+	if t.root == nil {
+		return
+	}
+	// The first node of the template which is neither a comment nor whitespaces
+	var first nodes.Node
+	for _, node := range t.root.Nodes {
+		if _, comment := node.(*nodes.Comment); comment {
+			continue
+		}
+		if data, ok := node.(*nodes.Data); ok && strings.TrimSpace(data.Data.Val) == "" {
+			continue
+		}
+		first = node
+		break
+	}
+	nodes.Walk(&visitor{template: t, first: first}, t.root)
+}
+
+// checkUnused reports the macros and the variables set which are neither used nor imported by other templates
+func (t *template) checkUnused(imported []string) {
+	for _, macro := range t.macros {
+		if !t.used[macro.name] && !slices.Contains(imported, macro.name) && !slices.Contains(imported, "*") {
+			t.report(macro.position, SeverityWarning, RuleUnusedMacro, "macro '%s' is never used", macro.name)
+		}
+	}
+	if t.inherits {
+		return
+	}
+	for _, set := range t.sets {
+		if !t.used[set.name] && !slices.Contains(imported, set.name) {
+			t.report(set.position, SeverityWarning, RuleUnusedSet, "variable '%s' is set but never used", set.name)
+		}
+	}
+}
+
+// visitor walks the nodes of a template, knowing the variables of the loops enclosing them
+type visitor struct {
+	template *template
+	first    nodes.Node
+	loops    []string
+}
+
+var defaultFilters = []string{"default", "d"}
+
+func (v *visitor) Visit(node nodes.Node) (nodes.Visitor, error) {
+	t := v.template
+	switch n := node.(type) {
+	case *nodes.Name:
+		if !t.targets[n] {
+			t.used[n.Name.Val] = true
+		}
+	case *nodes.FilterCall:
+		if !t.environment.Filters.Exists(n.Name) {
+			t.report(n.Token, SeverityError, RuleUnknownFilter, "filter '%s' is not defined", n.Name)
+		}
+	case *nodes.TestCall:
+		if !t.environment.Tests.Exists(n.Name) {
+			t.report(n.Token, SeverityError, RuleUnknownTest, "test '%s' is not defined", n.Name)
+		}
+	case *nodes.FilteredExpression:
+		if constant(n.Expression) {
+			for _, filter := range n.Filters {
+				if slices.Contains(defaultFilters, filter.Name) {
+					t.report(filter.Token, SeverityWarning, RuleConstantDefault, "filter '%s' is applied to a constant, which is never undefined", filter.Name)
+				}
+			}
+		}
+	case *nodes.ControlStructureBlock:
+		return v.controlStructure(n), nil
+	}
+	return v, nil
+}
+
+func (v *visitor) controlStructure(block *nodes.ControlStructureBlock) nodes.Visitor {
+	t := v.template
+	switch cs := block.ControlStructure.(type) {
+	case *controlStructures.ExtendsControlStructure:
+		t.inherits = true
+		if nodes.Node(block) != v.first {
+			if cs.Filename() != "" {
+				t.report(block.Location, SeverityWarning, RuleExtendsNotFirst, "extends of '%s' is not the first tag of the template", cs.Filename())
+			} else {
+				t.report(block.Location, SeverityWarning, RuleExtendsNotFirst, "extends is not the first tag of the template")
+			}
+		}
+	case *controlStructures.IncludeControlStructure:
+		// Templates included without context can not read the variables of the template
+		t.inherits = t.inherits || cs.WithContext()
+	case *controlStructures.ImportControlStructure:
+		for _, filename := range filenames(cs.FilenameExpression()) {
+			t.imports = append(t.imports, importing{filename: filename, names: []string{"*"}})
+		}
+	case *controlStructures.FromImportControlStructure:
+		names := make([]string, 0, len(cs.Aliases))
+		for _, alias := range cs.Aliases {
+			names = append(names, cs.As[alias])
+		}
+		for _, filename := range filenames(cs.FilenameExpression) {
+			t.imports = append(t.imports, importing{filename: filename, names: names})
+		}
+	case *controlStructures.MacroControlStructure:
+		t.macros = append(t.macros, definition{name: cs.Name, position: block.Location})
+	case *controlStructures.SetControlStructure:
+		if target, ok := cs.Target().(*nodes.Name); ok {
+			t.targets[target] = true
+			t.sets = append(t.sets, definition{name: target.Name.Val, position: target.Name})
+		}
+	case *controlStructures.ForControlStructure:
+		loops := slices.Clone(v.loops)
+		for _, variable := range []string{cs.Key, cs.Value} {
+			if variable == "" {
+				continue
+			}
+			if slices.Contains(v.loops, variable) {
+				t.report(block.Location, SeverityWarning, RuleShadowedLoopVariable, "loop variable '%s' shadows the one of an enclosing loop", variable)
+			}
+			loops = append(loops, variable)
+		}
+		return &visitor{template: t, first: v.first, loops: loops}
+	}
+	return v
+}
+
+// filenames returns the names of the templates given as a string literal or as a list of them,
+// since any of those may be imported
+func filenames(expression nodes.Expression) []string {
+	var expressions []nodes.Expression
+	switch n := expression.(type) {
+	case *nodes.String:
+		return []string{n.Val}
+	case *nodes.List:
+		expressions = n.Val
+	case *nodes.Tuple:
+		expressions = n.Val
+	}
+	var names []string
+	for _, expression := range expressions {
+		if filename, ok := expression.(*nodes.String); ok {
+			names = append(names, filename.Val)
+		}
+	}
+	return names
+}
+
+// constant tells whether the expression is made of literals only
+func constant(expression nodes.Expression) bool {
+	switch n := expression.(type) {
+	case *nodes.String, *nodes.Integer, *nodes.Float, *nodes.Bool, *nodes.None:
+		return true
+	case *nodes.List:
+		return !slices.ContainsFunc(n.Val, func(e nodes.Expression) bool { return !constant(e) })
+	case *nodes.Tuple:
+		return !slices.ContainsFunc(n.Val, func(e nodes.Expression) bool { return !constant(e) })
+	case *nodes.Dict:
+		return !slices.ContainsFunc(n.Pairs, func(p *nodes.Pair) bool { return !constant(p.Key) || !constant(p.Value) })
+	}
+	return false
+}
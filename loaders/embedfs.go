@@ -4,6 +4,7 @@ import (
 	"embed"
 	"fmt"
 	"io"
+	"io/fs"
 	"path/filepath"
 	"strings"
 )
@@ -35,6 +36,40 @@ func (e *EmbedFSLoader) Read(path string) (io.Reader, error) {
 	return e.fs.Open(strings.TrimLeft(resolved, "/"))
 }
 
+// Version returns a hash of the content of the file at the given path
+func (e *EmbedFSLoader) Version(path string) (string, error) {
+	resolved, err := e.Resolve(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve name '%s': %w", path, err)
+	}
+
+	content, err := e.fs.ReadFile(strings.TrimLeft(resolved, "/"))
+	if err != nil {
+		return "", err
+	}
+	return contentVersion(content), nil
+}
+
+// List returns the absolute paths of all the files under the root of the loader
+func (e *EmbedFSLoader) List() ([]string, error) {
+	root := strings.Trim(e.root, "/")
+	if root == "" {
+		root = "."
+	}
+	var paths []string
+	err := fs.WalkDir(e.fs, root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		paths = append(paths, "/"+path)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
 func (e *EmbedFSLoader) Resolve(path string) (string, error) {
 	if strings.HasPrefix(path, "/") {
 		return path, nil
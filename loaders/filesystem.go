@@ -2,7 +2,9 @@ package loaders
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -82,6 +84,43 @@ func (f *fileSystemLoader) Read(path string) (io.Reader, error) {
 	return bytes.NewReader(buf), nil
 }
 
+// Version returns the modification time and size of the file at the given path
+func (f *fileSystemLoader) Version(path string) (string, error) {
+	realPath, err := f.Resolve(path)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(realPath)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+// List returns the slash separated paths of all the files under the base directory, relative to it
+func (f *fileSystemLoader) List() ([]string, error) {
+	root, err := f.Resolve(".")
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(relative))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
 // Path resolves a filename relative to the base directory. Absolute paths are allowed.
 // When there's no base dir set, the absolute path to the filename
 // will be calculated based on either the provided base directory (which
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/nikolalohinski/gonja/v2/loaders"
 
@@ -236,4 +237,53 @@ var _ = Context("filesystem", func() {
 			})
 		})
 	})
+	Context("Version", func() {
+		var (
+			file = new(os.File)
+
+			returnedVersion = new(string)
+		)
+		BeforeEach(func() {
+			file = MustReturn(os.CreateTemp("", "*.filesystem"))
+			MustReturn(file.WriteString("content"))
+		})
+		AfterEach(func() {
+			os.Remove(file.Name())
+		})
+		JustBeforeEach(func() {
+			*returnedVersion, *returnedErr = loader.(loaders.Versioner).Version(file.Name())
+		})
+		It("should return a version based on the modification time", func() {
+			By("not returning an error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the same version while the file is not modified")
+			Expect(loaders.IsUpToDate(loader, file.Name(), *returnedVersion)).To(BeTrue())
+			By("returning another version once the file is modified")
+			later := time.Now().Add(time.Hour)
+			Expect(os.Chtimes(file.Name(), later, later)).To(Succeed())
+			Expect(loaders.IsUpToDate(loader, file.Name(), *returnedVersion)).To(BeFalse())
+		})
+		It("should not be up to date once the file is removed", func() {
+			Expect(os.Remove(file.Name())).To(Succeed())
+			Expect(loaders.IsUpToDate(loader, file.Name(), *returnedVersion)).To(BeFalse())
+		})
+	})
+	Context("List", func() {
+		var returnedPaths = new([]string)
+		BeforeEach(func() {
+			*root = GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(*root, "emails", "welcome"), 0o755)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(*root, "base.html"), []byte("base"), 0o644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(*root, "emails", "welcome", "body.txt"), []byte("body"), 0o644)).To(Succeed())
+		})
+		JustBeforeEach(func() {
+			*returnedPaths, *returnedErr = loader.(loaders.Lister).List()
+		})
+		It("should return the paths relative to the root", func() {
+			By("not returning an error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the slash separated paths of the files")
+			Expect(*returnedPaths).To(Equal([]string{"base.html", "emails/welcome/body.txt"}))
+		})
+	})
 })
@@ -2,6 +2,8 @@
 package loaders
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 )
 
@@ -16,3 +18,35 @@ type Loader interface {
 	// Create a new loader from the current one, relatively to the given path
 	Inherit(from string) (Loader, error)
 }
+
+// Versioner is optionally implemented by loaders able to tell when a template changes. The returned
+// version is opaque and changes whenever the source of the template does, like its modification time
+// or a hash of its content, which allows cached templates to be checked for being up to date.
+type Versioner interface {
+	// Version returns the current version of the template at the given path
+	Version(path string) (string, error)
+}
+
+// Lister is optionally implemented by loaders able to enumerate the templates they hold, which lets
+// tools like message extractors walk all of them.
+type Lister interface {
+	// List returns the paths of all the templates under the root of the loader, sorted,
+	// which can be given to Read as is
+	List() ([]string, error)
+}
+
+// IsUpToDate returns true if the template at the given path still has the given version.
+// Templates of loaders which do not implement Versioner are always considered up to date.
+func IsUpToDate(loader Loader, path string, version string) bool {
+	versioner, ok := loader.(Versioner)
+	if !ok {
+		return true
+	}
+	current, err := versioner.Version(path)
+	return err == nil && current == version
+}
+
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -77,6 +78,32 @@ func (m *memoryLoader) Read(path string) (io.Reader, error) {
 	return strings.NewReader(data), nil
 }
 
+// Version returns a hash of the content of the template at the given path
+func (m *memoryLoader) Version(path string) (string, error) {
+	resolved, err := m.Resolve(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve name '%s': %s", path, err)
+	}
+
+	data, ok := m.content[resolved]
+	if !ok {
+		return "", fmt.Errorf("unknown path: '%s'", resolved)
+	}
+	return contentVersion([]byte(data)), nil
+}
+
+// List returns the absolute paths of all the templates under the root of the loader
+func (m *memoryLoader) List() ([]string, error) {
+	var paths []string
+	for path := range m.content {
+		if strings.HasPrefix(path, m.root) {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
 func (m *memoryLoader) Resolve(path string) (string, error) {
 	if strings.HasPrefix(path, "/") {
 		return path, nil
@@ -131,4 +131,46 @@ var _ = Context("memory", func() {
 			})
 		})
 	})
+	Context("Version", func() {
+		var (
+			path = new(string)
+
+			returnedVersion = new(string)
+		)
+		BeforeEach(func() {
+			*path = "/home/of"
+		})
+		JustBeforeEach(func() {
+			*returnedVersion, *returnedErr = loader.(loaders.Versioner).Version(*path)
+		})
+		It("should return a stable version", func() {
+			By("not returning an error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the same version while the content does not change")
+			Expect(loaders.IsUpToDate(loader, *path, *returnedVersion)).To(BeTrue())
+			By("returning another version once the content changes")
+			(*content)["/home/of"] = "changed"
+			Expect(loaders.IsUpToDate(loader, *path, *returnedVersion)).To(BeFalse())
+		})
+		Context("when path is unknown", func() {
+			BeforeEach(func() {
+				*path = "/unknown"
+			})
+			It("should return an error", func() {
+				Expect(*returnedErr).ToNot(BeNil())
+			})
+		})
+	})
+	Context("List", func() {
+		var returnedPaths = new([]string)
+		JustBeforeEach(func() {
+			*returnedPaths, *returnedErr = loader.(loaders.Lister).List()
+		})
+		It("should return all the paths sorted", func() {
+			By("not returning an error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the paths which can be read")
+			Expect(*returnedPaths).To(Equal([]string{"/home/of", "/home/sweet"}))
+		})
+	})
 })
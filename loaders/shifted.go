@@ -70,6 +70,22 @@ func (f *shiftedLoader) Read(identifier string) (io.Reader, error) {
 	return f.loader.Read(identifier)
 }
 
+// Version returns a hash of the root template passed as code, and delegates to the
+// sub-loader for other paths. Paths of sub-loaders without versions have an empty version.
+func (f *shiftedLoader) Version(identifier string) (string, error) {
+	resolvedID, err := f.Resolve(identifier)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve '%s': %s", identifier, err)
+	}
+	if resolvedID == f.rootID {
+		return contentVersion(f.rootContent), nil
+	}
+	if versioner, ok := f.loader.(Versioner); ok {
+		return versioner.Version(identifier)
+	}
+	return "", nil
+}
+
 // Resolve the given identifier in the current context
 func (f *shiftedLoader) Resolve(identifier string) (string, error) {
 	if identifier == f.rootID {
@@ -138,4 +138,19 @@ var _ = Context("shifted", func() {
 			})
 		})
 	})
+	Context("Version", func() {
+		It("should version the root content and delegate other paths", func() {
+			versioner := loader.(loaders.Versioner)
+			By("returning a stable version for the root")
+			rootVersion, err := versioner.Version(*rootID)
+			Expect(err).To(BeNil())
+			Expect(loaders.IsUpToDate(loader, *rootID, rootVersion)).To(BeTrue())
+			By("returning the version of the sub-loader for other paths")
+			subVersion, err := versioner.Version("/foo")
+			Expect(err).To(BeNil())
+			expected, err := (*subLoader).(loaders.Versioner).Version("/foo")
+			Expect(err).To(BeNil())
+			Expect(subVersion).To(Equal(expected))
+		})
+	})
 })
@@ -0,0 +1,265 @@
+// Package meta provides the static analysis of parsed templates, telling which variables they read from
+// the context and which other templates they depend on, without rendering them.
+package meta
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+)
+
+// Reference is a template extended, included or imported by another one
+type Reference struct {
+	// Kind is either "extends", "include" or "import"
+	Kind string
+	// Name is the identifier of the referenced template, or is empty when it is only known at render time
+	Name string
+	// Position is the location of the tag referencing the template
+	Position *tokens.Token
+}
+
+// Analysis holds what a template reads from the context, assigns and depends on
+type Analysis struct {
+	// Undeclared are the variables read from the context, sorted by name
+	Undeclared []string
+	// Assigned are the names the template assigns at its top level with set, macro and import tags, sorted by name
+	Assigned []string
+	// Templates are the templates referenced by the template, in order of appearance
+	Templates []Reference
+}
+
+// Analyze walks a parsed template and returns its analysis. Variables provided by the renderer, like
+// self, loop within for loops, caller within macros and super within blocks, are not reported as undeclared,
+// but global functions and variables of the environment are. The templates it extends are not analyzed.
+//
+// Control structures are walked with nodes.Walk, the names assigned within them being local to them, unless
+// they are built-in control structures known to declare names, like for loops or macros.
+func Analyze(root *nodes.Template) *Analysis {
+	a := &analyzer{
+		root:       root,
+		undeclared: map[string]bool{},
+	}
+	builtins := &scope{names: map[string]bool{"self": true}}
+	top := builtins.child()
+	a.walk(top, root)
+
+	return &Analysis{
+		Undeclared: slices.Sorted(maps.Keys(a.undeclared)),
+		Assigned:   slices.Sorted(maps.Keys(top.names)),
+		Templates:  a.templates,
+	}
+}
+
+// FindUndeclaredVariables returns the variables the template reads from the context, like Jinja's function of the same name
+func FindUndeclaredVariables(root *nodes.Template) []string {
+	return Analyze(root).Undeclared
+}
+
+// FindAssignedNames returns the names the template assigns at its top level
+func FindAssignedNames(root *nodes.Template) []string {
+	return Analyze(root).Assigned
+}
+
+// FindReferencedTemplates returns the templates the template extends, includes or imports
+func FindReferencedTemplates(root *nodes.Template) []Reference {
+	return Analyze(root).Templates
+}
+
+// scope holds the names declared by a template, a loop, a macro or any other construct introducing variables
+type scope struct {
+	parent *scope
+	names  map[string]bool
+}
+
+func (s *scope) child(names ...string) *scope {
+	child := &scope{parent: s, names: map[string]bool{}}
+	for _, name := range names {
+		child.declare(name)
+	}
+	return child
+}
+
+func (s *scope) declare(name string) {
+	if name != "" {
+		s.names[name] = true
+	}
+}
+
+func (s *scope) declared(name string) bool {
+	for ; s != nil; s = s.parent {
+		if s.names[name] {
+			return true
+		}
+	}
+	return false
+}
+
+type analyzer struct {
+	root       *nodes.Template
+	undeclared map[string]bool
+	templates  []Reference
+}
+
+// visitor walks nodes within a scope
+type visitor struct {
+	analyzer *analyzer
+	scope    *scope
+}
+
+// walk analyzes the given nodes within the scope, skipping nil ones
+func (a *analyzer) walk(s *scope, list ...nodes.Node) {
+	for _, node := range list {
+		if node == nil || reflect.ValueOf(node).IsNil() {
+			continue
+		}
+		// The visitor never fails
+		_ = nodes.Walk(&visitor{analyzer: a, scope: s}, node)
+	}
+}
+
+func (v *visitor) Visit(node nodes.Node) (nodes.Visitor, error) {
+	switch n := node.(type) {
+	case *nodes.Name:
+		if !v.scope.declared(n.Name.Val) {
+			v.analyzer.undeclared[n.Name.Val] = true
+		}
+	case *nodes.ControlStructureBlock:
+		if !v.analyzer.controlStructure(v.scope, n) {
+			// The children of other control structures are walked in a scope of their own
+			return &visitor{analyzer: v.analyzer, scope: v.scope.child()}, nil
+		}
+		return nil, nil
+	}
+	return v, nil
+}
+
+// controlStructure analyzes the built-in control structures declaring names or referencing templates,
+// telling whether it did
+func (a *analyzer) controlStructure(s *scope, block *nodes.ControlStructureBlock) bool {
+	switch cs := block.ControlStructure.(type) {
+	case *controlStructures.IfControlStructure:
+		a.expressions(s, cs.Conditions...)
+		branches := make([]*scope, 0, len(cs.Wrappers))
+		for _, wrapper := range cs.Wrappers {
+			branch := s.child()
+			a.walk(branch, wrapper)
+			branches = append(branches, branch)
+		}
+		// Names assigned in every branch of a condition having an else branch are assigned afterwards
+		if len(cs.Wrappers) > len(cs.Conditions) {
+			for name := range branches[0].names {
+				if !slices.ContainsFunc(branches[1:], func(branch *scope) bool { return !branch.names[name] }) {
+					s.declare(name)
+				}
+			}
+		}
+	case *controlStructures.ForControlStructure:
+		a.expressions(s, cs.ObjectEvaluator)
+		body := s.child(cs.Key, cs.Value, "loop")
+		a.expressions(body, cs.IfCondition)
+		a.walk(body, cs.BodyWrapper)
+		a.walk(s.child(), cs.EmptyWrapper)
+	case *controlStructures.SetControlStructure:
+		expression, condition, alternative := cs.Expression()
+		a.expressions(s, expression, condition, alternative)
+		switch target := cs.Target().(type) {
+		case *nodes.Name:
+			s.declare(target.Name.Val)
+		case *nodes.GetAttribute:
+			a.expressions(s, target.Node)
+		case *nodes.GetItem:
+			a.expressions(s, target.Node, target.Arg)
+		default:
+			a.expressions(s, target)
+		}
+	case *controlStructures.WithControlStructure:
+		body := s.child()
+		pairs := cs.Pairs()
+		for _, name := range cs.Names() {
+			a.expressions(s, pairs[name])
+			body.declare(name)
+		}
+		a.walk(body, cs.Body())
+	case *controlStructures.MacroControlStructure:
+		a.macro(s, cs.Macro, exec.CallerKeyword)
+		s.declare(cs.Name)
+	case *controlStructures.CallControlStructure:
+		a.expressions(s, cs.Call())
+		a.macro(s, cs.Caller())
+	case *controlStructures.BlockControlStructure:
+		a.walk(s.child("super"), a.root.Blocks[cs.Name()])
+	case *controlStructures.ExtendsControlStructure:
+		expression, condition, alternative := cs.Expression()
+		if expression == nil {
+			a.reference("extends", block, &nodes.String{Location: block.Location, Val: cs.Filename()})
+			break
+		}
+		a.expressions(s, expression, condition)
+		a.reference("extends", block, expression)
+		if alternative != nil {
+			a.expressions(s, alternative)
+			a.reference("extends", block, alternative)
+		}
+	case *controlStructures.IncludeControlStructure:
+		a.walk(s.child(), cs)
+		a.reference("include", block, cs.FilenameExpression())
+	case *controlStructures.ImportControlStructure:
+		a.expressions(s, cs.FilenameExpression())
+		a.reference("import", block, cs.FilenameExpression())
+		s.declare(cs.As())
+	case *controlStructures.FromImportControlStructure:
+		a.expressions(s, cs.FilenameExpression)
+		a.reference("import", block, cs.FilenameExpression)
+		for _, alias := range cs.Aliases {
+			s.declare(alias)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// macro analyzes a macro whose default values are evaluated where it is defined,
+// and whose body sees its arguments along with the given names
+func (a *analyzer) macro(s *scope, macro *nodes.Macro, names ...string) {
+	body := s.child(names...)
+	for _, kwarg := range macro.Kwargs {
+		a.expressions(s, kwarg.Value)
+		if key, ok := kwarg.Key.(*nodes.String); ok {
+			body.declare(key.Val)
+		}
+	}
+	a.walk(body, macro.Wrapper)
+}
+
+// reference records the templates referenced by a filename expression, the names of the templates
+// being only known when it is a string or a list of strings
+func (a *analyzer) reference(kind string, block *nodes.ControlStructureBlock, filename nodes.Expression) {
+	var names []nodes.Expression
+	switch n := filename.(type) {
+	case *nodes.List:
+		names = n.Val
+	case *nodes.Tuple:
+		names = n.Val
+	default:
+		names = []nodes.Expression{filename}
+	}
+	for _, name := range names {
+		reference := Reference{Kind: kind, Position: block.Location}
+		if literal, ok := name.(*nodes.String); ok {
+			reference.Name = literal.Val
+		}
+		a.templates = append(a.templates, reference)
+	}
+}
+
+func (a *analyzer) expressions(s *scope, expressions ...nodes.Expression) {
+	for _, expression := range expressions {
+		a.walk(s, expression)
+	}
+}
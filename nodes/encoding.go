@@ -0,0 +1,470 @@
+package nodes
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// encodingVersion is bumped whenever the serialized form of templates changes in an incompatible way
+const encodingVersion = 1
+
+// Keys used by the serialized form of templates next to the names of the fields
+const (
+	keyID    = "@id"
+	keyRef   = "@ref"
+	keyType  = "@type"
+	keyValue = "@value"
+	keyError = "@error"
+)
+
+var (
+	registry = struct {
+		lock   sync.RWMutex
+		types  map[string]reflect.Type
+		byType map[reflect.Type]string
+	}{
+		types:  map[string]reflect.Type{},
+		byType: map[reflect.Type]string{},
+	}
+	errorType = reflect.TypeFor[error]()
+)
+
+func init() {
+	for _, node := range []Node{
+		&Template{}, &Data{}, &Comment{}, &Output{}, &FilteredExpression{}, &TestExpression{},
+		&String{}, &Integer{}, &Float{}, &Bool{}, &Name{}, &None{}, &List{}, &Tuple{}, &Dict{},
+		&Pair{}, &Variable{}, &Call{}, &GetItem{}, &GetSlice{}, &GetAttribute{}, &Negation{},
+		&UnaryExpression{}, &BinaryExpression{}, &BinOperator{}, &ControlStructureBlock{},
+		&Wrapper{}, &Macro{}, &Error{},
+	} {
+		if err := RegisterType(node); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Encodable is implemented by registered nodes holding unexported fields, like control structures keeping
+// their arguments private. EncodedFields returns pointers to these fields by name, which are read by MarshalJSON
+// and set by UnmarshalJSON along with the exported fields.
+type Encodable interface {
+	EncodedFields() map[string]any
+}
+
+// RegisterType registers the concrete type of the given node, like a custom control structure,
+// so that templates containing it can be serialized with MarshalJSON and loaded back with UnmarshalJSON.
+// Fields of registered types must not hold functions or channels, and unexported ones must be exposed
+// by implementing Encodable.
+func RegisterType(node Node) error {
+	t := reflect.TypeOf(node)
+	name := t.String()
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	if existing, ok := registry.types[name]; ok {
+		if existing == t {
+			return nil
+		}
+		return fmt.Errorf("another node type is already registered as '%s'", name)
+	}
+	registry.types[name] = t
+	registry.byType[t] = name
+	return nil
+}
+
+func registeredName(t reflect.Type) (string, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	name, ok := registry.byType[t]
+	return name, ok
+}
+
+func registeredType(name string) (reflect.Type, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	t, ok := registry.types[name]
+	return t, ok
+}
+
+type serializedTemplate struct {
+	Version  int             `json:"version"`
+	Template json.RawMessage `json:"template"`
+}
+
+// MarshalJSON serializes the template, including the templates it extends,
+// so that it can be loaded back without lexing and parsing its source again
+func (t *Template) MarshalJSON() ([]byte, error) {
+	e := &nodeEncoder{ids: map[pointer]int{}}
+	// The template is encoded through its fields to avoid calling MarshalJSON recursively
+	encoded, err := e.encode(reflect.ValueOf(*t))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template '%s': %s", t.Identifier, err)
+	}
+	template, err := json.Marshal(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode template '%s': %s", t.Identifier, err)
+	}
+	return json.Marshal(serializedTemplate{
+		Version:  encodingVersion,
+		Template: template,
+	})
+}
+
+// UnmarshalJSON loads a template serialized with MarshalJSON
+func (t *Template) UnmarshalJSON(data []byte) error {
+	serialized := serializedTemplate{}
+	if err := json.Unmarshal(data, &serialized); err != nil {
+		return fmt.Errorf("failed to decode template: %s", err)
+	}
+	if serialized.Version != encodingVersion {
+		return fmt.Errorf("unsupported template encoding version %d, expected %d", serialized.Version, encodingVersion)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(serialized.Template))
+	decoder.UseNumber()
+	var encoded any
+	if err := decoder.Decode(&encoded); err != nil {
+		return fmt.Errorf("failed to decode template: %s", err)
+	}
+	d := &nodeDecoder{ids: map[int]reflect.Value{}}
+	template := Template{}
+	if err := d.decode(encoded, reflect.ValueOf(&template).Elem()); err != nil {
+		return fmt.Errorf("failed to decode template: %s", err)
+	}
+	*t = template
+	return nil
+}
+
+type pointer struct {
+	t       reflect.Type
+	address uintptr
+}
+
+// nodeEncoder converts nodes into values which can be marshalled to JSON, keeping
+// track of pointers so that nodes referenced multiple times are only encoded once
+type nodeEncoder struct {
+	ids map[pointer]int
+}
+
+func (e *nodeEncoder) encode(v reflect.Value) (any, error) {
+	switch v.Kind() {
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil, nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			item, err := e.encode(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		items := map[string]any{}
+		for _, key := range sortedKeys(v) {
+			item, err := e.encode(v.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+			items[key.String()] = item
+		}
+		return items, nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil, nil
+		}
+		key := pointer{t: v.Type(), address: v.Pointer()}
+		if id, ok := e.ids[key]; ok {
+			return map[string]any{keyRef: id}, nil
+		}
+		id := len(e.ids) + 1
+		e.ids[key] = id
+		value, err := e.encode(v.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if fields, ok := value.(map[string]any); ok && v.Elem().Kind() == reflect.Struct {
+			fields[keyID] = id
+			return fields, nil
+		}
+		return map[string]any{keyID: id, keyValue: value}, nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+		if v.Type() == errorType {
+			return map[string]any{keyError: v.Interface().(error).Error()}, nil
+		}
+		concrete := v.Elem()
+		name, ok := registeredName(concrete.Type())
+		if !ok {
+			return nil, fmt.Errorf("type %s is not registered, see nodes.RegisterType", concrete.Type())
+		}
+		value, err := e.encode(concrete)
+		if err != nil {
+			return nil, err
+		}
+		if fields, ok := value.(map[string]any); ok && (concrete.Kind() == reflect.Pointer || concrete.Kind() == reflect.Struct) {
+			fields[keyType] = name
+			return fields, nil
+		}
+		return map[string]any{keyType: name, keyValue: value}, nil
+	case reflect.Struct:
+		fields := map[string]any{}
+		encoded, err := structFields(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range encoded {
+			if field.value.IsZero() {
+				continue
+			}
+			value, err := e.encode(field.value)
+			if err != nil {
+				return nil, fmt.Errorf("field '%s' of %s: %s", field.name, v.Type(), err)
+			}
+			fields[field.name] = value
+		}
+		return fields, nil
+	default:
+		return nil, fmt.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// nodeDecoder rebuilds nodes from the values produced by nodeEncoder
+type nodeDecoder struct {
+	ids map[int]reflect.Value
+}
+
+func (d *nodeDecoder) decode(data any, target reflect.Value) error {
+	if data == nil {
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Bool:
+		value, ok := data.(bool)
+		if !ok {
+			return fmt.Errorf("expected a boolean but got %v", data)
+		}
+		target.SetBool(value)
+	case reflect.String:
+		value, ok := data.(string)
+		if !ok {
+			return fmt.Errorf("expected a string but got %v", data)
+		}
+		target.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := asNumber(data).Int64()
+		if err != nil {
+			return err
+		}
+		target.SetInt(number)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, err := asNumber(data).Int64()
+		if err != nil {
+			return err
+		}
+		target.SetUint(uint64(number))
+	case reflect.Float32, reflect.Float64:
+		number, err := asNumber(data).Float64()
+		if err != nil {
+			return err
+		}
+		target.SetFloat(number)
+	case reflect.Slice, reflect.Array:
+		items, ok := data.([]any)
+		if !ok {
+			return fmt.Errorf("expected a list but got %v", data)
+		}
+		if target.Kind() == reflect.Slice {
+			target.Set(reflect.MakeSlice(target.Type(), len(items), len(items)))
+		} else if len(items) != target.Len() {
+			return fmt.Errorf("expected %d items but got %d", target.Len(), len(items))
+		}
+		for i, item := range items {
+			if err := d.decode(item, target.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		items, ok := data.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object but got %v", data)
+		}
+		target.Set(reflect.MakeMapWithSize(target.Type(), len(items)))
+		keys := make([]string, 0, len(items))
+		for key := range items {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			value := reflect.New(target.Type().Elem()).Elem()
+			if err := d.decode(items[key], value); err != nil {
+				return err
+			}
+			target.SetMapIndex(reflect.ValueOf(key).Convert(target.Type().Key()), value)
+		}
+	case reflect.Pointer:
+		fields, ok := data.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object but got %v", data)
+		}
+		if ref, ok := fields[keyRef]; ok {
+			id, err := asNumber(ref).Int64()
+			if err != nil {
+				return err
+			}
+			value, ok := d.ids[int(id)]
+			if !ok {
+				return fmt.Errorf("unknown reference %d", id)
+			}
+			if value.Type() != target.Type() {
+				return fmt.Errorf("reference %d is a %s and not a %s", id, value.Type(), target.Type())
+			}
+			target.Set(value)
+			return nil
+		}
+		id, err := asNumber(fields[keyID]).Int64()
+		if err != nil {
+			return fmt.Errorf("missing identifier: %s", err)
+		}
+		value := reflect.New(target.Type().Elem())
+		d.ids[int(id)] = value
+		target.Set(value)
+		if inner, ok := fields[keyValue]; ok {
+			return d.decode(inner, value.Elem())
+		}
+		return d.decode(fields, value.Elem())
+	case reflect.Interface:
+		fields, ok := data.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object but got %v", data)
+		}
+		if message, ok := fields[keyError]; ok {
+			target.Set(reflect.ValueOf(errors.New(fmt.Sprint(message))))
+			return nil
+		}
+		name, _ := fields[keyType].(string)
+		t, ok := registeredType(name)
+		if !ok {
+			return fmt.Errorf("type '%s' is not registered, see nodes.RegisterType", name)
+		}
+		if !t.AssignableTo(target.Type()) {
+			return fmt.Errorf("type '%s' can not be used as %s", name, target.Type())
+		}
+		value := reflect.New(t).Elem()
+		var err error
+		if t.Kind() == reflect.Pointer || t.Kind() == reflect.Struct {
+			err = d.decode(fields, value)
+		} else {
+			err = d.decode(fields[keyValue], value)
+		}
+		if err != nil {
+			return err
+		}
+		target.Set(value)
+	case reflect.Struct:
+		fields, ok := data.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object but got %v", data)
+		}
+		decoded, err := structFields(target)
+		if err != nil {
+			return err
+		}
+		for _, field := range decoded {
+			if err := d.decode(fields[field.name], field.value); err != nil {
+				return fmt.Errorf("field '%s' of %s: %s", field.name, target.Type(), err)
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %s", target.Type())
+	}
+	return nil
+}
+
+type structField struct {
+	name  string
+	value reflect.Value
+}
+
+// structFields returns the fields of the given struct to encode or decode, being its exported fields followed
+// by the ones exposed by EncodedFields sorted by name if it implements Encodable, so that references between
+// nodes are always met in the same order. Structs must be addressable to implement Encodable, which is always
+// the case of the ones referenced by pointers like nodes.
+func structFields(v reflect.Value) ([]structField, error) {
+	var fields []structField
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).IsExported() {
+			fields = append(fields, structField{name: v.Type().Field(i).Name, value: v.Field(i)})
+		}
+	}
+	var encodable Encodable
+	if v.CanAddr() && v.Addr().CanInterface() {
+		encodable, _ = v.Addr().Interface().(Encodable)
+	}
+	if encodable != nil {
+		encoded := encodable.EncodedFields()
+		names := make([]string, 0, len(encoded))
+		for name := range encoded {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			pointer := reflect.ValueOf(encoded[name])
+			if pointer.Kind() != reflect.Pointer || pointer.IsNil() {
+				return nil, fmt.Errorf("field '%s' of %s must be given as a pointer by EncodedFields", name, v.Type())
+			}
+			fields = append(fields, structField{name: name, value: pointer.Elem()})
+		}
+		return fields, nil
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() && !v.Field(i).IsZero() {
+			return nil, fmt.Errorf("unexported field '%s' of %s must be exposed by implementing nodes.Encodable", v.Type().Field(i).Name, v.Type())
+		}
+	}
+	return fields, nil
+}
+
+func asNumber(data any) json.Number {
+	switch n := data.(type) {
+	case json.Number:
+		return n
+	default:
+		return json.Number(fmt.Sprint(data))
+	}
+}
+
+func sortedKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	slices.SortFunc(keys, func(a, b reflect.Value) int {
+		if a.String() < b.String() {
+			return -1
+		} else if a.String() > b.String() {
+			return 1
+		}
+		return 0
+	})
+	return keys
+}
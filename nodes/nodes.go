@@ -71,6 +71,17 @@ func (t *Template) GetBlocks(name string) []*Wrapper {
 	return blocks
 }
 
+// GetBlockTemplates returns the templates defining the named block, in the same order as GetBlocks
+func (t *Template) GetBlockTemplates(name string) []*Template {
+	var templates []*Template
+	for current := t; current != nil; current = current.Parent {
+		if _, exists := current.Blocks[name]; exists {
+			templates = append(templates, current)
+		}
+	}
+	return templates
+}
+
 type Trim struct {
 	Left  bool
 	Right bool
@@ -90,6 +101,27 @@ func (d *Data) String() string {
 	return fmt.Sprintf("data(%s)", u.Ellipsis(d.Data.Val, 20))
 }
 
+// Text returns the data as rendered, once whitespace control is applied
+func (d *Data) Text() string {
+	output := d.Data.Val
+	if d.RemoveFirstLineReturn {
+		output = strings.TrimPrefix(output, "\r\n")
+		output = strings.TrimPrefix(output, "\n")
+	}
+	if d.Trim.Left {
+		output = strings.TrimLeft(output, " \r\n\t")
+	}
+	if d.Trim.Right {
+		output = strings.TrimRight(output, " \r\n\t")
+	}
+	if d.RemoveTrailingWhiteSpaceFromLastLine {
+		lines := strings.Split(output, "\n")
+		lines = append(lines[0:len(lines)-1], strings.TrimRight(lines[len(lines)-1], " \n\t\r"))
+		output = strings.Join(lines, "\n")
+	}
+	return output
+}
+
 // A Comment node represents a single {# #} comment.
 type Comment struct {
 	Start *tokens.Token // Opening token
@@ -146,6 +178,11 @@ type FilterCall struct {
 	// filterFunc FilterFunction
 }
 
+func (fc *FilterCall) Position() *tokens.Token { return fc.Token }
+func (fc *FilterCall) String() string {
+	return fmt.Sprintf("filter(%s)", fc.Name)
+}
+
 type TestExpression struct {
 	Expression Expression
 	Test       *TestCall
@@ -168,6 +205,7 @@ type TestCall struct {
 	// testFunc TestFunction
 }
 
+func (tc *TestCall) Position() *tokens.Token { return tc.Token }
 func (tc *TestCall) String() string {
 	return fmt.Sprintf("test(%s)", tc.Name)
 }
@@ -397,6 +435,7 @@ type ControlStructureBlock struct {
 	Location         *tokens.Token
 	Name             string
 	ControlStructure ControlStructure
+	End              *tokens.Token // Closing token
 }
 
 func (s ControlStructureBlock) Position() *tokens.Token { return s.Location }
@@ -410,6 +449,9 @@ type Wrapper struct {
 	EndTag   string
 	Trim     *Trim
 	LStrip   bool
+	// EndTagBegin and EndTagEnd are the opening and closing tokens of the end tag
+	EndTagBegin *tokens.Token
+	EndTagEnd   *tokens.Token
 }
 
 func (w Wrapper) Position() *tokens.Token { return w.Location }
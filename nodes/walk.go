@@ -1,6 +1,10 @@
 package nodes
 
 import (
+	"maps"
+	"reflect"
+	"slices"
+
 	"github.com/pkg/errors"
 )
 
@@ -8,6 +12,22 @@ type Visitor interface {
 	Visit(node Node) (Visitor, error)
 }
 
+// ApplyFunc is called on the children of a node, returning the node to replace each of them with,
+// which can be the child itself
+type ApplyFunc func(node Node) (Node, error)
+
+// Container is implemented by the nodes holding other nodes, like expressions or control structures,
+// so that their children can be walked and rewritten. Control structures not implementing it are
+// walked as leaves.
+type Container interface {
+	Node
+	// Apply calls f on each child of the node in order of appearance in the source, and replaces it
+	// with the returned node
+	Apply(f ApplyFunc) error
+}
+
+// Walk traverses an AST in depth-first order: it starts by calling v.Visit(node), then walks each
+// of the children of node with the visitor returned, unless it is nil.
 func Walk(v Visitor, node Node) error {
 	v, err := v.Visit(node)
 	if err != nil {
@@ -17,25 +37,10 @@ func Walk(v Visitor, node Node) error {
 		return nil
 	}
 
-	switch n := node.(type) {
-	case *Template:
-		for _, node := range n.Nodes {
-			if err := Walk(v, node); err != nil {
-				return err
-			}
-		}
-	case *Wrapper:
-		for _, node := range n.Nodes {
-			if err := Walk(v, node); err != nil {
-				return err
-			}
-		}
-	// case *Data:
-	// 	return visitor.Data(t)
-	// case *Output:
-	// 	return visitor.Output(t)
-	default:
-		return errors.Errorf("Unkown type %T", n)
+	if container, ok := node.(Container); ok {
+		return container.Apply(func(child Node) (Node, error) {
+			return child, Walk(v, child)
+		})
 	}
 	return nil
 }
@@ -51,26 +56,249 @@ func (f Inspector) Visit(node Node) (Visitor, error) {
 
 // Inspect traverses an AST in depth-first order: It starts by calling
 // f(node); node must not be nil. If f returns true, Inspect invokes f
-// recursively for each of the non-nil children of node, followed by a
-// call of f(nil).
+// recursively for each of the non-nil children of node.
 func Inspect(node Node, f func(Node) bool) {
 	Walk(Inspector(f), node)
 }
 
-// type NoOpVisitor struct {}
-
-// func (v *NoOpVisitor) Template(node *Template) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Comment(node *Template) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Data(node *Data) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) Output(node *Output) error {
-// 	return nil
-// }
-// func (v *NoOpVisitor) ControlStructure(node *ControlStructure) error {
-// 	return nil
-// }
+// Rewriter returns the node to replace a node of an AST with, which can be the node itself
+type Rewriter interface {
+	Rewrite(node Node) (Node, error)
+}
+
+type RewriterFunc func(Node) (Node, error)
+
+func (f RewriterFunc) Rewrite(node Node) (Node, error) {
+	return f(node)
+}
+
+// Rewrite rewrites an AST in depth-first order: the children of a node are rewritten before the node
+// itself, and each node is replaced with the one returned by the rewriter. Returning nil removes a node
+// from the nodes of a template or of a wrapper, and clears it anywhere else. The rewritten root is returned.
+func Rewrite(r Rewriter, node Node) (Node, error) {
+	if container, ok := node.(Container); ok {
+		if err := container.Apply(func(child Node) (Node, error) { return Rewrite(r, child) }); err != nil {
+			return nil, err
+		}
+	}
+	return r.Rewrite(node)
+}
+
+// ApplyTo calls f on the node held by field unless it is nil, and replaces it with the returned node,
+// which must be of the type of the field
+func ApplyTo[T Node](f ApplyFunc, field *T) error {
+	if isNil(*field) {
+		return nil
+	}
+	replacement, err := f(*field)
+	if err != nil {
+		return err
+	}
+	if same(replacement, *field) {
+		// Nodes left as is are not written, trees being walked concurrently when rendering
+		return nil
+	}
+	if isNil(replacement) {
+		var zero T
+		*field = zero
+		return nil
+	}
+	typed, ok := replacement.(T)
+	if !ok {
+		return errors.Errorf("unable to replace %s with %s: expected %s, got %T", *field, replacement, reflect.TypeFor[T](), replacement)
+	}
+	*field = typed
+	return nil
+}
+
+// ApplyToEach calls ApplyTo on each element of the slice
+func ApplyToEach[T Node](f ApplyFunc, fields []T) error {
+	for i := range fields {
+		if err := ApplyTo(f, &fields[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyToMap calls ApplyTo on each value of the map, in order of keys
+func ApplyToMap[T Node](f ApplyFunc, fields map[string]T) error {
+	for _, key := range slices.Sorted(maps.Keys(fields)) {
+		field := fields[key]
+		if err := ApplyTo(f, &field); err != nil {
+			return err
+		}
+		fields[key] = field
+	}
+	return nil
+}
+
+// ApplyToNodes calls f on each node of the list, replacing it with the returned node or removing it when nil
+func ApplyToNodes(f ApplyFunc, list *[]Node) error {
+	var applied []Node
+	for i, node := range *list {
+		replacement, err := f(node)
+		if err != nil {
+			return err
+		}
+		if applied == nil {
+			if same(replacement, node) {
+				continue
+			}
+			// The list is only copied once a node is replaced, trees being walked concurrently when rendering
+			applied = append(make([]Node, 0, len(*list)), (*list)[:i]...)
+		}
+		if !isNil(replacement) {
+			applied = append(applied, replacement)
+		}
+	}
+	if applied != nil {
+		*list = applied
+	}
+	return nil
+}
+
+// same tells whether both nodes are the same, without comparing nodes of types which are not comparable
+func same(a, b Node) bool {
+	t := reflect.TypeOf(a)
+	return t == reflect.TypeOf(b) && (t == nil || t.Comparable() && a == b)
+}
+
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	value := reflect.ValueOf(node)
+	return value.Kind() == reflect.Pointer && value.IsNil()
+}
+
+func (t *Template) Apply(f ApplyFunc) error {
+	return ApplyToNodes(f, &t.Nodes)
+}
+
+func (w *Wrapper) Apply(f ApplyFunc) error {
+	return ApplyToNodes(f, &w.Nodes)
+}
+
+func (o *Output) Apply(f ApplyFunc) error {
+	return applyAll(f, &o.Expression, &o.Condition, &o.Alternative)
+}
+
+func (expr *FilteredExpression) Apply(f ApplyFunc) error {
+	if err := ApplyTo(f, &expr.Expression); err != nil {
+		return err
+	}
+	return ApplyToEach(f, expr.Filters)
+}
+
+func (fc *FilterCall) Apply(f ApplyFunc) error {
+	if err := ApplyToEach(f, fc.Args); err != nil {
+		return err
+	}
+	return ApplyToMap(f, fc.Kwargs)
+}
+
+func (expr *TestExpression) Apply(f ApplyFunc) error {
+	if err := ApplyTo(f, &expr.Expression); err != nil {
+		return err
+	}
+	return ApplyTo(f, &expr.Test)
+}
+
+func (tc *TestCall) Apply(f ApplyFunc) error {
+	if err := ApplyToEach(f, tc.Args); err != nil {
+		return err
+	}
+	return ApplyToMap(f, tc.Kwargs)
+}
+
+func (l *List) Apply(f ApplyFunc) error {
+	return ApplyToEach(f, l.Val)
+}
+
+func (t *Tuple) Apply(f ApplyFunc) error {
+	return ApplyToEach(f, t.Val)
+}
+
+func (d *Dict) Apply(f ApplyFunc) error {
+	return ApplyToEach(f, d.Pairs)
+}
+
+func (p *Pair) Apply(f ApplyFunc) error {
+	return applyAll(f, &p.Key, &p.Value)
+}
+
+func (v *Variable) Apply(f ApplyFunc) error {
+	for _, part := range v.Parts {
+		if err := ApplyToEach(f, part.Args); err != nil {
+			return err
+		}
+		if err := ApplyToMap(f, part.Kwargs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Call) Apply(f ApplyFunc) error {
+	if err := ApplyTo(f, &c.Func); err != nil {
+		return err
+	}
+	if err := ApplyToEach(f, c.Args); err != nil {
+		return err
+	}
+	return ApplyToMap(f, c.Kwargs)
+}
+
+func (g *GetItem) Apply(f ApplyFunc) error {
+	if err := ApplyTo(f, &g.Node); err != nil {
+		return err
+	}
+	return ApplyTo(f, &g.Arg)
+}
+
+func (g *GetSlice) Apply(f ApplyFunc) error {
+	for _, field := range []*Node{&g.Node, &g.Start, &g.End} {
+		if err := ApplyTo(f, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GetAttribute) Apply(f ApplyFunc) error {
+	return ApplyTo(f, &g.Node)
+}
+
+func (n *Negation) Apply(f ApplyFunc) error {
+	return ApplyTo(f, &n.Term)
+}
+
+func (u *UnaryExpression) Apply(f ApplyFunc) error {
+	return ApplyTo(f, &u.Term)
+}
+
+func (expr *BinaryExpression) Apply(f ApplyFunc) error {
+	return applyAll(f, &expr.Left, &expr.Right)
+}
+
+func (s *ControlStructureBlock) Apply(f ApplyFunc) error {
+	return ApplyTo(f, &s.ControlStructure)
+}
+
+func (m *Macro) Apply(f ApplyFunc) error {
+	if err := ApplyToEach(f, m.Kwargs); err != nil {
+		return err
+	}
+	return ApplyTo(f, &m.Wrapper)
+}
+
+// applyAll calls ApplyTo on each of the given expressions
+func applyAll(f ApplyFunc, fields ...*Expression) error {
+	for _, field := range fields {
+		if err := ApplyTo(f, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -64,20 +65,29 @@ func (p *Parser) ParseControlStructureBlock() (*nodes.ControlStructureBlock, err
 		}).Trace("Matched end block")
 	}
 
-	stream := tokens.NewStream(args)
+	argParser := p.argumentsParser(args, name)
 	if logging.Enabled() {
 		log.WithFields(log.Fields{
-			"stream": stream,
+			"stream": argParser.Stream(),
 		}).Trace("Got stream")
 	}
-	argParser := NewParser(p.identifier, stream, p.Config, p.Loader, p.controlStructures)
 	if logging.Enabled() {
 		log.Trace("argparser")
 	}
 
+	body := p.Current()
 	controlStructure, err := controlStructureParser(p, argParser)
 	if err != nil {
-		return nil, errors.Wrapf(err, `Unable to parse controlStructure "%s"`, name.Val)
+		err = NewTemplateError(p.identifier, p.source, name, errors.Wrapf(err, `Unable to parse controlStructure "%s"`, name.Val))
+		if p.errors != nil && p.Current() == body {
+			// When recovering from syntax errors, the body of the control structure is checked on its own
+			if skipped, endTag := p.skippedBody(name.Val, end); endTag != nil {
+				p.recover(err, begin)
+				p.skip(skipped, endTag)
+				return nil, nil
+			}
+		}
+		return nil, err
 	}
 	if logging.Enabled() {
 		log.Trace("got controlStructure and return")
@@ -86,5 +96,82 @@ func (p *Parser) ParseControlStructureBlock() (*nodes.ControlStructureBlock, err
 		Location:         begin,
 		Name:             name.Val,
 		ControlStructure: controlStructure,
+		End:              end,
 	}, nil
 }
+
+// skippedBody returns the tokens of the body of the control structure with the given name whose tag, ending with
+// the given token, failed to parse, along with the tag ending it. The source is lexed again from the end of its tag
+// to match the tags with the ones ending them by name, the tags without end tag being closed along with the ones
+// enclosing them. No tag is returned when the body is not ended before the end of the enclosing control structures,
+// of the skipped body being checked, or a lexer error.
+func (p *Parser) skippedBody(name string, end *tokens.Token) ([]*tokens.Token, *tokens.Token) {
+	offset := end.Pos + len(end.Val)
+	if offset > len(p.source) || p.bound > 0 && offset >= p.bound {
+		return nil, nil
+	}
+	stream := tokens.LexFrom(p.source, p.Config, offset)
+	opened := []string{name}
+	var body []*tokens.Token
+	for !stream.End() && (p.bound == 0 || stream.Current().Pos < p.bound) {
+		token := stream.Next()
+		if tag := stream.Current(); token.Type == tokens.BlockBegin && tag.Type == tokens.Name {
+			if closed, ok := strings.CutPrefix(tag.Val, "end"); ok {
+				index := len(opened) - 1
+				for index >= 0 && opened[index] != closed {
+					index--
+				}
+				switch {
+				case index < 0:
+					// The end tag of an enclosing control structure
+					return nil, nil
+				case index == 0:
+					return body, token
+				}
+				opened = opened[:index]
+			} else {
+				opened = append(opened, tag.Val)
+			}
+		}
+		body = append(body, token)
+	}
+	return nil, nil
+}
+
+// skip checks the body of a control structure which failed to parse, recording its syntax errors, and moves
+// past the tag ending it. The tags of the body which are not control structures are taken as its own, like
+// else, since only the parser of the control structure knows them.
+func (p *Parser) skip(body []*tokens.Token, endTag *tokens.Token) {
+	stream, bound := p.stream, p.bound
+	p.stream = tokens.NewStream(append(body, &tokens.Token{Type: tokens.EOF, Pos: endTag.Pos, Line: endTag.Line, Col: endTag.Col}))
+	p.bound = endTag.Pos
+	for !p.End() {
+		if begin := p.Match(tokens.BlockBegin); begin != nil {
+			if tag := p.Current(tokens.Name); tag != nil {
+				if _, exists := p.controlStructures.Get(tag.Val); !exists {
+					p.skipTag()
+					continue
+				}
+			}
+			p.stream.Backup()
+		}
+		start := p.Current()
+		if _, err := p.parseDocElement(); err != nil {
+			p.recover(err, start)
+		}
+	}
+	p.stream, p.bound = stream, bound
+
+	for !p.End() && (p.Current(tokens.BlockBegin) == nil || p.Current().Pos != endTag.Pos) {
+		p.Consume()
+	}
+	p.skipTag()
+}
+
+// skipTag moves past the end of the current tag
+func (p *Parser) skipTag() {
+	for !p.End() && p.Current(tokens.BlockEnd) == nil {
+		p.Consume()
+	}
+	p.Match(tokens.BlockEnd)
+}
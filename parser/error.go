@@ -1,14 +1,196 @@
 package parser
 
 import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/nikolalohinski/gonja/v2/tokens"
-	"github.com/pkg/errors"
 )
 
-func (p *Parser) Error(message string, token *tokens.Token) error {
-	if token == nil {
-		return errors.New(message)
+// TemplateError is an error located in a template, returned when parsing or rendering a template fails.
+// It is usually wrapped with some context, and can be retrieved with errors.As.
+type TemplateError struct {
+	// Template is the identifier of the template the error occurred in
+	Template string
+	// Line and Column locate the error in the template, starting at 1, or are 0 when unknown
+	Line   int
+	Column int
+	// Near is the value of the token the error occurred at, if any
+	Near string
+	// Source is the line of the template the error occurred at, if its source is known
+	Source string
+	// Stack holds the includes, extends, imports and macro calls leading to the error, innermost first
+	Stack []Frame
+	// Err is the underlying error
+	Err error
+}
+
+// Frame is a step of the stack leading to a template error, like the inclusion of the template
+// the error occurred in, or the call of the macro it occurred in
+type Frame struct {
+	// Kind is the kind of step, either "include", "extends", "import" or "macro"
+	Kind string
+	// Name is the identifier of the included, extended or imported template, or the name of the called macro
+	Name string
+	// Template, Line and Column locate the tag or the call of the step
+	Template string
+	Line     int
+	Column   int
+}
+
+// ErrorList is the list of the syntax errors of a template, returned when parsing with Parser.ParseAll
+type ErrorList []*TemplateError
+
+func (l ErrorList) Error() string {
+	messages := make([]string, 0, len(l))
+	for _, err := range l {
+		messages = append(messages, err.Error())
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (l ErrorList) Unwrap() []error {
+	errs := make([]error, 0, len(l))
+	for _, err := range l {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+func (f Frame) String() string {
+	return fmt.Sprintf("%s '%s' at %s:%d:%d", f.Kind, f.Name, f.Template, f.Line, f.Column)
+}
+
+// NewTemplateError returns an error located at the given token of a template. The source line of the error
+// is looked up in source when given. When err is already located, it is returned as is.
+func NewTemplateError(template, source string, token *tokens.Token, err error) error {
+	if err == nil || errors.As(err, new(*TemplateError)) {
+		return err
+	}
+	templateError := &TemplateError{
+		Template: template,
+		Err:      err,
+	}
+	if token != nil {
+		templateError.Line = token.Line
+		templateError.Column = token.Col
+		templateError.Near = token.Val
+		templateError.Source = SourceLine(source, token.Line)
+	}
+	return templateError
+}
+
+// WithFrame records a step of the stack leading to err, when err is located in a template. The template
+// error is copied rather than updated, since the same error may be returned along several stacks.
+func WithFrame(err error, frame Frame) error {
+	var templateError *TemplateError
+	if !errors.As(err, &templateError) {
+		return err
+	}
+	framed := *templateError
+	framed.Stack = append(slices.Clip(templateError.Stack), frame)
+	if err == error(templateError) {
+		return &framed
+	}
+	return &framedError{error: err, located: &framed}
+}
+
+// framedError wraps an error holding a template error, and stands for that template error
+// with a copy having a longer stack
+type framedError struct {
+	error
+	located *TemplateError
+}
+
+func (e *framedError) Unwrap() error {
+	return e.error
+}
+
+func (e *framedError) As(target any) bool {
+	if target, ok := target.(**TemplateError); ok {
+		*target = e.located
+		return true
 	}
+	return false
+}
 
-	return errors.Errorf(`%s (Line: %d Col: %d, near "%s")`, message, token.Line, token.Col, token.Val)
+// SourceLine returns the given line of the source, starting at 1, or an empty string if there is no such line
+func SourceLine(source string, line int) string {
+	if line < 1 {
+		return ""
+	}
+	for i := 1; i < line; i++ {
+		index := strings.IndexByte(source, '\n')
+		if index < 0 {
+			return ""
+		}
+		source = source[index+1:]
+	}
+	if index := strings.IndexByte(source, '\n'); index >= 0 {
+		source = source[:index]
+	}
+	return strings.TrimSuffix(source, "\r")
+}
+
+func (e *TemplateError) Error() string {
+	if e.Line == 0 && e.Near == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf(`%s (Line: %d Col: %d, near "%s")`, e.Err, e.Line, e.Column, e.Near)
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Details describes the error over several lines, with the source line of the error marked with a caret
+// and the stack leading to it, like:
+//
+//	/page.html:3:8: unknown filter 'uper'
+//	  {{ user | uper }}
+//	         ^
+//	  in macro 'card' at /index.html:5:4
+//	  in include '/page.html' at /index.html:1:4
+func (e *TemplateError) Details() string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "%s:%d:%d: %s", e.Template, e.Line, e.Column, e.Err)
+	if e.Source != "" && e.Column > 0 {
+		fmt.Fprintf(&out, "\n  %s\n  %s^", e.Source, caretIndent(e.Source, e.Column))
+	}
+	for _, frame := range e.Stack {
+		fmt.Fprintf(&out, "\n  in %s", frame)
+	}
+	return out.String()
+}
+
+// caretIndent returns the indentation putting a caret under the given column of the line, keeping its tabs
+func caretIndent(line string, column int) string {
+	var indent strings.Builder
+	for i, r := range []rune(line) {
+		if i >= column-1 {
+			break
+		}
+		if r == '\t' {
+			indent.WriteRune('\t')
+		} else {
+			indent.WriteRune(' ')
+		}
+	}
+	return indent.String()
+}
+
+// Error returns an error located at the given token, or at the tag being parsed when the token is
+// nil or the end of its arguments
+func (p *Parser) Error(message string, token *tokens.Token) error {
+	return p.Locate(errors.New(message), token)
+}
+
+// Locate returns the error located at the given token like Error, for errors to be told apart with errors.Is or errors.As
+func (p *Parser) Locate(err error, token *tokens.Token) error {
+	if p.location != nil && (token == nil || token.Type == tokens.EOF) {
+		token = p.location
+	}
+	return NewTemplateError(p.identifier, p.source, token, err)
 }
@@ -2,6 +2,7 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"slices"
@@ -17,6 +18,11 @@ type ControlStructureGetter interface {
 	Get(name string) (ControlStructureParser, bool)
 }
 
+// TemplateLoader loads the templates extended by the one being parsed, allowing them to be cached
+type TemplateLoader interface {
+	LoadTemplate(identifier string, config *config.Config, loader loaders.Loader) (*nodes.Template, error)
+}
+
 // Parser provides a comprehensive and easy tool to
 // work with the template document and arguments provided by
 // the user for your custom tag.
@@ -30,10 +36,49 @@ type Parser struct {
 	identifier        string
 	stream            *tokens.Stream
 	controlStructures ControlStructureGetter
+	// source of the template, and location of the tag whose arguments are parsed if any, used to locate errors
+	source   string
+	location *tokens.Token
+	// errors collects the syntax errors when recovering from them, and bound is the offset of the tag ending
+	// the body being checked of a control structure which failed to parse, if any
+	errors *ErrorList
+	bound  int
+	// loops counts the loops enclosing the tags being parsed, reset within bodies executed out of the loop
+	loops int
+	// bodies counts the bodies of control structures enclosing the tags being parsed
+	bodies int
+
+	Config    *config.Config
+	Template  *nodes.Template
+	Loader    loaders.Loader
+	Templates TemplateLoader
+}
+
+// Identifier returns the identifier of the template being parsed
+func (p *Parser) Identifier() string {
+	return p.identifier
+}
+
+// LoopDepth returns the number of loops whose body encloses the tags being parsed
+func (p *Parser) LoopDepth() int {
+	return p.loops
+}
+
+// WithLoopDepth sets the number of loops enclosing the tags parsed until the returned function
+// is called to restore the previous one. Loops increment it while parsing their body, whereas
+// control structures whose body may be executed out of the loop, like macros, reset it
+func (p *Parser) WithLoopDepth(depth int) func() {
+	previous := p.loops
+	p.loops = depth
+	return func() {
+		p.loops = previous
+	}
+}
 
-	Config   *config.Config
-	Template *nodes.Template
-	Loader   loaders.Loader
+// TopLevel tells whether the tags being parsed are at the top level of the template, rather than
+// in the body of a control structure
+func (p *Parser) TopLevel() bool {
+	return p.bodies == 0
 }
 
 func (p *Parser) Stream() *tokens.Stream {
@@ -44,13 +89,25 @@ func (p *Parser) Stream() *tokens.Stream {
 // Used inside gonja to parse documents and to provide an easy-to-use
 // parser for tag authors
 func NewParser(identifier string, stream *tokens.Stream, cfg *config.Config, loader loaders.Loader, controlStructures ControlStructureGetter) *Parser {
-	return &Parser{
+	p := &Parser{
 		identifier:        identifier,
 		stream:            stream,
 		controlStructures: controlStructures,
 		Config:            cfg,
 		Loader:            loader,
 	}
+	if stream != nil {
+		p.source = stream.Source
+	}
+	return p
+}
+
+// argumentsParser returns a parser for the arguments of the tag at the given location
+func (p *Parser) argumentsParser(arguments []*tokens.Token, location *tokens.Token) *Parser {
+	sub := NewParser(p.identifier, tokens.NewStream(arguments), p.Config, p.Loader, p.controlStructures)
+	sub.source = p.source
+	sub.location = location
+	return sub
 }
 
 // Consume one token. It will be gone forever.
@@ -155,7 +212,9 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 
 	var args []*tokens.Token
 
-	for !p.stream.End() {
+	p.bodies++
+	defer func() { p.bodies-- }()
+	for !p.stream.End() || p.resume() {
 		// New tag, check whether we have to stop wrapping here
 		if begin := p.Match(tokens.BlockBegin); begin != nil {
 			endTag := p.CurrentName(names...)
@@ -165,11 +224,11 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 				for {
 					if end := p.Match(tokens.BlockEnd); end != nil {
 						wrapper.EndTag = endTag.Val
+						wrapper.EndTagBegin, wrapper.EndTagEnd = begin, end
 						if data := p.Current(tokens.Data); data != nil {
 							data.Trim = data.Trim || len(end.Val) > 0 && end.Val[0] == '-'
 						}
-						stream := tokens.NewStream(args)
-						return wrapper, NewParser(p.identifier, stream, p.Config, p.Loader, p.controlStructures), nil
+						return wrapper, p.argumentsParser(args, endTag), nil
 					}
 					if p.End() || p.Current(tokens.EOF) != nil {
 						return nil, nil, p.Error("Unexpected EOF.", p.Current())
@@ -181,11 +240,17 @@ func (p *Parser) WrapUntil(names ...string) (*nodes.Wrapper, *Parser, error) {
 		}
 
 		// Otherwise process next element to be wrapped
+		start := p.Current()
 		node, err := p.parseDocElement()
 		if err != nil {
+			if p.recover(err, start) {
+				continue
+			}
 			return nil, nil, err
 		}
-		wrapper.Nodes = append(wrapper.Nodes, node)
+		if node != nil {
+			wrapper.Nodes = append(wrapper.Nodes, node)
+		}
 	}
 
 	return nil, nil, p.Error(fmt.Sprintf("Unexpected EOF, expected tag %s.", strings.Join(names, " or ")),
@@ -219,6 +284,9 @@ func (p *Parser) parseDocElement() (nodes.Node, error) {
 		return p.ParseExpressionNode()
 	case tokens.BlockBegin:
 		node, err := p.ParseControlStructureBlock()
+		if node == nil {
+			return nil, err
+		}
 		return node, err
 	}
 	return nil, p.Error("Unexpected token (only HTML/tags/filters in templates allowed)", t)
@@ -232,9 +300,13 @@ func (p *Parser) Parse() (*nodes.Template, error) {
 	}
 	p.Template = tpl
 
-	for !p.Stream().End() {
+	for !p.Stream().End() || p.resume() {
+		start := p.Current()
 		node, err := p.parseDocElement()
 		if err != nil {
+			if p.recover(err, start) {
+				continue
+			}
 			return nil, err
 		}
 		if node != nil {
@@ -244,7 +316,69 @@ func (p *Parser) Parse() (*nodes.Template, error) {
 	return tpl, nil
 }
 
+// ParseAll parses the template like Parse, but does not stop at the first syntax error: the parser
+// resyncs at the next delimiter and carries on, lexing the template again after the errors of the lexer.
+// The bodies of the control structures which failed to parse are checked as well. It returns the nodes
+// parsed successfully along with an ErrorList of every syntax error found, if any.
+func (p *Parser) ParseAll() (*nodes.Template, error) {
+	p.errors = &ErrorList{}
+	defer func() { p.errors = nil }()
+
+	tpl, err := p.Parse()
+	if err != nil {
+		return nil, err
+	}
+	if len(*p.errors) > 0 {
+		return tpl, *p.errors
+	}
+	return tpl, nil
+}
+
+// recover records the error of the element starting at the given token when recovering from syntax
+// errors, and skips the tokens up to the next data or delimiter. It tells whether parsing can go on.
+func (p *Parser) recover(err error, start *tokens.Token) bool {
+	if p.errors == nil {
+		return false
+	}
+	var templateError *TemplateError
+	errors.As(NewTemplateError(p.identifier, p.source, start, err), &templateError)
+	*p.errors = append(*p.errors, templateError)
+
+	if p.Current() == start && !p.End() {
+		p.Consume()
+	}
+	for !p.End() && p.Current(tokens.Data, tokens.VariableBegin, tokens.BlockBegin, tokens.CommentBegin) == nil {
+		p.Consume()
+	}
+	if p.stream.IsError() && p.bound == 0 {
+		p.stream = tokens.Resume(p.source, p.Config, p.Current())
+	}
+	return true
+}
+
+// resume records the error of the lexer when recovering from syntax errors, and lexes the rest of the
+// template from the next delimiter. It tells whether parsing can go on.
+func (p *Parser) resume() bool {
+	if p.errors == nil || !p.stream.IsError() || p.bound > 0 {
+		return false
+	}
+	failed := p.Current()
+	return p.recover(p.Error(failed.Val, failed), failed)
+}
+
 func (p *Parser) Extend(identifier string) (*nodes.Template, error) {
+	if p.Templates != nil {
+		resolved, err := p.Loader.Resolve(identifier)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve identifier '%s': %s", identifier, err)
+		}
+		loader, err := p.Loader.Inherit(resolved)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inherit loader: %s", err)
+		}
+		return p.Templates.LoadTemplate(resolved, p.Config.Inherit(), loader)
+	}
+
 	input, err := p.Loader.Read(identifier)
 	if err != nil {
 		return nil, fmt.Errorf("failed to reader template '%s': %s", identifier, err)
@@ -267,12 +401,7 @@ func (p *Parser) Extend(identifier string) (*nodes.Template, error) {
 
 	config := p.Config.Inherit()
 
-	parser := &Parser{
-		identifier:        identifier,
-		stream:            tokens.LexAll(source.String(), config),
-		controlStructures: p.controlStructures,
-		Config:            config,
-		Loader:            loader,
-	}
+	parser := NewParser(identifier, tokens.LexAll(source.String(), config), config, loader, p.controlStructures)
+	parser.Templates = p.Templates
 	return parser.Parse()
 }
@@ -1,6 +1,7 @@
 package parser_test
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -977,3 +978,126 @@ var _ = Context("parser", func() {
 		})
 	}
 })
+
+var _ = Context("parser recovering from syntax errors", func() {
+	var (
+		input = new(string)
+
+		returnedTemplate = new(*nodes.Template)
+		returnedError    = new(error)
+	)
+	JustBeforeEach(func() {
+		stream := tokens.Lex(*input, config.New())
+		*returnedTemplate, *returnedError = parser.NewParser("tests", stream, config.New(), loaders.MustNewFileSystemLoader(""), builtins.ControlStructures).ParseAll()
+	})
+	location := func(err *parser.TemplateError) string {
+		return fmt.Sprintf("%d:%d", err.Line, err.Column)
+	}
+	Context("when the input is valid", func() {
+		BeforeEach(func() {
+			*input = "Hello {{ name }}{% if name %}!{% endif %}"
+		})
+		It("should return the template without any error", func() {
+			Expect(*returnedError).To(BeNil())
+			Expect((*returnedTemplate).Nodes).To(HaveLen(3))
+		})
+	})
+	Context("when the input has several syntax errors", func() {
+		BeforeEach(func() {
+			*input = "a {{ name. }} b\n" +
+				"{% if %}x{% else %}y{% endif %}\n" +
+				"{% for item in items %}{{ item | }}{{ item + }}{% endfor %}\n" +
+				"{% unknown %}{{ 'ok' }}\n" +
+				"{{ 1.2.3 }}"
+		})
+		It("should return every error with its position", func() {
+			var errs parser.ErrorList
+			Expect(*returnedError).To(BeAssignableToTypeOf(errs))
+			errs = (*returnedError).(parser.ErrorList)
+			Expect(errs).To(HaveEach(WithTransform(func(err *parser.TemplateError) string { return err.Template }, Equal("tests"))))
+			Expect(errs).To(WithTransform(func(errs parser.ErrorList) []string {
+				locations := []string{}
+				for _, err := range errs {
+					locations = append(locations, location(err))
+				}
+				return locations
+			}, Equal([]string{"1:12", "2:4", "3:34", "3:46", "4:4", "5:4"})))
+			Expect(errs[4].Err).To(MatchError(ContainSubstring("ControlStructure 'unknown' not found")))
+		})
+		It("should keep the nodes parsed successfully", func() {
+			Expect(*returnedTemplate).ToNot(BeNil())
+			Expect((*returnedTemplate).Nodes).To(ContainElement(PointTo(MatchFields(IgnoreExtras, Fields{
+				"Expression": PointTo(MatchFields(IgnoreExtras, Fields{"Val": Equal("ok")})),
+			}))))
+		})
+		It("should match any of the errors with errors.As", func() {
+			var templateError *parser.TemplateError
+			Expect(errors.As(*returnedError, &templateError)).To(BeTrue())
+			Expect(location(templateError)).To(Equal("1:12"))
+		})
+	})
+	locations := func(errs error) []string {
+		locations := []string{}
+		for _, err := range errs.(parser.ErrorList) {
+			locations = append(locations, location(err))
+		}
+		return locations
+	}
+	Context("when the body of a control structure which failed to parse has errors", func() {
+		BeforeEach(func() {
+			*input = "{% if %}{% else %}{% raw %}{% endif %}{% endraw %}{# {% endif %} #}{% endif %}\n" +
+				"{% if x %}{% unknown %}{% endif %}{% for %}{{ x. }}{% endfor %}\n" +
+				"{% for x in %}{% if x %}{% endfor %}{% endif %}\n" +
+				"{% bad %}"
+		})
+		It("should return the errors within the body and after its end tag", func() {
+			Expect(locations(*returnedError)).To(Equal([]string{"1:4", "2:14", "2:38", "2:50", "3:4", "3:25", "3:40", "4:4"}))
+			errs := (*returnedError).(parser.ErrorList)
+			Expect(errs[1].Err).To(MatchError(ContainSubstring("ControlStructure 'unknown' not found")))
+			Expect(errs[5].Err).To(MatchError(ContainSubstring("Unexpected EOF, expected tag elif or else or endif.")))
+			Expect(errs[6].Err).To(MatchError(ContainSubstring("ControlStructure 'endif' not found")))
+			Expect(errs[7].Err).To(MatchError(ContainSubstring("ControlStructure 'bad' not found")))
+		})
+	})
+	Context("when the lexer fails", func() {
+		BeforeEach(func() {
+			*input = "{{ 'name }}\n{% bad %}{% if %}{{ x }\n{{ x. }}"
+		})
+		It("should return the errors following the one of the lexer", func() {
+			Expect(locations(*returnedError)).To(Equal([]string{"1:4", "2:4", "2:13", "2:23", "3:7"}))
+		})
+	})
+	Context("when a control structure is not closed", func() {
+		BeforeEach(func() {
+			*input = "{% if name %}{{ name. }}"
+		})
+		It("should return the errors within and at the end of the control structure", func() {
+			Expect(*returnedError).To(MatchError(And(
+				ContainSubstring(`near "}}"`),
+				ContainSubstring("Unexpected EOF, expected tag elif or else or endif."),
+			)))
+		})
+	})
+})
+
+var _ = Context("recording the stack of a template error", func() {
+	var (
+		located = new(*parser.TemplateError)
+	)
+	BeforeEach(func() {
+		*located = &parser.TemplateError{Template: "/macros.html", Line: 1, Column: 4, Err: errors.New("failed")}
+	})
+	It("should leave the recorded error untouched", func() {
+		first := parser.WithFrame(*located, parser.Frame{Kind: "include", Name: "/macros.html", Template: "/a.html"})
+		second := parser.WithFrame(fmt.Errorf("wrapped: %w", *located), parser.Frame{Kind: "include", Name: "/macros.html", Template: "/b.html"})
+		Expect((*located).Stack).To(BeEmpty())
+
+		var templateError *parser.TemplateError
+		Expect(errors.As(first, &templateError)).To(BeTrue())
+		Expect(templateError.Stack).To(HaveExactElements(HaveField("Template", "/a.html")))
+		Expect(errors.As(second, &templateError)).To(BeTrue())
+		Expect(templateError.Stack).To(HaveExactElements(HaveField("Template", "/b.html")))
+		Expect(second).To(MatchError(`wrapped: failed (Line: 1 Col: 4, near "")`))
+		Expect(errors.Is(second, *located)).To(BeTrue())
+	})
+})
@@ -0,0 +1,65 @@
+package gonja
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+)
+
+// PrecompileDirectory parses every template found in the given directory and its subdirectories with the given
+// configuration and environment, like DefaultConfig and DefaultEnvironment, and returns them serialized in a bundle
+// which can be loaded back with LoadPrecompiled, typically from a go:generate directive. Templates are keyed by
+// their slash separated path relative to the directory. When extensions are given, only the files ending with one
+// of them are precompiled.
+func PrecompileDirectory(dir string, cfg *config.Config, environment *exec.Environment, extensions ...string) ([]byte, error) {
+	loader, err := loaders.NewFileSystemLoader(dir)
+	if err != nil {
+		return nil, err
+	}
+	bundle := map[string]*nodes.Template{}
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() || (len(extensions) > 0 && !slices.Contains(extensions, filepath.Ext(path))) {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		template, err := exec.NewTemplate(name, cfg, loader, environment)
+		if err != nil {
+			return fmt.Errorf("failed to precompile '%s': %s", name, err)
+		}
+		bundle[name] = template.Root()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(bundle)
+}
+
+// LoadPrecompiled loads the templates of a bundle produced by PrecompileDirectory, keyed by their relative path.
+// The configuration and environment should be the ones the templates were precompiled with, and the loader
+// is used for the templates resolved at render time, like included or imported ones.
+func LoadPrecompiled(bundle []byte, cfg *config.Config, loader loaders.Loader, environment *exec.Environment) (map[string]*exec.Template, error) {
+	roots := map[string]*nodes.Template{}
+	if err := json.Unmarshal(bundle, &roots); err != nil {
+		return nil, fmt.Errorf("failed to load precompiled templates: %s", err)
+	}
+	templates := make(map[string]*exec.Template, len(roots))
+	for name, root := range roots {
+		templates[name] = exec.NewTemplateFromRoot(root, cfg, loader, environment)
+	}
+	return templates, nil
+}
@@ -0,0 +1,143 @@
+package integration_test
+
+import (
+	"github.com/MakeNowJust/heredoc"
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("control structure 'call'", func() {
+	var (
+		identifier = new(string)
+
+		environment = new(*exec.Environment)
+		loader      = new(loaders.Loader)
+
+		context = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+		shouldRender   = func(template, result string) {
+			Context(template, func() {
+				BeforeEach(func() {
+					*loader = loaders.MustNewMemoryLoader(map[string]string{
+						*identifier: template,
+					})
+				})
+				It("should return the expected rendered content", func() {
+					By("not returning any error")
+					Expect(*returnedErr).To(BeNil())
+					By("returning the expected result")
+					AssertPrettyDiff(result, *returnedResult)
+				})
+			})
+		}
+		shouldFail = func(template, err string) {
+			Context(template, func() {
+				BeforeEach(func() {
+					*loader = loaders.MustNewMemoryLoader(map[string]string{
+						*identifier: template,
+					})
+				})
+				It("should return the expected error", func() {
+					Expect(*returnedErr).ToNot(BeNil())
+					Expect((*returnedErr).Error()).To(MatchRegexp(err))
+				})
+			})
+		}
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*environment = gonja.DefaultEnvironment
+		*loader = loaders.MustNewMemoryLoader(nil)
+		*context = exec.NewContext(map[string]any{
+			"users": []map[string]any{
+				{"name": "alice"},
+				{"name": "bob"},
+			},
+		})
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	Context("without caller arguments", func() {
+		shouldRender(
+			heredoc.Doc(`
+				{%- macro card(title) -%}
+				<div class="{{ title }}">{{ caller() }}</div>
+				{%- endmacro -%}
+				{% call card("main") %}content of {{ users | length }} users{% endcall %}`),
+			`<div class="main">content of 2 users</div>`,
+		)
+	})
+	Context("with caller arguments", func() {
+		shouldRender(
+			heredoc.Doc(`
+				{%- macro list_users(users) -%}
+				<ul>{% for user in users %}<li>{{ caller(user) }}</li>{% endfor %}</ul>
+				{%- endmacro -%}
+				{% call(user) list_users(users) %}{{ user.name | capitalize }}{% endcall %}`),
+			`<ul><li>Alice</li><li>Bob</li></ul>`,
+		)
+	})
+	Context("with caller arguments having default values", func() {
+		shouldRender(
+			heredoc.Doc(`
+				{%- macro wrap() -%}
+				[{{ caller() }}|{{ caller("explicit") }}]
+				{%- endmacro -%}
+				{% call(value="default") wrap() %}{{ value }}{% endcall %}`),
+			`[default|explicit]`,
+		)
+	})
+	Context("when nesting call blocks", func() {
+		shouldRender(
+			heredoc.Doc(`
+				{%- macro outer() -%}
+				outer({{ caller() }})
+				{%- endmacro -%}
+				{%- macro inner() -%}
+				inner({{ caller() }})
+				{%- endmacro -%}
+				{% call outer() %}{% call inner() %}body{% endcall %}{% endcall %}`),
+			`outer(inner(body))`,
+		)
+	})
+	Context("when the macro declares the caller explicitly", func() {
+		shouldRender(
+			heredoc.Doc(`
+				{%- macro explicit(caller) -%}
+				{{ caller() }}
+				{%- endmacro -%}
+				{% call explicit() %}explicit caller{% endcall %}`),
+			`explicit caller`,
+		)
+	})
+	Context("when a caller is passed explicitly outside of a call block", func() {
+		shouldFail(
+			`{% macro m() %}{{ caller() }}{% endmacro %}{{ m(caller="body") }}`,
+			"macro 'm' takes no keyword argument 'caller'",
+		)
+	})
+	Context("when the call tag is not given a call expression", func() {
+		shouldFail(
+			"{% call users %}body{% endcall %}",
+			"Tag 'call' expects a macro call",
+		)
+	})
+	Context("when the call tag is not closed", func() {
+		shouldFail(
+			"{% macro m() %}{{ caller() }}{% endmacro %}{% call m() %}body",
+			"Unexpected EOF, expected tag endcall",
+		)
+	})
+})
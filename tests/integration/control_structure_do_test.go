@@ -0,0 +1,93 @@
+package integration_test
+
+import (
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("control structure 'do'", func() {
+	var (
+		identifier = new(string)
+
+		environment = new(*exec.Environment)
+		loader      = new(loaders.Loader)
+
+		context = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+		shouldRender   = func(template, result string) {
+			Context(template, func() {
+				BeforeEach(func() {
+					*loader = loaders.MustNewMemoryLoader(map[string]string{
+						*identifier: template,
+					})
+				})
+				It("should return the expected rendered content", func() {
+					By("not returning any error")
+					Expect(*returnedErr).To(BeNil())
+					By("returning the expected result")
+					AssertPrettyDiff(result, *returnedResult)
+				})
+			})
+		}
+		shouldFail = func(template, err string) {
+			Context(template, func() {
+				BeforeEach(func() {
+					*loader = loaders.MustNewMemoryLoader(map[string]string{
+						*identifier: template,
+					})
+				})
+				It("should return the expected error", func() {
+					Expect(*returnedErr).ToNot(BeNil())
+					Expect((*returnedErr).Error()).To(ContainSubstring(err))
+				})
+			})
+		}
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*environment = gonja.DefaultEnvironment
+		*loader = loaders.MustNewMemoryLoader(nil)
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	shouldRender(
+		"{% set items = [1] %}{% do items.append(2) %}{{ items }}",
+		"[1, 2]",
+	)
+	shouldRender(
+		"{% set items = [] %}{% do items.append(1) %}{% do items.append(2) %}{% do items.reverse() %}{{ items }}",
+		"[2, 1]",
+	)
+	shouldRender(
+		"before{% do 'ignored' | upper %}after",
+		"beforeafter",
+	)
+	shouldRender(
+		"{% set items = [] %}{% do items.append('yes') if true else items.append('no') %}{% do items.append('never') if false %}{{ items }}",
+		"['yes']",
+	)
+	shouldFail(
+		"{% do %}",
+		"Tag 'do' requires an expression",
+	)
+	shouldFail(
+		"{% do 1 2 %}",
+		"Malformed 'do' tag args",
+	)
+	shouldFail(
+		"{% do 'abc'.unknown() %}",
+		"unknown method 'unknown'",
+	)
+})
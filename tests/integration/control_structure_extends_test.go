@@ -0,0 +1,191 @@
+package integration_test
+
+import (
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("control structure 'extends'", func() {
+	var (
+		identifier = new(string)
+
+		environment = new(*exec.Environment)
+		loader      = new(loaders.Loader)
+
+		context = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*environment = gonja.DefaultEnvironment
+		*context = exec.NewContext(map[string]any{})
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	layouts := map[string]string{
+		"/desktop.html": "desktop[{% block content %}default{% endblock %}]",
+		"/mobile.html":  "mobile[{% block content %}default{% endblock %}]",
+		"/base.html":    "<{% block content %}base{% endblock %}>",
+		"/middle.html":  "{% extends layout %}{% block content %}middle {{ super() }}{% endblock %}",
+		"/macros.html":  "{% macro italic(s) %}_{{ s }}_{% endmacro %}",
+	}
+	withTemplate := func(source string) {
+		BeforeEach(func() {
+			templates := map[string]string{*identifier: source}
+			for name, layout := range layouts {
+				templates[name] = layout
+			}
+			*loader = loaders.MustNewMemoryLoader(templates)
+		})
+	}
+	Context("when the extended template is a variable", func() {
+		withTemplate("{% extends layout %}{% block content %}page {{ super() }}{% endblock %}")
+		BeforeEach(func() {
+			(*context).Set("layout", "/mobile.html")
+		})
+		It("should render the template named by the variable with the overridden blocks", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("mobile[page default]", *returnedResult)
+		})
+	})
+	Context("when the extended template is selected by a condition", func() {
+		withTemplate(`{% extends "/mobile.html" if mobile else "/desktop.html" %}{% block content %}page{% endblock %}`)
+		Context("and the condition holds", func() {
+			BeforeEach(func() {
+				(*context).Set("mobile", true)
+			})
+			It("should render the first template", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("mobile[page]", *returnedResult)
+			})
+		})
+		Context("and the condition does not hold", func() {
+			BeforeEach(func() {
+				(*context).Set("mobile", false)
+			})
+			It("should render the alternative template", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("desktop[page]", *returnedResult)
+			})
+		})
+	})
+	Context("when the extended template is an already built template", func() {
+		withTemplate("{% extends layout %}{% block content %}page{% endblock %}")
+		BeforeEach(func() {
+			layout, err := exec.NewTemplate("/desktop.html", gonja.DefaultConfig, loaders.MustNewMemoryLoader(layouts), *environment)
+			Expect(err).To(BeNil())
+			(*context).Set("layout", layout)
+		})
+		It("should render the given template", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("desktop[page]", *returnedResult)
+		})
+	})
+	Context("when the extended template extends another one at render time", func() {
+		withTemplate(`{% extends "/middle.html" ~ "" %}{% block content %}page {{ super() }}{% endblock %}`)
+		BeforeEach(func() {
+			(*context).Set("layout", "/base.html")
+		})
+		It("should render the topmost template with the blocks of every template", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("<page middle base>", *returnedResult)
+		})
+	})
+	Context("when the template extends statically a template extending another one at render time", func() {
+		withTemplate(`{% extends "/middle.html" %}{% block content %}page {{ super() }}{% endblock %}`)
+		BeforeEach(func() {
+			(*context).Set("layout", "/desktop.html")
+		})
+		It("should render the topmost template with the blocks of every template", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("desktop[page middle default]", *returnedResult)
+		})
+	})
+	Context("when the template has content around the extends tag", func() {
+		withTemplate("before {% extends layout %}after{% block content %}page{% endblock %}")
+		BeforeEach(func() {
+			(*context).Set("layout", "/base.html")
+		})
+		It("should render the content preceding the tag only", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("before <page>", *returnedResult)
+		})
+	})
+	Context("when the template has definitions following the extends tag", func() {
+		withTemplate(`{% extends layout %}{% set name = "page" %}{% macro bold(s) %}*{{ s }}*{% endmacro %}{% from "/macros.html" import italic %}{% block content %}{{ bold(name) }}{{ italic(name) }}{% endblock %}`)
+		BeforeEach(func() {
+			(*context).Set("layout", "/base.html")
+		})
+		It("should evaluate them before rendering the parent", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("<*page*_page_>", *returnedResult)
+		})
+		Context("and the template is extended statically", func() {
+			withTemplate(`{% extends "/base.html" %}{% set name = "page" %}{% macro bold(s) %}*{{ s }}*{% endmacro %}{% from "/macros.html" import italic %}{% block content %}{{ bold(name) }}{{ italic(name) }}{% endblock %}`)
+			It("should evaluate them before rendering the parent too", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("<*page*_page_>", *returnedResult)
+			})
+		})
+	})
+	Context("when the template is rendered with different parents", func() {
+		withTemplate("{% extends layout %}{% block content %}page{% endblock %}")
+		It("should render each parent", func() {
+			t, err := exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+			Expect(err).To(BeNil())
+			for _, layout := range []string{"/mobile.html", "/desktop.html", "/mobile.html"} {
+				result, err := t.ExecuteToString(exec.NewContext(map[string]any{"layout": layout}))
+				Expect(err).To(BeNil())
+				AssertPrettyDiff(layout[1:len(layout)-len(".html")]+"[page]", result)
+			}
+		})
+	})
+	Context("when the extended template does not exist", func() {
+		withTemplate("{% extends layout %}")
+		BeforeEach(func() {
+			(*context).Set("layout", "/missing.html")
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("unable to load template '/missing.html'")))
+		})
+	})
+	Context("when the extended template is neither a name nor a template", func() {
+		withTemplate("{% extends layout %}")
+		BeforeEach(func() {
+			(*context).Set("layout", 42)
+		})
+		It("should return an error located at the expression", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("expected a template, the name of a template or a list of names")))
+			Expect(*returnedErr).To(MatchError(ContainSubstring(`(Line: 1 Col: 12, near "layout")`)))
+		})
+	})
+	Context("when the extended template is selected in the body of a control structure", func() {
+		for _, source := range []string{
+			"{% macro m() %}{% extends layout %}{% endmacro %}A{{ m() }}B",
+			"{% for i in [1] %}{% extends layout %}{% endfor %}",
+			"{% if true %}{% extends layout %}{% endif %}",
+		} {
+			Context(source, func() {
+				withTemplate(source)
+				BeforeEach(func() {
+					(*context).Set("layout", "/base.html")
+				})
+				It("should return an error", func() {
+					Expect(*returnedErr).To(MatchError(ContainSubstring("tag 'extends' selecting its template at render time must be at the top level of the template")))
+				})
+			})
+		}
+	})
+})
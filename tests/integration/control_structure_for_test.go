@@ -49,6 +49,157 @@ var _ = Context("control structure 'for'", func() {
 		}
 		*returnedResult, *returnedErr = t.ExecuteToString(*context)
 	})
+	Context("recursive loops", func() {
+		BeforeEach(func() {
+			*context = exec.NewContext(map[string]any{
+				"tree": []map[string]any{
+					{"name": "a", "children": []map[string]any{
+						{"name": "b", "children": []map[string]any{
+							{"name": "c"},
+						}},
+					}},
+					{"name": "d"},
+				},
+			})
+		})
+		shouldRender(
+			"{% for item in tree recursive %}{{ item.name }}{% if item.children %}({{ loop(item.children) }}){% endif %}{% endfor %}",
+			"a(b(c))d",
+		)
+		shouldRender(
+			"{% for item in tree recursive %}{{ item.name }}:{{ loop.depth }}:{{ loop.depth0 }}{% if item.children %}[{{ loop(item.children) }}]{% endif %} {% endfor %}",
+			"a:1:0[b:2:1[c:3:2 ] ] d:1:0 ",
+		)
+		shouldRender(
+			"{% for item in tree if item.name != 'b' recursive %}{{ item.name }}{% if item.children %}({{ loop(item.children) }}){% endif %}{% endfor %}",
+			"a()d",
+		)
+		shouldRender(
+			"{% for item in tree recursive %}{{ item.name }}:{{ loop.index }}/{{ loop.length }}{% if item.children %}({{ loop(item.children) }}){% endif %}{% if not loop.last %},{% endif %}{% endfor %}",
+			"a:1/2(b:1/1(c:1/1)),d:2/2",
+		)
+	})
+	Context("loop depth of non-recursive loops", func() {
+		shouldRender(
+			"{% for i in [1, 2] %}{% for j in [3] %}{{ loop.depth }}{{ loop.depth0 }}{% endfor %}{% endfor %}",
+			"1010",
+		)
+	})
+	Context("calling a non-recursive loop", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in [[1]] %}{{ loop(i) }}{% endfor %}",
+			})
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).ToNot(BeNil())
+			Expect((*returnedErr).Error()).To(ContainSubstring("tried to call a non-recursive loop"))
+		})
+	})
+	Context("loop controls", func() {
+		Context("break", func() {
+			shouldRender(
+				"{% for i in [1, 2, 3, 4] %}{% if i == 3 %}{% break %}{% endif %}{{ i }}{% endfor %}",
+				"12",
+			)
+			shouldRender(
+				"{% for i in [1, 2, 3] %}{{ i }}{% break %}never{% endfor %}",
+				"1",
+			)
+			shouldRender(
+				"{% for i in range(10) if i is odd %}{% if i > 5 %}{% break %}{% endif %}{{ i }}:{{ loop.index }}/{{ loop.length }} {% endfor %}",
+				"1:1/5 3:2/5 5:3/5 ",
+			)
+			shouldRender(
+				"{% for i in [1] %}{% break %}{% else %}empty{% endfor %}",
+				"",
+			)
+			shouldRender(
+				"{% for i in [] %}{% break %}{% else %}empty{% endfor %}",
+				"empty",
+			)
+			shouldRender(
+				"{% for i in [1, 2] %}{% for j in ['a', 'b', 'c'] %}{% if j == 'b' %}{% break %}{% endif %}{{ i }}{{ j }} {% endfor %}{% endfor %}",
+				"1a 2a ",
+			)
+		})
+		Context("continue", func() {
+			shouldRender(
+				"{% for i in [1, 2, 3, 4] %}{% if i is even %}{% continue %}{% endif %}{{ i }}{% endfor %}",
+				"13",
+			)
+			shouldRender(
+				"{% for i in [1, 2, 3] if i > 1 %}{% if loop.first %}{% continue %}{% endif %}{{ i }}{% endfor %}",
+				"3",
+			)
+			shouldRender(
+				"{% for i in [1, 2] %}{% for j in ['a', 'b', 'c'] %}{% if j == 'b' %}{% continue %}{% endif %}{{ i }}{{ j }} {% endfor %}{% endfor %}",
+				"1a 1c 2a 2c ",
+			)
+			shouldRender(
+				"{% for i in [1, 2, 3] %}{% with x = i %}{% if x == 2 %}{% continue %}{% endif %}{{ x }}{% endwith %}{% endfor %}",
+				"13",
+			)
+		})
+		Context("outside of a loop", func() {
+			shouldFail := func(message string, templates map[string]string) {
+				BeforeEach(func() {
+					*loader = loaders.MustNewMemoryLoader(templates)
+				})
+				It("should return an error", func() {
+					Expect(*returnedErr).ToNot(BeNil())
+					Expect((*returnedErr).Error()).To(ContainSubstring(message))
+				})
+			}
+			Context("at the top level of the template", func() {
+				shouldFail("Tag 'break' must be used within a loop", map[string]string{
+					"/test": "before{% break %}after",
+				})
+			})
+			Context("in the else branch of a loop", func() {
+				shouldFail("Tag 'continue' must be used within a loop", map[string]string{
+					"/test": "{% for i in [] %}{% else %}{% continue %}{% endfor %}",
+				})
+			})
+			Context("in a macro defined within a loop", func() {
+				shouldFail("Tag 'break' must be used within a loop", map[string]string{
+					"/test": "{% for i in [1, 2] %}{% macro m() %}{% break %}{% endmacro %}{{ m() }}{{ i }}{% endfor %}",
+				})
+			})
+			Context("in a macro called within a loop", func() {
+				shouldFail("Tag 'continue' must be used within a loop", map[string]string{
+					"/test": "{% macro m() %}{% continue %}{% endmacro %}{% for i in [1, 2] %}{{ m() }}{{ i }}{% endfor %}",
+				})
+			})
+			Context("in the body of a call block within a loop", func() {
+				shouldFail("Tag 'break' must be used within a loop", map[string]string{
+					"/test": "{% macro m() %}{{ caller() }}{% endmacro %}{% for i in [1, 2] %}{% call m() %}{% break %}{% endcall %}{% endfor %}",
+				})
+			})
+			Context("in the body of a filter block within a loop", func() {
+				shouldFail("Tag 'break' must be used within a loop", map[string]string{
+					"/test": "{% for i in [1, 2] %}{% filter upper %}{% break %}{% endfilter %}{% endfor %}",
+				})
+			})
+			Context("in a template included within a loop", func() {
+				shouldFail("Tag 'break' must be used within a loop", map[string]string{
+					"/test":  `{% for i in [1, 2] %}{% include "/break" %}{{ i }}{% endfor %}`,
+					"/break": "{% break %}",
+				})
+			})
+		})
+		Context("with arguments", func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier: "{% for i in [1] %}{% continue now %}{% endfor %}",
+				})
+			})
+			It("should return an error", func() {
+				Expect(*returnedErr).ToNot(BeNil())
+				Expect((*returnedErr).Error()).To(ContainSubstring("Tag 'continue' does not take any argument"))
+			})
+		})
+	})
 	Context("loop metadata", func() {
 		shouldRender(
 			"{% for item in [1, 2, 3, 4] if item > 2 %}{{ loop.length }}:{{ loop.index }}:{{ item }}{% if not loop.last %}|{% endif %}{% endfor %}",
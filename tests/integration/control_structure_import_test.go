@@ -0,0 +1,76 @@
+package integration_test
+
+import (
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("control structure 'import'", func() {
+	var (
+		identifier = new(string)
+
+		environment = new(*exec.Environment)
+		loader      = new(loaders.Loader)
+
+		context = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*environment = &exec.Environment{}
+		**environment = *gonja.DefaultEnvironment
+		(*environment).Context = gonja.DefaultEnvironment.Context.Inherit()
+		(*environment).Context.Set("site", "example")
+		*context = exec.NewContext(map[string]any{"user": "bob"})
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	withTemplate := func(source string) {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier:    source,
+				"/macros.html": `{% macro greet() %}{{ site }} {{ user | default("nobody") }}{% endmacro %}`,
+			})
+		})
+	}
+	Context("when importing a template", func() {
+		withTemplate(`{% import "/macros.html" as m %}{{ m.greet() }}`)
+		It("should not give the context to its macros", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("example nobody", *returnedResult)
+		})
+	})
+	Context("when importing a template with context", func() {
+		withTemplate(`{% import "/macros.html" as m with context %}{{ m.greet() }}`)
+		It("should give the context to its macros", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("example bob", *returnedResult)
+		})
+	})
+	Context("when importing names from a template", func() {
+		withTemplate(`{% from "/macros.html" import greet %}{{ greet() }}`)
+		It("should not give the context to its macros", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("example nobody", *returnedResult)
+		})
+	})
+	Context("when importing names from a template with context", func() {
+		withTemplate(`{% from "/macros.html" import greet with context %}{{ greet() }}`)
+		It("should give the context to its macros", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("example bob", *returnedResult)
+		})
+	})
+})
@@ -88,4 +88,73 @@ var _ = Context("control structure 'include'", func() {
 			})
 		})
 	})
+
+	Context("when passing the context", func() {
+		BeforeEach(func() {
+			*context = exec.NewContext(map[string]any{"user": "bob"})
+			(*environment) = &exec.Environment{}
+			**environment = *gonja.DefaultEnvironment
+			(*environment).Context = gonja.DefaultEnvironment.Context.Inherit()
+			(*environment).Context.Set("site", "example")
+		})
+		withInclude := func(tag string) {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier:          tag + "[{{ user | default('nobody') }}]",
+					"/included/template": `{{ site }} {{ user | default("nobody") }} {{ role | default("guest") }}`,
+				})
+			})
+		}
+		Context("by default", func() {
+			withInclude(`{% include "/included/template" %}`)
+			It("should give the context to the included template", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("example bob guest[bob]", *returnedResult)
+			})
+		})
+		Context("and the included template sets variables", func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier:          `{% include "/included/template" %} {{ user }}`,
+					"/included/template": `{% set user = "eve" %}{{ user }}`,
+				})
+			})
+			It("should not change the context of the including template", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("eve bob", *returnedResult)
+			})
+		})
+		Context("without context", func() {
+			withInclude(`{% include "/included/template" without context %}`)
+			It("should only give the global variables", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("example nobody guest[bob]", *returnedResult)
+			})
+		})
+		Context("with variables", func() {
+			withInclude(`{% include "/included/template" with {"role": "admin"} %}`)
+			It("should give the variables along with the context", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("example bob admin[bob]", *returnedResult)
+			})
+		})
+		Context("with variables only", func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier:          `{% include "/included/template" ignore missing with {"role": user ~ "!"} only %}`,
+					"/included/template": `{{ site }} {{ user | default("nobody") }} {{ role }}`,
+				})
+			})
+			It("should only give the variables and the global variables", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("example nobody bob!", *returnedResult)
+			})
+		})
+		Context("with variables which are not a dict", func() {
+			withInclude(`{% include "/included/template" with ["admin"] %}`)
+			It("should return an error", func() {
+				Expect(*returnedErr).To(MatchError(ContainSubstring("variables given to the included template must be a dict")))
+			})
+		})
+	})
 })
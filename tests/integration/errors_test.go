@@ -0,0 +1,126 @@
+package integration_test
+
+import (
+	"errors"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("template errors", func() {
+	var (
+		identifier = new(string)
+		sources    = new(map[string]string)
+
+		returnedErr           = new(error)
+		returnedTemplateError = new(*exec.TemplateError)
+	)
+	BeforeEach(func() {
+		*identifier = "/page.html"
+		*sources = map[string]string{}
+		*returnedTemplateError = nil
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, loaders.MustNewMemoryLoader(*sources), gonja.DefaultEnvironment)
+		if *returnedErr == nil {
+			_, *returnedErr = t.ExecuteToString(exec.NewContext(map[string]any{"user": "Ann"}))
+		}
+		errors.As(*returnedErr, returnedTemplateError)
+	})
+	Context("when the template has a syntax error", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "Hello\n  {{ user. }}"
+		})
+		It("should return a located error", func() {
+			By("returning an error mentioning the identifier of the template rather than its source")
+			Expect(*returnedErr).To(MatchError(ContainSubstring("failed to parse template '/page.html'")))
+			Expect((*returnedErr).Error()).ToNot(ContainSubstring("Hello"))
+			By("returning a template error")
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/page.html"))
+			Expect((*returnedTemplateError).Line).To(Equal(2))
+			Expect((*returnedTemplateError).Column).To(Equal(12))
+			Expect((*returnedTemplateError).Source).To(Equal("  {{ user. }}"))
+			Expect((*returnedTemplateError).Stack).To(BeEmpty())
+			By("describing it with a caret under the error")
+			Expect((*returnedTemplateError).Details()).To(HavePrefix("/page.html:2:12: "))
+			Expect((*returnedTemplateError).Details()).To(HaveSuffix("\n    {{ user. }}\n             ^"))
+		})
+	})
+	Context("when the template fails to render", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "Hello\n{{ user | unknown }}"
+		})
+		It("should return a located error", func() {
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/page.html"))
+			Expect((*returnedTemplateError).Line).To(Equal(2))
+			Expect((*returnedTemplateError).Source).To(Equal("{{ user | unknown }}"))
+		})
+	})
+	Context("when an included template fails to render", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "Hello\n{% include '/included.html' %}"
+			(*sources)["/included.html"] = "{{ user | unknown }}"
+		})
+		It("should return an error located in the included template with the include in its stack", func() {
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/included.html"))
+			Expect((*returnedTemplateError).Line).To(Equal(1))
+			Expect((*returnedTemplateError).Source).To(Equal("{{ user | unknown }}"))
+			Expect((*returnedTemplateError).Stack).To(Equal([]exec.Frame{
+				{Kind: "include", Name: "/included.html", Template: "/page.html", Line: 2, Column: 1},
+			}))
+			Expect((*returnedTemplateError).Details()).To(HaveSuffix("\n  in include '/included.html' at /page.html:2:1"))
+		})
+	})
+	Context("when a macro fails to render", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "{% import '/macros.html' as macros %}\n\n{{ macros.greet(user) }}"
+			(*sources)["/macros.html"] = "{% macro greet(name) %}{{ name | unknown }}{% endmacro %}"
+		})
+		It("should return an error located in the macro with its call in the stack", func() {
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/macros.html"))
+			Expect((*returnedTemplateError).Stack).To(HaveLen(1))
+			Expect((*returnedTemplateError).Stack[0].Kind).To(Equal("macro"))
+			Expect((*returnedTemplateError).Stack[0].Name).To(Equal("greet"))
+			Expect((*returnedTemplateError).Stack[0].Template).To(Equal("/page.html"))
+			Expect((*returnedTemplateError).Stack[0].Line).To(Equal(3))
+		})
+	})
+	Context("when an extended template fails to render", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "{% extends '/base.html' %}"
+			(*sources)["/base.html"] = "<title>\n{{ user | unknown }}</title>"
+		})
+		It("should return an error located in the extended template with the extends in its stack", func() {
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/base.html"))
+			Expect((*returnedTemplateError).Line).To(Equal(2))
+			Expect((*returnedTemplateError).Source).To(Equal("{{ user | unknown }}</title>"))
+			Expect((*returnedTemplateError).Stack).To(Equal([]exec.Frame{
+				{Kind: "extends", Name: "/base.html", Template: "/page.html", Line: 1, Column: 1},
+			}))
+		})
+	})
+	Context("when an extended template has a syntax error", func() {
+		BeforeEach(func() {
+			(*sources)["/page.html"] = "{% extends '/base.html' %}"
+			(*sources)["/base.html"] = "{{ user. }}"
+		})
+		It("should return an error located in the extended template with the extends in its stack", func() {
+			Expect(*returnedTemplateError).ToNot(BeNil())
+			Expect((*returnedTemplateError).Template).To(Equal("/base.html"))
+			Expect((*returnedTemplateError).Line).To(Equal(1))
+			Expect((*returnedTemplateError).Stack).To(HaveLen(1))
+			Expect((*returnedTemplateError).Stack[0].Kind).To(Equal("extends"))
+			Expect((*returnedTemplateError).Stack[0].Template).To(Equal("/page.html"))
+		})
+	})
+})
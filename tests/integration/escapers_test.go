@@ -0,0 +1,266 @@
+package integration_test
+
+import (
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/builtins"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("escapers", func() {
+	var (
+		identifier = new(string)
+
+		configuration = new(*config.Config)
+		sources       = new(map[string]string)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*configuration = config.New()
+		*sources = map[string]string{}
+	})
+	JustBeforeEach(func() {
+		environment := *gonja.DefaultEnvironment
+		environment.Escapers = exec.NewEscaperSet(map[string]exec.Escaper{
+			"upper": strings.ToUpper,
+		}).Update(builtins.Escapers)
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, *configuration, loaders.MustNewMemoryLoader(*sources), &environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(exec.NewContext(map[string]any{
+			"value": `it's <"a" & b>`,
+			"cost":  "50% of $10_000",
+			"query": exec.AsEscapedValue(`'x'`, "sql"),
+		}))
+	})
+	shouldRender := func(template, result string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				(*sources)[*identifier] = template
+			})
+			It("should return the expected rendered content", func() {
+				By("not returning any error")
+				Expect(*returnedErr).To(BeNil())
+				By("returning the expected result")
+				AssertPrettyDiff(result, *returnedResult)
+			})
+		})
+	}
+	Context("when selecting an escaper in the configuration", func() {
+		BeforeEach(func() {
+			(*configuration).AutoEscape = true
+		})
+		Context("html", func() {
+			shouldRender(`{{ value }}`, `it&#39;s &lt;&#34;a&#34; &amp; b&gt;`)
+		})
+		Context("shell", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "shell"
+			})
+			shouldRender(`echo {{ value }}`, `echo 'it'"'"'s <"a" & b>'`)
+		})
+		Context("sql", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "sql"
+			})
+			shouldRender(`SELECT * FROM t WHERE v = {{ value }}`, `SELECT * FROM t WHERE v = 'it''s <"a" & b>'`)
+		})
+		Context("json", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "json"
+			})
+			shouldRender(`{"v": {{ value }}, "n": {{ 42 }}}`, `{"v": "it's \u003c\"a\" \u0026 b\u003e", "n": 42}`)
+		})
+		Context("yaml", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "yaml"
+			})
+			shouldRender(`v: {{ "a: b\n- c" }}`, `v: "a: b\n- c"`)
+		})
+		Context("latex", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "latex"
+			})
+			shouldRender(`{{ cost }} {{ '\\{x}' }}`, `50\% of \$10\_000 \textbackslash{}\{x\}`)
+		})
+		Context("a custom escaper", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "upper"
+			})
+			shouldRender(`{{ 'loud' }} quiet`, `LOUD quiet`)
+		})
+		Context("an unknown escaper", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "unknown"
+				(*sources)[*identifier] = `{{ value }}`
+			})
+			It("should return an error", func() {
+				Expect(*returnedErr).To(MatchError(ContainSubstring("escaper 'unknown' not found")))
+			})
+		})
+	})
+	Context("when selecting an escaper by extension", func() {
+		BeforeEach(func() {
+			*identifier = "/script.sh"
+			(*configuration).EscapersByExtension = map[string]string{".sh": "shell"}
+			(*sources)["/page.html"] = `<p>{{ value }}</p>`
+			(*sources)["/notes.txt"] = `{{ value }}`
+		})
+		shouldRender(`rm {{ value }}`, `rm 'it'"'"'s <"a" & b>'`)
+		Context("when including a template with another extension", func() {
+			BeforeEach(func() {
+				(*configuration).EscapersByExtension[".html"] = "html"
+			})
+			shouldRender(
+				`{% include "/page.html" %} {% include "/notes.txt" %}`,
+				`<p>it&#39;s &lt;&#34;a&#34; &amp; b&gt;</p> it's <"a" & b>`,
+			)
+			Context("from an autoescape block", func() {
+				shouldRender(
+					`{% autoescape "sql" %}{{ value }} {% include "/notes.txt" %}{% endautoescape %}`,
+					`'it''s <"a" & b>' it's <"a" & b>`,
+				)
+			})
+		})
+		Context("when importing macros from a template with another extension", func() {
+			BeforeEach(func() {
+				(*sources)["/macros.txt"] = `{% macro show(v) %}[{{ v }}]{% endmacro %}`
+			})
+			shouldRender(
+				`{% import "/macros.txt" as m %}{% from "/macros.txt" import show %}{{ m.show(value) }} {{ show(value) }}`,
+				`'[it'"'"'s <"a" & b>]' '[it'"'"'s <"a" & b>]'`,
+			)
+		})
+	})
+	Context("when switching escapers with autoescape blocks", func() {
+		shouldRender(
+			`{{ value }}|{% autoescape "shell" %}{{ value }}{% autoescape "sql" %}|{{ value }}{% endautoescape %}{% endautoescape %}|{% autoescape true %}{{ value }}{% endautoescape %}`,
+			`it's <"a" & b>|'it'"'"'s <"a" & b>'|'it''s <"a" & b>'|it&#39;s &lt;&#34;a&#34; &amp; b&gt;`,
+		)
+		Context("when disabling autoescaping", func() {
+			BeforeEach(func() {
+				(*configuration).AutoEscape = true
+				(*configuration).Escaper = "shell"
+			})
+			shouldRender(`{% autoescape false %}{{ value }}{% endautoescape %}`, `it's <"a" & b>`)
+		})
+	})
+	Context("when marking values as safe", func() {
+		BeforeEach(func() {
+			(*configuration).AutoEscape = true
+			(*configuration).Escaper = "shell"
+		})
+		Context("with the safe filter", func() {
+			shouldRender(`{{ value | safe }}`, `it's <"a" & b>`)
+		})
+		Context("with the escape filter of the same escaper", func() {
+			shouldRender(`{{ value | escape }}`, `'it'"'"'s <"a" & b>'`)
+		})
+		Context("when escaped for another escaper", func() {
+			shouldRender(
+				`{{ query }} {% autoescape "sql" %}{{ query }}{% endautoescape %}`,
+				`''"'"'x'"'"'' 'x'`,
+			)
+		})
+		Context("with the tojson filter", func() {
+			shouldRender(`echo {{ {"v": "a b"} | tojson }}`, `echo '{"v":"a b"}'`)
+			Context("when escaping for sql", func() {
+				BeforeEach(func() {
+					(*configuration).Escaper = "sql"
+				})
+				shouldRender(`SELECT {{ ["it's"] | tojson }}`, `SELECT '["it\u0027s"]'`)
+			})
+		})
+		Context("with the output of a macro", func() {
+			shouldRender(
+				`{% macro m(v) %}[{{ v }}]{% endmacro %}{{ m("a b") }} {% autoescape "sql" %}{{ m("a b") }}{% endautoescape %}`,
+				`['a b'] '[''a b'']'`,
+			)
+		})
+		Context("with the join filter", func() {
+			BeforeEach(func() {
+				(*configuration).Escaper = "latex"
+			})
+			shouldRender(`{{ ["a_b", "c" | safe] | join("&") }}`, `a\_b\&c`)
+		})
+	})
+})
+
+var _ = Context("autoescape selection", func() {
+	var (
+		identifier = new(string)
+		sources    = new(map[string]string)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*sources = map[string]string{
+			"/base.html":   `<b>{{ value }}</b>{% block content %}[{{ value }}]{% endblock %}`,
+			"/macros.html": `{% macro show(v) %}<i>{{ v }}</i>{% endmacro %}`,
+			"/note.txt":    `{{ value }}`,
+			"/data.yaml":   `v: {{ value }}`,
+		}
+	})
+	JustBeforeEach(func() {
+		configuration := config.New()
+		configuration.AutoEscapeFunc = config.SelectAutoEscape([]string{"html", ".XML"}, []string{"txt", "yaml"}, false)
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, configuration, loaders.MustNewMemoryLoader(*sources), gonja.DefaultEnvironment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(exec.NewContext(map[string]any{
+			"value": "<&>",
+		}))
+	})
+	shouldRender := func(name, template, result string) {
+		Context(name, func() {
+			BeforeEach(func() {
+				*identifier = name
+				(*sources)[name] = template
+			})
+			It("should return the expected rendered content", func() {
+				By("not returning any error")
+				Expect(*returnedErr).To(BeNil())
+				By("returning the expected result")
+				AssertPrettyDiff(result, *returnedResult)
+			})
+		})
+	}
+	Context("when selecting autoescaping by extension", func() {
+		shouldRender("/page.html", `{{ value }}`, `&lt;&amp;&gt;`)
+		shouldRender("/page.XML", `{{ value }}`, `&lt;&amp;&gt;`)
+		shouldRender("/page.txt", `{{ value }}`, `<&>`)
+		shouldRender("/page", `{{ value }}`, `<&>`)
+	})
+	Context("when including templates", func() {
+		shouldRender("/page.html", `{{ value }} {% include "/note.txt" %} {% include "/data.yaml" %}`, `&lt;&amp;&gt; <&> v: <&>`)
+		shouldRender("/page.txt", `{{ value }} {% include "/base.html" %}`, `<&> <b>&lt;&amp;&gt;</b>[&lt;&amp;&gt;]`)
+	})
+	Context("when extending templates", func() {
+		shouldRender(
+			"/page.txt",
+			`{% extends "/base.html" %}{% block content %}{{ value }}{{ super() }}{% endblock %}`,
+			`<b>&lt;&amp;&gt;</b><&>[&lt;&amp;&gt;]`,
+		)
+	})
+	Context("when importing macros", func() {
+		shouldRender(
+			"/page.txt",
+			`{% import "/macros.html" as m %}{% from "/macros.html" import show %}{{ m.show(value) }} {{ show(value) }} {{ value }}`,
+			`<i>&lt;&amp;&gt;</i> <i>&lt;&amp;&gt;</i> <&>`,
+		)
+	})
+})
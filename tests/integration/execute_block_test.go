@@ -0,0 +1,144 @@
+package integration_test
+
+import (
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("executing a block", func() {
+	var (
+		identifier = new(string)
+		name       = new(string)
+		data       = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	loader := loaders.MustNewMemoryLoader(map[string]string{
+		"/base.html": strings.Join([]string{
+			`<html><title>{% block title %}Site{% endblock %}</title>`,
+			`<main>{% block content %}{% block list %}<ul></ul>{% endblock %}{% endblock %}</main>`,
+			`<footer>{% block footer %}{{ year }}{% endblock %}</footer></html>`,
+		}, ""),
+		"/macros.html": `{% macro link(name) %}<a>{{ name }}</a>{% endmacro %}`,
+		"/page.html": strings.Join([]string{
+			`{% extends "/base.html" %}`,
+			`{% import "/macros.html" as macros %}{% from "/macros.html" import link %}`,
+			`{% set separator = ", " %}{% macro item(name) %}<li>{{ name }}</li>{% endmacro %}`,
+			`{% block links %}{{ macros.link(title) }}{{ separator }}{{ link("home") }}{{ item("a") }}{% endblock %}`,
+			`{% block title %}{{ title }} - {{ super() }}{% endblock %}`,
+			`{% block list %}<ul>{% for item in items %}<li>{{ item }}</li>{% endfor %}</ul>{% endblock %}`,
+			`{% block summary %}{{ self.title() }}: {{ items | length }} items{% endblock %}`,
+		}, ""),
+		"/dynamic.html": strings.Join([]string{
+			`{% extends layout %}{% set separator = " | " %}`,
+			`{% block title %}{{ title }}{{ separator }}{{ super() }}{% endblock %}`,
+		}, ""),
+	})
+	BeforeEach(func() {
+		*identifier = "/page.html"
+		*data = exec.NewContext(map[string]any{
+			"title":  "Shop",
+			"items":  []string{"a", "b"},
+			"year":   2026,
+			"layout": "/base.html",
+		})
+	})
+	JustBeforeEach(func() {
+		template, err := exec.NewTemplate(*identifier, gonja.DefaultConfig, loader, gonja.DefaultEnvironment)
+		Expect(err).To(BeNil())
+		var output strings.Builder
+		*returnedErr = template.ExecuteBlock(*name, &output, *data)
+		*returnedResult = output.String()
+	})
+	Context("when the block is overridden and calls super()", func() {
+		BeforeEach(func() {
+			*name = "title"
+		})
+		It("should render the overriding block only", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("Shop - Site", *returnedResult)
+		})
+	})
+	Context("when the block is defined by the extended template only", func() {
+		BeforeEach(func() {
+			*name = "footer"
+		})
+		It("should render the block of the extended template", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("2026", *returnedResult)
+		})
+	})
+	Context("when the block holds an overridden block", func() {
+		BeforeEach(func() {
+			*name = "content"
+		})
+		It("should render the nested block as overridden", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("<ul><li>a</li><li>b</li></ul>", *returnedResult)
+		})
+	})
+	Context("when the block uses self", func() {
+		BeforeEach(func() {
+			*name = "summary"
+		})
+		It("should render the other blocks as overridden", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("Shop - Site: 2 items", *returnedResult)
+		})
+	})
+	Context("when the block uses the macros, imports and assignments at the top level of the template", func() {
+		BeforeEach(func() {
+			*name = "links"
+		})
+		It("should evaluate them beforehand without rendering anything else", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("<a>Shop</a>, <a>home</a><li>a</li>", *returnedResult)
+		})
+	})
+	Context("when the block does not exist", func() {
+		BeforeEach(func() {
+			*name = "missing"
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("no such block in template '/page.html'")))
+		})
+	})
+	Context("when the template extends a template selected at render time", func() {
+		BeforeEach(func() {
+			*identifier = "/dynamic.html"
+		})
+		Context("and the block is overridden and calls super()", func() {
+			BeforeEach(func() {
+				*name = "title"
+			})
+			It("should render the block of the selected template as super()", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("Shop | Site", *returnedResult)
+			})
+		})
+		Context("and the block is defined by the selected template only", func() {
+			BeforeEach(func() {
+				*name = "footer"
+			})
+			It("should render the block of the selected template", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("2026", *returnedResult)
+			})
+		})
+		Context("and the block does not exist", func() {
+			BeforeEach(func() {
+				*name = "missing"
+			})
+			It("should return an error", func() {
+				Expect(*returnedErr).To(MatchError(ContainSubstring("no such block in template '/dynamic.html'")))
+			})
+		})
+	})
+})
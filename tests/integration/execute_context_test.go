@@ -0,0 +1,145 @@
+package integration_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("execute with a context", func() {
+	var (
+		identifier = new(string)
+		block      = new(string)
+
+		environment = new(*exec.Environment)
+		loader      = new(loaders.Loader)
+
+		ctx     = new(context.Context)
+		cancel  = new(context.CancelFunc)
+		data    = new(*exec.Context)
+		elapsed = new(time.Duration)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*block = ""
+		*environment = gonja.DefaultEnvironment
+		*loader = loaders.MustNewMemoryLoader(nil)
+		*ctx, *cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		*data = exec.NewContext(map[string]any{
+			"slow": func() string {
+				time.Sleep(10 * time.Millisecond)
+				return "."
+			},
+		})
+	})
+	AfterEach(func() {
+		(*cancel)()
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		output := new(bytes.Buffer)
+		start := time.Now()
+		if *block != "" {
+			*returnedErr = t.ExecuteBlockContext(*ctx, *block, output, *data)
+		} else {
+			*returnedErr = t.ExecuteContext(*ctx, output, *data)
+		}
+		*elapsed = time.Since(start)
+		*returnedResult = output.String()
+	})
+	shouldBeCancelled := func() {
+		It("should stop rendering and return a cancellation error", func() {
+			By("returning an error")
+			Expect(*returnedErr).ToNot(BeNil())
+			By("returning a typed cancellation error")
+			cancelled := new(exec.CancelledError)
+			Expect(errors.As(*returnedErr, &cancelled)).To(BeTrue())
+			Expect(cancelled.Template).To(Equal(*identifier))
+			Expect(errors.Is(*returnedErr, context.DeadlineExceeded)).To(BeTrue())
+			By("stopping shortly after the deadline")
+			Expect(*elapsed).To(BeNumerically("<", time.Second))
+		})
+	}
+	Context("when the template renders within the deadline", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in range(3) %}{{ slow() }}{% endfor %}",
+			})
+		})
+		It("should return the expected rendered content", func() {
+			By("not returning any error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the expected result")
+			AssertPrettyDiff("...", *returnedResult)
+		})
+	})
+	Context("when a loop calls an expensive function", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in range(1000) %}{{ slow() }}{% endfor %}",
+			})
+		})
+		shouldBeCancelled()
+	})
+	Context("when iterating over a huge range", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in range(1000000000) %}{{ i }}{% endfor %}",
+			})
+		})
+		shouldBeCancelled()
+	})
+	Context("when the expensive call happens within a macro", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% macro dots() %}{% for i in range(1000) %}{{ slow() }}{% endfor %}{% endmacro %}{{ dots() }}",
+			})
+		})
+		shouldBeCancelled()
+	})
+	Context("when the expensive call happens within an included template", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% include '/included' %}",
+				"/included": "{% for i in range(1000) %}{{ slow() }}{% endfor %}",
+			})
+		})
+		shouldBeCancelled()
+	})
+	Context("when the expensive call happens within an executed block", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% block dots %}{% for i in range(1000) %}{{ slow() }}{% endfor %}{% endblock %}",
+			})
+			*block = "dots"
+		})
+		shouldBeCancelled()
+	})
+	Context("when the context is already cancelled", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "never rendered",
+			})
+			(*cancel)()
+		})
+		It("should not render anything", func() {
+			Expect(errors.Is(*returnedErr, context.Canceled)).To(BeTrue())
+			Expect(*returnedResult).To(BeEmpty())
+		})
+	})
+})
@@ -193,4 +193,21 @@ var _ = Context("expressions", func() {
 			Entry("empty list still goes else", `{% set v = 'X' if [] else 'Y' %}{{ v }}`, "Y", nil),
 		)
 	})
+	Context("when multiplying strings", func() {
+		DescribeTable(
+			"repeats them by integers and integral floats only",
+			func(template, expected string) {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{*identifier: template})
+				t, err := exec.NewTemplate(*identifier, *configuration, *loader, *environment)
+				Expect(err).To(BeNil())
+				out, err := t.ExecuteToString(*context)
+				Expect(err).To(BeNil())
+				AssertPrettyDiff(expected, out)
+			},
+			Entry("integer", `{{ 'ab' * 2 }}`, "abab"),
+			Entry("power", `{{ 'ab' * 2**1 }}`, "abab"),
+			Entry("integral float", `{{ 'ab' * 2.0 }}`, "abab"),
+			Entry("fractional float", `{{ 'ab' * 2.5 }}`, "0.0"),
+		)
+	})
 })
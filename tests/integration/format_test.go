@@ -0,0 +1,218 @@
+package integration_test
+
+import (
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/format"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("formatting", func() {
+	var (
+		cfg    = new(*config.Config)
+		source = new(string)
+
+		returnedSource = new(string)
+		returnedErr    = new(error)
+	)
+	loader := loaders.MustNewMemoryLoader(map[string]string{
+		"/base.html": `{% block content %}{% endblock %}`,
+	})
+	BeforeEach(func() {
+		*cfg = gonja.DefaultConfig
+	})
+	JustBeforeEach(func() {
+		*returnedSource, *returnedErr = format.Source("/page.html", *source, *cfg, loader, gonja.DefaultEnvironment)
+	})
+	// shouldFormat formats the template, expecting the given source which must be left as is when formatted again
+	shouldFormat := func(template string, expected string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				*source = template
+			})
+			It("should return the canonical source", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff(expected, *returnedSource)
+				By("leaving it as is when formatted again")
+				again, err := format.Source("/page.html", *returnedSource, *cfg, loader, gonja.DefaultEnvironment)
+				Expect(err).To(BeNil())
+				AssertPrettyDiff(expected, again)
+			})
+		})
+	}
+	Context("when delimiters are not spaced", func() {
+		shouldFormat(
+			`{{user.name|upper}} {%set x=[1,2]%}{{x[0]+x[1]*2}}`,
+			`{{ user.name | upper }} {% set x = [1, 2] %}{{ x[0] + x[1] * 2 }}`,
+		)
+	})
+	Context("when control structures are nested", func() {
+		shouldFormat(strings.Join([]string{
+			`<ul>`,
+			`{%for row in rows if row%}`,
+			`      {%if row.visible%}`,
+			`<li>{{row}}</li>`,
+			`{%elif row.hidden is defined%}`,
+			`{%else%}`,
+			`    {#not shown#}`,
+			`    {%endif%}`,
+			`{%else%}`,
+			`{%endfor%}`,
+			`</ul>`,
+		}, "\n")+"\n", strings.Join([]string{
+			`<ul>`,
+			`{% for row in rows if row %}`,
+			`      {% if row.visible %}`,
+			`<li>{{ row }}</li>`,
+			`{% elif row.hidden is defined %}`,
+			`{% else %}`,
+			`    {#not shown#}`,
+			`    {% endif %}`,
+			`{% else %}`,
+			`{% endfor %}`,
+			`</ul>`,
+		}, "\n")+"\n")
+	})
+	Context("when the whitespaces before the tags of nested control structures are stripped", func() {
+		shouldFormat(strings.Join([]string{
+			`{%for row in rows%}`,
+			`      {%-if row%}`,
+			`{%-if row.visible%}`,
+			`<li>{{row}}</li>`,
+			`{%-endif%}`,
+			`    {#-not shown#}`,
+			`  {%+endif%}`,
+			`{%-endfor%}`,
+		}, "\n")+"\n", strings.Join([]string{
+			`{% for row in rows %}`,
+			`  {%- if row %}`,
+			`    {%- if row.visible %}`,
+			`<li>{{ row }}</li>`,
+			`    {%- endif %}`,
+			`    {#-not shown#}`,
+			`  {%+ endif %}`,
+			`{%- endfor %}`,
+		}, "\n")+"\n")
+	})
+	Context("when the whitespaces before the tags of nested control structures are kept", func() {
+		BeforeEach(func() {
+			*source = "{% if true %}\n{% if true %}\nx\n{% endif %}\n{% endif %}\n"
+		})
+		It("should render the same output", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff(*source, *returnedSource)
+		})
+	})
+	Context("when the template has whitespace control markers", func() {
+		shouldFormat(
+			"{%- if x -%}\n  {{-x-}}\n{%+endif+%} {#- note -#}",
+			"{%- if x -%}\n  {{- x -}}\n{%+ endif +%} {#- note -#}",
+		)
+	})
+	Context("when the template has raw blocks", func() {
+		shouldFormat(
+			"{% if x %}\n{%raw%}  {{x}}\n    {%endraw%}\n{% endif %}",
+			"{% if x %}\n{% raw %}  {{x}}\n    {% endraw %}\n{% endif %}",
+		)
+	})
+	Context("when parentheses are required by precedence", func() {
+		shouldFormat(
+			`{{ (a+b)*-(c) }}{{ not(a or b) }}{{ (a or b)|default(1) }}{{ a-(b-c) }}{{ a is not defined }}{{ a not in (1,) }}`,
+			`{{ (a + b) * -c }}{{ not (a or b) }}{{ (a or b) | default(1) }}{{ a - (b - c) }}{{ a is not defined }}{{ a not in (1,) }}`,
+		)
+	})
+	Context("when the template uses every kind of literal", func() {
+		shouldFormat(
+			`{{ {'a':1.5,"b":[True,None]} }}{{ 'say "hi"' }}{{ f(1,k=2)(3) }}{{ x[1:] if y else z }}`,
+			`{{ {"a": 1.5, "b": [True, None]} }}{{ 'say "hi"' }}{{ f(1, k=2)(3) }}{{ x[1:] if y else z }}`,
+		)
+	})
+	Context("when the template defines and calls macros", func() {
+		shouldFormat(
+			"{%macro item(value,label='-')%}\n{{value}}\n{%endmacro%}\n{%call(x)item(1)%}{{x}}{%endcall%}",
+			"{% macro item(value, label=\"-\") %}\n{{ value }}\n{% endmacro %}\n{% call(x) item(1) %}{{ x }}{% endcall %}",
+		)
+	})
+	Context("when the template extends, includes and imports others", func() {
+		shouldFormat(
+			"{%extends '/base.html'%}\n{%block content%}\n{%include 'x.html' ignore missing%}{%from 'm.html' import a as b,c%}\n{%endblock%}",
+			"{% extends \"/base.html\" %}\n{% block content %}\n{% include \"x.html\" ignore missing %}{% from \"m.html\" import a as b, c %}\n{% endblock %}",
+		)
+	})
+	Context("when the template extends a template selected at render time", func() {
+		shouldFormat(
+			"{%extends 'a.html' if mobile else layout%}",
+			"{% extends \"a.html\" if mobile else layout %}",
+		)
+	})
+	Context("when the template includes others with or without context", func() {
+		shouldFormat(
+			"{%include 'a.html' with context%}{%include 'b.html' without context%}{%include 'c.html' with {'x':1} only%}{%include 'd.html' only%}",
+			"{% include \"a.html\" %}{% include \"b.html\" without context %}{% include \"c.html\" with {\"x\": 1} only %}{% include \"d.html\" without context %}",
+		)
+	})
+	Context("when the template has other control structures", func() {
+		shouldFormat(
+			"{%with a=1%}{%filter upper|trim%}{%do x.append(a)%}{%endfilter%}{%endwith%}{%autoescape false%}{%endautoescape%}",
+			"{% with a = 1 %}{% filter upper | trim %}{% do x.append(a) %}{% endfilter %}{% endwith %}{% autoescape false %}{% endautoescape %}",
+		)
+	})
+	Context("when the template names several variables or macros", func() {
+		shouldFormat(
+			"{%with z=1,a=2%}{{z}}{%endwith%}{%from 'm.html' import z,b as a,c%}",
+			"{% with z = 1, a = 2 %}{{ z }}{% endwith %}{% from \"m.html\" import z, b as a, c %}",
+		)
+	})
+	Context("when the template translates messages", func() {
+		shouldFormat(
+			"{%trans trimmed,user,count=n|length%}\n  Hello {{user}}\n{%pluralize%}\n  Hello all\n{%endtrans%}",
+			"{% trans trimmed, user, count = n | length %}\n  Hello {{ user }}\n{% pluralize %}\n  Hello all\n{% endtrans %}",
+		)
+	})
+	Context("when the template has a syntax error", func() {
+		BeforeEach(func() {
+			*source = `{{ user. }}`
+		})
+		It("should return a located error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("failed to parse template '/page.html'")))
+		})
+	})
+	Context("when line statements are enabled", func() {
+		BeforeEach(func() {
+			*cfg = gonja.DefaultConfig.Inherit()
+			(*cfg).LineStatementPrefix = "#"
+			*source = "# if x\n{{ x }}\n# endif"
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("line statements and line comments are not supported")))
+		})
+	})
+	Context("when whitespaces are stripped from blocks", func() {
+		BeforeEach(func() {
+			*cfg = gonja.DefaultConfig.Inherit()
+			(*cfg).TrimBlocks = true
+			(*cfg).LeftStripBlocks = true
+			*source = "{% for x in y %}\n    {% if x %}\n{{x}}\n    {% endif %}\n{% endfor %}\n"
+		})
+		It("should keep the whitespaces of the template", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("{% for x in y %}\n  {% if x %}\n{{ x }}\n  {% endif %}\n{% endfor %}\n", *returnedSource)
+		})
+		It("should render the same output", func() {
+			render := func(source string) string {
+				t, err := exec.NewTemplate("/page.html", *cfg, loaders.MustNewMemoryLoader(map[string]string{"/page.html": source}), gonja.DefaultEnvironment)
+				Expect(err).To(BeNil())
+				output, err := t.ExecuteToString(exec.NewContext(map[string]any{"y": []int{1, 0, 2}}))
+				Expect(err).To(BeNil())
+				return output
+			}
+			Expect(render(*returnedSource)).To(Equal(render(*source)))
+		})
+	})
+})
@@ -0,0 +1,279 @@
+package integration_test
+
+import (
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/config"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/i18n"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// catalogTranslator translates messages from a catalog keyed by message, plural forms being keyed by plural message
+type catalogTranslator map[string]string
+
+func (c catalogTranslator) Gettext(message string) string {
+	if translated, ok := c[message]; ok {
+		return translated
+	}
+	return message
+}
+
+func (c catalogTranslator) NGettext(singular, plural string, n int) string {
+	if n == 1 {
+		return c.Gettext(singular)
+	}
+	return c.Gettext(plural)
+}
+
+var _ = Context("i18n", func() {
+	var (
+		identifier = new(string)
+
+		translator    = new(exec.Translator)
+		configuration = new(*config.Config)
+		loader        = new(loaders.Loader)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*translator = catalogTranslator{
+			"Hello %(user)s!":                      "Bonjour %(user)s !",
+			"Goodbye":                              "Au revoir",
+			"%(num)s apple":                        "%(num)s pomme",
+			"%(num)s apples":                       "%(num)s pommes",
+			"%(count)s message for %(user)s":       "%(count)s message pour %(user)s",
+			"%(count)s messages for %(user)s":      "%(count)s messages pour %(user)s",
+			"100%% sure":                           "sûr à 100%%",
+			"Welcome back, %(user)s. See you soon": "Bon retour, %(user)s. À bientôt",
+		}
+		*configuration = config.New()
+	})
+	JustBeforeEach(func() {
+		environment := *gonja.DefaultEnvironment
+		environment.Translator = *translator
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, *configuration, *loader, &environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(exec.NewContext(map[string]any{
+			"user":   "<Ann>",
+			"apples": 3,
+			"one":    1,
+		}))
+	})
+	shouldRender := func(template, result string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier: template,
+				})
+			})
+			It("should return the expected rendered content", func() {
+				By("not returning any error")
+				Expect(*returnedErr).To(BeNil())
+				By("returning the expected result")
+				AssertPrettyDiff(result, *returnedResult)
+			})
+		})
+	}
+	shouldFail := func(template, err string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier: template,
+				})
+			})
+			It("should return the expected error", func() {
+				Expect(*returnedErr).ToNot(BeNil())
+				Expect((*returnedErr).Error()).To(MatchRegexp(err))
+			})
+		})
+	}
+	Context("trans", func() {
+		shouldRender(`{% trans %}Goodbye{% endtrans %}`, `Au revoir`)
+		shouldRender(`{% trans %}Hello {{ user }}!{% endtrans %}`, `Bonjour <Ann> !`)
+		shouldRender(`{% trans user=user | lower %}Hello {{ user }}!{% endtrans %}`, `Bonjour <ann> !`)
+		shouldRender(`{% trans %}Untranslated {{ user }}{% endtrans %}`, `Untranslated <Ann>`)
+		shouldRender(`{% trans %}100% sure{% endtrans %}`, `100% sure`)
+		shouldRender(`{% trans user %}100% sure{% endtrans %}`, `100% sure`)
+		shouldRender(`{% trans %}100% sure, {{ user }}{% endtrans %}`, `100% sure, <Ann>`)
+		shouldRender(
+			"{% trans trimmed %}\n  Welcome back,\n  {{ user }}.\n  See you soon\n{% endtrans %}",
+			`Bon retour, <Ann>. À bientôt`,
+		)
+		Context("when pluralizing", func() {
+			shouldRender(`{% trans count=apples %}{{ count }} apple{% pluralize %}{{ count }} apples{% endtrans %}`, `3 apples`)
+			shouldRender(`{% trans count=one %}{{ count }} apple{% pluralize %}{{ count }} apples{% endtrans %}`, `1 apple`)
+			shouldRender(`{% trans apples %}{{ apples }} apple{% pluralize %}{{ apples }} apples{% endtrans %}`, `3 apples`)
+			shouldRender(
+				`{% trans count=one, user=user %}{{ count }} message for {{ user }}{% pluralize %}{{ count }} messages for {{ user }}{% endtrans %}`,
+				`1 message pour <Ann>`,
+			)
+			shouldRender(
+				`{% trans %}{{ user }} has {{ apples }} apple{% pluralize apples %}{{ user }} has {{ apples }} apples{% endtrans %}`,
+				`<Ann> has 3 apples`,
+			)
+		})
+		Context("when autoescaping", func() {
+			BeforeEach(func() {
+				(*configuration).AutoEscape = true
+			})
+			shouldRender(`{% trans %}Hello {{ user }}!{% endtrans %}`, `Bonjour &lt;Ann&gt; !`)
+		})
+		Context("when the environment has no translator", func() {
+			BeforeEach(func() {
+				*translator = nil
+			})
+			shouldRender(`{% trans %}Hello {{ user }}!{% endtrans %}`, `Hello <Ann>!`)
+			shouldRender(`{% trans n=apples %}{{ n }} apple{% pluralize %}{{ n }} apples{% endtrans %}`, `3 apples`)
+		})
+		Context("when the template is invalid", func() {
+			shouldFail(`{% trans %}Hello {{ user.name }}{% endtrans %}`, `Only simple variables are allowed in trans blocks`)
+			shouldFail(`{% trans %}{% if user %}Hello{% endif %}{% endtrans %}`, `Control structures are not allowed in trans blocks`)
+			shouldFail(`{% trans %}apple{% pluralize %}apples{% endtrans %}`, `A count variable is required to pluralize`)
+			shouldFail(`{% trans %}Hello{% endtrans user %}`, `Arguments not allowed here`)
+		})
+	})
+	Context("gettext functions", func() {
+		shouldRender(`{{ _("Goodbye") }} {{ gettext("Goodbye") }}`, `Au revoir Au revoir`)
+		shouldRender(`{{ _("Hello %(user)s!", user=user) }}`, `Bonjour <Ann> !`)
+		shouldRender(`{{ _("100%% sure") }}`, `sûr à 100%`)
+		shouldRender(`{{ ngettext("%(num)s apple", "%(num)s apples", apples) }}`, `3 pommes`)
+		shouldRender(`{{ ngettext("%(num)s apple", "%(num)s apples", 1) }}`, `1 pomme`)
+		Context("when autoescaping", func() {
+			BeforeEach(func() {
+				(*configuration).AutoEscape = true
+			})
+			shouldRender(`{{ _("Hello %(user)s!", user=user) }}`, `Bonjour &lt;Ann&gt; !`)
+		})
+		Context("when a variable is missing", func() {
+			shouldFail(`{{ _("Hello %(user)s!", name=user) }}`, `message has no variable named 'user'`)
+		})
+	})
+})
+
+var _ = Context("message extraction", func() {
+	var (
+		sources = new(map[string]string)
+		names   = new([]string)
+
+		returnedCatalog = new(*i18n.Catalog)
+		returnedErr     = new(error)
+	)
+	BeforeEach(func() {
+		*sources = map[string]string{
+			"/base.html": "<title>{{ _(\"Welcome\") }}</title>\n{% block content %}{% endblock %}",
+			"/page.html": strings.Join([]string{
+				`{% extends "/base.html" %}`,
+				`{% block content %}`,
+				`{% trans %}Hello {{ user }}!{% endtrans %}`,
+				`{% if user %}{% trans count=apples %}{{ count }} apple{% pluralize %}{{ count }} apples{% endtrans %}{% endif %}`,
+				`{% macro label() %}{{ gettext("Welcome") }}{% endmacro %}`,
+				`{{ ngettext("%(num)d file", "%(num)d files", 2) }} {{ _(name) }}`,
+				`{% trans %}Say "100%"{% endtrans %}`,
+				`{% endblock %}`,
+			}, "\n"),
+		}
+		*names = nil
+	})
+	JustBeforeEach(func() {
+		*returnedCatalog, *returnedErr = i18n.Extract(gonja.DefaultConfig, loaders.MustNewMemoryLoader(*sources), gonja.DefaultEnvironment, *names...)
+	})
+	It("should extract the messages of all the templates", func() {
+		By("not returning any error")
+		Expect(*returnedErr).To(BeNil())
+		By("returning the messages in order of appearance with their references")
+		Expect((*returnedCatalog).Messages).To(Equal([]*i18n.Message{
+			{Singular: "Welcome", References: []i18n.Reference{{File: "/base.html", Line: 1}, {File: "/page.html", Line: 5}}},
+			{Singular: "Hello %(user)s!", References: []i18n.Reference{{File: "/page.html", Line: 3}}},
+			{Singular: "%(count)s apple", Plural: "%(count)s apples", References: []i18n.Reference{{File: "/page.html", Line: 4}}},
+			{Singular: "%(num)d file", Plural: "%(num)d files", References: []i18n.Reference{{File: "/page.html", Line: 6}}},
+			{Singular: `Say "100%"`, References: []i18n.Reference{{File: "/page.html", Line: 7}}},
+		}))
+		By("writing them as a .pot file")
+		var pot strings.Builder
+		Expect((*returnedCatalog).WritePOT(&pot)).To(Succeed())
+		AssertPrettyDiff(strings.Join([]string{
+			`msgid ""`,
+			`msgstr ""`,
+			`"MIME-Version: 1.0\n"`,
+			`"Content-Type: text/plain; charset=UTF-8\n"`,
+			`"Content-Transfer-Encoding: 8bit\n"`,
+			``,
+			`#: /base.html:1 /page.html:5`,
+			`msgid "Welcome"`,
+			`msgstr ""`,
+			``,
+			`#: /page.html:3`,
+			`#, python-format`,
+			`msgid "Hello %(user)s!"`,
+			`msgstr ""`,
+			``,
+			`#: /page.html:4`,
+			`#, python-format`,
+			`msgid "%(count)s apple"`,
+			`msgid_plural "%(count)s apples"`,
+			`msgstr[0] ""`,
+			`msgstr[1] ""`,
+			``,
+			`#: /page.html:6`,
+			`#, python-format`,
+			`msgid "%(num)d file"`,
+			`msgid_plural "%(num)d files"`,
+			`msgstr[0] ""`,
+			`msgstr[1] ""`,
+			``,
+			`#: /page.html:7`,
+			`msgid "Say \"100%\""`,
+			`msgstr ""`,
+			``,
+		}, "\n"), pot.String())
+	})
+	Context("when templates are named", func() {
+		BeforeEach(func() {
+			*names = []string{"/page.html"}
+		})
+		It("should only extract the messages of the named templates", func() {
+			Expect(*returnedErr).To(BeNil())
+			Expect((*returnedCatalog).Messages).To(HaveLen(5))
+			Expect((*returnedCatalog).Messages).To(ContainElement(&i18n.Message{
+				Singular:   "Welcome",
+				References: []i18n.Reference{{File: "/page.html", Line: 5}},
+			}))
+		})
+	})
+	Context("when messages are held by the arguments of control structures", func() {
+		BeforeEach(func() {
+			(*sources)["/arguments.html"] = strings.Join([]string{
+				`{% with b = _("Beta"), a = _("Alpha") %}{% endwith %}`,
+				`{% set s = _("Set") %}{% include "/missing.html" ignore missing with {"z": _("Zeta")} %}`,
+			}, "\n")
+			*names = []string{"/arguments.html"}
+		})
+		It("should extract them in order of location", func() {
+			Expect(*returnedErr).To(BeNil())
+			Expect((*returnedCatalog).Messages).To(Equal([]*i18n.Message{
+				{Singular: "Beta", References: []i18n.Reference{{File: "/arguments.html", Line: 1}}},
+				{Singular: "Alpha", References: []i18n.Reference{{File: "/arguments.html", Line: 1}}},
+				{Singular: "Set", References: []i18n.Reference{{File: "/arguments.html", Line: 2}}},
+				{Singular: "Zeta", References: []i18n.Reference{{File: "/arguments.html", Line: 2}}},
+			}))
+		})
+	})
+	Context("when a template is invalid", func() {
+		BeforeEach(func() {
+			(*sources)["/broken.html"] = `{% trans %}{{ user.name }}{% endtrans %}`
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("failed to parse template '/broken.html'")))
+		})
+	})
+})
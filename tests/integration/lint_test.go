@@ -0,0 +1,118 @@
+package integration_test
+
+import (
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/lint"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("linting", func() {
+	var (
+		sources = new(map[string]string)
+		names   = new([]string)
+
+		returnedDiagnostics = new([]lint.Diagnostic)
+		returnedErr         = new(error)
+	)
+	BeforeEach(func() {
+		*sources = map[string]string{
+			"/base.html": `{% block content %}{% endblock %}`,
+		}
+		*names = nil
+	})
+	JustBeforeEach(func() {
+		*returnedDiagnostics, *returnedErr = lint.New(gonja.DefaultConfig, loaders.MustNewMemoryLoader(*sources), gonja.DefaultEnvironment).Lint(*names...)
+	})
+	// shouldReport lints the template alone, expecting the given diagnostics formatted as strings
+	shouldReport := func(template string, diagnostics ...string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				(*sources)["/page.html"] = template
+				*names = []string{"/page.html"}
+			})
+			It("should return the expected diagnostics", func() {
+				Expect(*returnedErr).To(BeNil())
+				reported := []string{}
+				for _, diagnostic := range *returnedDiagnostics {
+					reported = append(reported, diagnostic.String())
+				}
+				Expect(reported).To(HaveExactElements(diagnostics))
+			})
+		})
+	}
+	Context("when the template has no mistake", func() {
+		shouldReport(strings.Join([]string{
+			`{# layout #}`,
+			`{% extends "/base.html" %}`,
+			`{% macro item(value) %}<li>{{ value | default("-") }}</li>{% endmacro %}`,
+			`{% block content %}{% set title = user.name | upper %}<h1>{{ title }}</h1>`,
+			`{% for row in rows if row is defined %}{% for cell in row %}{{ item(cell) }}{% endfor %}{% endfor %}`,
+			`{% endblock %}`,
+		}, "\n"))
+	})
+	Context("when the template has syntax errors", func() {
+		shouldReport(
+			"{{ user. }}\n{% block a %}{% endblock %}{% block a %}{% endblock %}",
+			`/page.html:1:10: error: expected name or integer (syntax)`,
+			`/page.html:2:37: error: Block named 'a' already defined (duplicate-block)`,
+		)
+	})
+	Context("when using unknown filters and tests", func() {
+		shouldReport(
+			`{{ user | uper }}{% filter lowr %}{% endfilter %}{% if user is awesome %}{% endif %}`,
+			`/page.html:1:11: error: filter 'uper' is not defined (unknown-filter)`,
+			`/page.html:1:28: error: filter 'lowr' is not defined (unknown-filter)`,
+			`/page.html:1:64: error: test 'awesome' is not defined (unknown-test)`,
+		)
+	})
+	Context("when extends is not the first tag", func() {
+		shouldReport(
+			"Hello\n{% extends \"/base.html\" %}",
+			`/page.html:2:1: warning: extends of '/base.html' is not the first tag of the template (extends-not-first)`,
+		)
+		shouldReport("{# comment #}\n  {% extends \"/base.html\" %}")
+	})
+	Context("when macros and variables are unused", func() {
+		shouldReport(
+			"{% macro unused() %}{% endmacro %}{% macro used() %}{% endmacro %}{{ used() }}\n{% set a = 1 %}{% set b = 2 %}{{ b }}{% set ns.c = 3 %}",
+			`/page.html:1:1: warning: macro 'unused' is never used (unused-macro)`,
+			`/page.html:2:8: warning: variable 'a' is set but never used (unused-set)`,
+		)
+		shouldReport(`{% set a = 1 %}{% include "/base.html" %}`)
+	})
+	Context("when loop variables are shadowed", func() {
+		shouldReport(
+			`{% for item in items %}{% for key, item in item.pairs %}{{ key }}{{ item }}{% endfor %}{% endfor %}{% for item in items %}{{ item }}{% endfor %}`,
+			`/page.html:1:24: warning: loop variable 'item' shadows the one of an enclosing loop (shadowed-loop-variable)`,
+		)
+	})
+	Context("when default is applied to constants", func() {
+		shouldReport(
+			`{{ "name" | default("none") }}{{ [1, 2] | first | d(3) }}{{ name | default("none") }}`,
+			`/page.html:1:13: warning: filter 'default' is applied to a constant, which is never undefined (constant-default)`,
+			`/page.html:1:51: warning: filter 'd' is applied to a constant, which is never undefined (constant-default)`,
+		)
+	})
+	Context("when linting several templates", func() {
+		BeforeEach(func() {
+			(*sources)["/macros.html"] = `{% macro card() %}{% endmacro %}{% macro list() %}{% endmacro %}{% set version = 1 %}`
+			(*sources)["/page.html"] = `{% from "/macros.html" import card, version %}{{ card() }}{{ version }}`
+		})
+		It("should not report the macros and variables imported by other templates", func() {
+			Expect(*returnedErr).To(BeNil())
+			Expect(*returnedDiagnostics).To(Equal([]lint.Diagnostic{{
+				File:     "/macros.html",
+				Line:     1,
+				Column:   33,
+				Severity: lint.SeverityWarning,
+				Rule:     lint.RuleUnusedMacro,
+				Message:  "macro 'list' is never used",
+			}}))
+		})
+	})
+})
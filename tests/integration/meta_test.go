@@ -0,0 +1,153 @@
+package integration_test
+
+import (
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/meta"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/parser"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("static analysis", func() {
+	var (
+		identifier  = new(string)
+		sources     = new(map[string]string)
+		environment = new(*exec.Environment)
+
+		returnedAnalysis = new(*meta.Analysis)
+		returnedErr      = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/page.html"
+		*environment = gonja.DefaultEnvironment
+		*sources = map[string]string{
+			"/base.html":   "{% block content %}{% endblock %}",
+			"/header.html": "",
+			"/macros.html": "",
+		}
+	})
+	JustBeforeEach(func() {
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, loaders.MustNewMemoryLoader(*sources), *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedAnalysis = meta.Analyze(t.Root())
+	})
+	shouldFind := func(template string, undeclared, assigned []string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				(*sources)[*identifier] = template
+			})
+			It("should return the expected variables", func() {
+				Expect(*returnedErr).To(BeNil())
+				Expect((*returnedAnalysis).Undeclared).To(HaveExactElements(undeclared))
+				Expect((*returnedAnalysis).Assigned).To(HaveExactElements(assigned))
+			})
+		})
+	}
+	Context("variables", func() {
+		shouldFind(`Hello {{ user.name | default(fallback) }}`, []string{"fallback", "user"}, []string{})
+		shouldFind(`{{ first }}{% set first = 1 %}{% set second = first + other %}{{ second }}`, []string{"first", "other"}, []string{"first", "second"})
+		shouldFind(`{% set ns.value = value %}{% set items[key] = 1 %}`, []string{"items", "key", "ns", "value"}, []string{})
+		shouldFind(`{{ a if b else c }}{{ d is divisibleby(e) }}{{ [f, (g, h), {"i": j}][k:l] }}{{ not m }}{{ -n }}`, []string{"a", "b", "c", "d", "e", "f", "g", "h", "j", "k", "l", "m", "n"}, []string{})
+		shouldFind(`{{ range(count) }}{{ self }}`, []string{"count", "range"}, []string{})
+		shouldFind(`{% do items.append(item) if flag else None %}`, []string{"flag", "item", "items"}, []string{})
+		Context("when looping", func() {
+			shouldFind(`{% for item in items if item != skipped %}{{ loop.index }}{{ item }}{% else %}{{ item }}{% endfor %}{{ loop }}`, []string{"item", "items", "loop", "skipped"}, []string{})
+			shouldFind(`{% for key, value in pairs %}{{ key }}={{ value }}{% set inner = 1 %}{% endfor %}{{ inner }}`, []string{"inner", "pairs"}, []string{})
+		})
+		Context("when using conditions", func() {
+			shouldFind(`{% if a %}{% set x = 1 %}{% set y = 1 %}{% else %}{% set x = 2 %}{% endif %}{{ x }}{{ y }}`, []string{"a", "y"}, []string{"x"})
+			shouldFind(`{% if a %}{% set x = 1 %}{% endif %}{{ x }}`, []string{"a", "x"}, []string{})
+		})
+		Context("when using macros", func() {
+			shouldFind(`{% macro card(title, size=default_size) %}{{ title }}{{ size }}{{ caller() }}{{ footer }}{% endmacro %}{{ card("t") }}`, []string{"default_size", "footer"}, []string{"card"})
+			shouldFind(`{% macro list(items) %}{{ caller(items) }}{% endmacro %}{% call(item) list(entries) %}{{ item }}{{ other }}{% endcall %}`, []string{"entries", "other"}, []string{"list"})
+		})
+		Context("when using scopes", func() {
+			shouldFind(`{% with a = b, c = 1 %}{{ a }}{{ c }}{{ d }}{% endwith %}{{ a }}`, []string{"a", "b", "d"}, []string{})
+			shouldFind(`{% filter upper %}{{ a }}{% endfilter %}{% autoescape true %}{{ b }}{% endautoescape %}`, []string{"a", "b"}, []string{})
+			shouldFind(`{% trans count=apples %}{{ count }} apple for {{ user }}{% pluralize %}{{ count }} apples for {{ user }}{% endtrans %}`, []string{"apples", "user"}, []string{})
+		})
+		Context("when using blocks", func() {
+			shouldFind(`{% extends "/base.html" %}{% block content %}{{ super() }}{{ title }}{% endblock %}`, []string{"title"}, []string{})
+		})
+		Context("when using a control structure which is not built in", func() {
+			BeforeEach(func() {
+				copied := *gonja.DefaultEnvironment
+				copied.ControlStructures = exec.NewControlStructureSet(map[string]parser.ControlStructureParser{
+					"shout": shoutParser,
+				}).Update(gonja.DefaultEnvironment.ControlStructures)
+				*environment = &copied
+			})
+			shouldFind(`{% shout greeting %}{{ name }}{% set local = 1 %}{{ local }}{% endshout %}{{ local }}`, []string{"greeting", "local", "name"}, []string{})
+		})
+		Context("when importing templates", func() {
+			shouldFind(`{% import "/macros.html" as macros %}{% from "/macros.html" import card as c, list %}{{ macros.card() }}{{ c() }}{{ list() }}`, []string{}, []string{"c", "list", "macros"})
+		})
+	})
+	Context("referenced templates", func() {
+		BeforeEach(func() {
+			(*sources)[*identifier] = `{% extends "/base.html" %}` +
+				`{% block content %}{% include "/header.html" %}{% include name ignore missing %}{% endblock %}` +
+				`{% import "/macros.html" as macros %}{% from "/macros.html" import card %}`
+		})
+		It("should return the referenced templates in order", func() {
+			Expect(*returnedErr).To(BeNil())
+			references := []string{}
+			for _, reference := range (*returnedAnalysis).Templates {
+				Expect(reference.Position).ToNot(BeNil())
+				references = append(references, reference.Kind+":"+reference.Name)
+			}
+			Expect(references).To(Equal([]string{
+				"extends:/base.html",
+				"include:/header.html",
+				"include:",
+				"import:/macros.html",
+				"import:/macros.html",
+			}))
+			Expect((*returnedAnalysis).Undeclared).To(Equal([]string{"name"}))
+		})
+	})
+})
+
+// shoutControlStructure is a control structure holding an expression and a body, defined out of the
+// built-in ones and only implementing nodes.Container to be walked
+type shoutControlStructure struct {
+	location   *tokens.Token
+	expression nodes.Expression
+	body       *nodes.Wrapper
+}
+
+func (s *shoutControlStructure) Position() *tokens.Token { return s.location }
+func (s *shoutControlStructure) String() string          { return "shoutControlStructure" }
+
+func (s *shoutControlStructure) Apply(f nodes.ApplyFunc) error {
+	if err := nodes.ApplyTo(f, &s.expression); err != nil {
+		return err
+	}
+	return nodes.ApplyTo(f, &s.body)
+}
+
+func (s *shoutControlStructure) Execute(r *exec.Renderer, tag *nodes.ControlStructureBlock) error {
+	return r.ExecuteWrapper(s.body)
+}
+
+func shoutParser(p *parser.Parser, args *parser.Parser) (nodes.ControlStructure, error) {
+	cs := &shoutControlStructure{location: p.Current()}
+	expression, err := args.ParseExpression()
+	if err != nil {
+		return nil, err
+	}
+	cs.expression = expression
+	if cs.body, _, err = p.WrapUntil("endshout"); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
@@ -0,0 +1,236 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/nikolalohinski/gonja/v2"
+	controlStructures "github.com/nikolalohinski/gonja/v2/builtins/control_structures"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// opaqueNode holds an unexported field without implementing nodes.Encodable
+type opaqueNode struct {
+	value string
+}
+
+func (n *opaqueNode) Position() *tokens.Token { return nil }
+func (n *opaqueNode) String() string          { return n.value }
+
+var _ = Context("precompiled templates", func() {
+	var (
+		identifier = new(string)
+		sources    = new(map[string]string)
+		context    = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+		loader         = new(*countingLoader)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*sources = map[string]string{
+			"/base":   `<main>{% block content %}base{% endblock %}</main>{% block footer %}footer{% endblock %}`,
+			"/macros": `{% macro greet(name) %}hello {{ name }}{% endmacro %}`,
+			"/nested": `{{ missing | default('none') }}`,
+		}
+		*context = exec.NewContext(map[string]any{
+			"items": []any{"a", "b", "c"},
+			"user":  map[string]any{"name": "alice", "admin": true},
+		})
+	})
+	JustBeforeEach(func() {
+		*loader = &countingLoader{
+			Loader: loaders.MustNewMemoryLoader(*sources),
+			reads:  map[string]int{},
+		}
+		var parsed *exec.Template
+		parsed, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, gonja.DefaultEnvironment)
+		if *returnedErr != nil {
+			return
+		}
+		var serialized []byte
+		serialized, *returnedErr = json.Marshal(parsed.Root())
+		if *returnedErr != nil {
+			return
+		}
+		root := new(nodes.Template)
+		if *returnedErr = json.Unmarshal(serialized, root); *returnedErr != nil {
+			return
+		}
+		(*loader).reads = map[string]int{}
+		t := exec.NewTemplateFromRoot(root, gonja.DefaultConfig, *loader, gonja.DefaultEnvironment)
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	shouldRender := func(template, result string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				(*sources)[*identifier] = template
+			})
+			It("should return the expected rendered content", func() {
+				By("not returning any error")
+				Expect(*returnedErr).To(BeNil())
+				By("returning the expected result")
+				AssertPrettyDiff(result, *returnedResult)
+				By("not reading the source of the template again")
+				Expect((*loader).reads).ToNot(HaveKey(*identifier))
+			})
+		})
+	}
+	Context("when using expressions and filters", func() {
+		shouldRender(
+			`{{ user.name | upper }} {{ items | join(',') }} {{ 1 + 2 * 3 }} {{ 'yes' if user.admin else 'no' }} {{ items[1:] }} {{ {'a': 1.5}['a'] }} {{ not false }} {{ none is none }}`,
+			`ALICE a,b,c 7 yes ['b', 'c'] 1.5 True True`,
+		)
+	})
+	Context("when using loops and conditions", func() {
+		shouldRender(
+			`{% for item in items if item != 'b' %}{{ loop.index }}{{ item }}{% if loop.last %}.{% else %},{% endif %}{% else %}empty{% endfor %}{% for i in range(5) %}{% if i == 2 %}{% continue %}{% elif i == 4 %}{% break %}{% endif %}{{ i }}{% endfor %}`,
+			`1a,2c.013`,
+		)
+	})
+	Context("when using assignments and scopes", func() {
+		shouldRender(
+			`{% set x = 1 %}{% with z = x + 1 %}{{ z }}{% endwith %}{% do items.append('d') %}{{ items | length }}`,
+			`24`,
+		)
+	})
+	Context("when using macros and calls", func() {
+		shouldRender(
+			`{% macro wrap(tag='b') %}<{{ tag }}>{{ caller() }}</{{ tag }}>{% endmacro %}{% call wrap('i') %}inner{% endcall %}{{ wrap() if false else 'x' }}`,
+			`<i>inner</i>x`,
+		)
+	})
+	Context("when using filter blocks, raw blocks and comments", func() {
+		shouldRender(
+			`{% filter upper %}up{% endfilter %}{# comment #}{% raw %}{{ raw }}{% endraw %}{%- if true -%}  trimmed  {%- endif %}`,
+			`UP{{ raw }}trimmed`,
+		)
+	})
+	Context("when using autoescape blocks", func() {
+		shouldRender(
+			`{% autoescape true %}{{ '<b>' }}{% endautoescape %}`,
+			`&lt;b&gt;`,
+		)
+	})
+	Context("when including and importing templates", func() {
+		shouldRender(
+			`{% include '/nested' %} {% import '/macros' as m %}{{ m.greet('bob') }} {% from '/macros' import greet as g %}{{ g('eve') }}`,
+			`none hello bob hello eve`,
+		)
+	})
+	Context("when extending a template", func() {
+		shouldRender(
+			`{% extends '/base' %}{% block content %}[{{ super() }}]{% endblock %}`,
+			`<main>[base]</main>footer`,
+		)
+		It("should not read the source of the extended template again", func() {
+			Expect((*loader).reads).ToNot(HaveKey("/base"))
+		})
+	})
+	Context("when the same node is referenced several times", func() {
+		BeforeEach(func() {
+			(*sources)[*identifier] = `{% macro m() %}macro{% endmacro %}{% block content %}block{% endblock %}`
+		})
+		It("should keep sharing it once loaded back", func() {
+			parsed, err := exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, gonja.DefaultEnvironment)
+			Expect(err).To(BeNil())
+			serialized, err := json.Marshal(parsed.Root())
+			Expect(err).To(BeNil())
+			root := new(nodes.Template)
+			Expect(json.Unmarshal(serialized, root)).To(Succeed())
+			macro := root.Nodes[0].(*nodes.ControlStructureBlock).ControlStructure.(*controlStructures.MacroControlStructure)
+			Expect(root.Macros["m"]).ToNot(BeNil())
+			Expect(root.Macros["m"]).To(BeIdenticalTo(macro.Macro))
+			Expect(root.Blocks["content"]).ToNot(BeNil())
+		})
+	})
+	Context("when a node holds unexported fields without exposing them", func() {
+		It("should return an error", func() {
+			Expect(nodes.RegisterType(&opaqueNode{})).To(Succeed())
+			root := &nodes.Template{Identifier: *identifier, Nodes: []nodes.Node{&opaqueNode{value: "hidden"}}}
+			_, err := json.Marshal(root)
+			Expect(err).To(MatchError(ContainSubstring("unexported field 'value' of integration_test.opaqueNode must be exposed by implementing nodes.Encodable")))
+		})
+	})
+	Context("when loading a serialized template of another version", func() {
+		It("should return an error", func() {
+			Expect(json.Unmarshal([]byte(`{"version":0,"template":{}}`), new(nodes.Template))).To(MatchError(ContainSubstring("unsupported template encoding version")))
+		})
+	})
+})
+
+var _ = Context("precompiled directory", func() {
+	var (
+		dir = new(string)
+
+		returnedTemplates = new(map[string]*exec.Template)
+		returnedErr       = new(error)
+	)
+	BeforeEach(func() {
+		*dir = GinkgoT().TempDir()
+		for name, source := range map[string]string{
+			"layout.j2":       `<html>{% block body %}{% endblock %}</html>`,
+			"pages/home.j2":   `{% extends "layout.j2" %}{% block body %}{% include "partial.j2" %}{% endblock %}`,
+			"pages/README.md": `not a template`,
+			"partial.j2":      `hello {{ name }}`,
+		} {
+			path := filepath.Join(*dir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0o755)).To(Succeed())
+			Expect(os.WriteFile(path, []byte(source), 0o644)).To(Succeed())
+		}
+	})
+	JustBeforeEach(func() {
+		var bundle []byte
+		bundle, *returnedErr = gonja.PrecompileDirectory(*dir, gonja.DefaultConfig, gonja.DefaultEnvironment, ".j2")
+		if *returnedErr != nil {
+			return
+		}
+		*returnedTemplates, *returnedErr = gonja.LoadPrecompiled(bundle, gonja.DefaultConfig, loaders.MustNewFileSystemLoader(*dir), gonja.DefaultEnvironment)
+	})
+	It("should precompile the templates with the given extensions", func() {
+		By("not returning any error")
+		Expect(*returnedErr).To(BeNil())
+		By("returning the templates keyed by their relative path")
+		Expect(*returnedTemplates).To(HaveLen(3))
+		Expect(*returnedTemplates).To(HaveKey("layout.j2"))
+		Expect(*returnedTemplates).To(HaveKey("pages/home.j2"))
+		Expect(*returnedTemplates).To(HaveKey("partial.j2"))
+	})
+	It("should render the precompiled templates", func() {
+		Expect(*returnedErr).To(BeNil())
+		result, err := (*returnedTemplates)["pages/home.j2"].ExecuteToString(exec.NewContext(map[string]any{"name": "bob"}))
+		Expect(err).To(BeNil())
+		AssertPrettyDiff("<html>hello bob</html>", result)
+	})
+	Context("when precompiling with another configuration", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(filepath.Join(*dir, "custom.j2"), []byte(`<% if true %>custom<% endif %>`), 0o644)).To(Succeed())
+		})
+		It("should parse and load the templates with that configuration", func() {
+			cfg := gonja.DefaultConfig.Inherit()
+			cfg.BlockStartString = "<%"
+			cfg.BlockEndString = "%>"
+			bundle, err := gonja.PrecompileDirectory(*dir, cfg, gonja.DefaultEnvironment, ".j2")
+			Expect(err).To(BeNil())
+			templates, err := gonja.LoadPrecompiled(bundle, cfg, loaders.MustNewFileSystemLoader(*dir), gonja.DefaultEnvironment)
+			Expect(err).To(BeNil())
+			Expect(templates["custom.j2"].ExecuteToString(nil)).To(Equal("custom"))
+		})
+	})
+	Context("when a template is invalid", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(filepath.Join(*dir, "broken.j2"), []byte(`{% if %}`), 0o644)).To(Succeed())
+		})
+		It("should return an error", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("failed to precompile 'broken.j2'")))
+		})
+	})
+})
@@ -0,0 +1,357 @@
+package integration_test
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("sandbox", func() {
+	var (
+		identifier = new(string)
+
+		sandbox = new(*exec.Sandbox)
+		loader  = new(loaders.Loader)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*sandbox = &exec.Sandbox{
+			MaxOutputSize:     64,
+			MaxLoopIterations: 10,
+			MaxRecursionDepth: 5,
+			MaxRangeLength:    20,
+		}
+		*loader = loaders.MustNewMemoryLoader(nil)
+	})
+	JustBeforeEach(func() {
+		environment := *gonja.DefaultEnvironment
+		environment.Sandbox = *sandbox
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, &environment)
+		if *returnedErr != nil {
+			return
+		}
+		output := new(bytes.Buffer)
+		*returnedErr = t.Execute(output, nil)
+		*returnedResult = output.String()
+	})
+	shouldFailWith := func(limit string, max int) {
+		It("should return a security error", func() {
+			By("returning an error")
+			Expect(*returnedErr).ToNot(BeNil())
+			By("returning a typed security error")
+			securityErr := new(exec.SecurityError)
+			Expect(errors.As(*returnedErr, &securityErr)).To(BeTrue())
+			Expect(securityErr.Template).To(Equal(*identifier))
+			Expect(securityErr.Limit).To(Equal(limit))
+			Expect(securityErr.Max).To(Equal(max))
+		})
+	}
+	Context("when the template stays within the limits", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% macro m(i) %}[{{ i }}]{% endmacro %}{% for i in range(10) %}{{ m(i) }}{% endfor %}",
+			})
+		})
+		It("should return the expected rendered content", func() {
+			By("not returning any error")
+			Expect(*returnedErr).To(BeNil())
+			By("returning the expected result")
+			AssertPrettyDiff("[0][1][2][3][4][5][6][7][8][9]", *returnedResult)
+		})
+	})
+	Context("when the output is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{{ 'x' * 65 }}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+		It("should not write the output exceeding the limit", func() {
+			Expect(*returnedResult).To(BeEmpty())
+		})
+	})
+	Context("when the output of an included template is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in range(2) %}{% include '/included' %}{% endfor %}",
+				"/included": "{{ 'x' * 40 }}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+	})
+	Context("when a string repetition is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% set huge = 'x' * 10**9 %}{{ huge | length }}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+	})
+	Context("when a string concatenation is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% set x = 'x' * 40 %}{{ (x ~ x) | length }}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+	})
+	Context("when the output of a macro kept in memory is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% macro m() %}{% for i in range(5) %}{{ 'x' * 20 }}{% endfor %}{% endmacro %}{{ m() | length }}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+	})
+	Context("when the body of a filter block is too large", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% filter length %}{% for i in range(5) %}{{ 'x' * 20 }}{% endfor %}{% endfilter %}",
+			})
+		})
+		shouldFailWith(exec.LimitOutputSize, 64)
+	})
+	Context("when looping too many times", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% for i in range(4) %}{% for j in range(4) %}{% endfor %}{% endfor %}",
+			})
+		})
+		shouldFailWith(exec.LimitLoopIterations, 10)
+	})
+	Context("when a macro recurses infinitely", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% macro m() %}{{ m() }}{% endmacro %}{{ m() }}",
+			})
+		})
+		shouldFailWith(exec.LimitRecursionDepth, 5)
+	})
+	Context("when a template includes itself", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{% include '/test' %}",
+			})
+		})
+		shouldFailWith(exec.LimitRecursionDepth, 5)
+	})
+	Context("when the range is too long", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{{ range(0, 100, 4) | list | length }}",
+			})
+		})
+		shouldFailWith(exec.LimitRangeLength, 20)
+	})
+	Context("when the range is at the limit", func() {
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{{ range(40, 0, -2) | list | length }}",
+			})
+		})
+		It("should return the expected rendered content", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("20", *returnedResult)
+		})
+	})
+	Context("when limits are disabled", func() {
+		BeforeEach(func() {
+			*sandbox = &exec.Sandbox{}
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{{ range(1000) | list | length }}",
+			})
+		})
+		It("should return the expected rendered content", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("1000", *returnedResult)
+		})
+	})
+})
+
+type sandboxedAccount struct {
+	Name     string
+	Password string
+	deleted  *bool
+}
+
+func (a sandboxedAccount) Delete() string {
+	*a.deleted = true
+	return "deleted"
+}
+
+type sandboxedGetter struct {
+	read *[]string
+}
+
+func (g sandboxedGetter) GetAttribute(name string) (*exec.Value, bool) {
+	*g.read = append(*g.read, name)
+	return exec.AsValue(name), true
+}
+
+type sandboxedUnlisted struct {
+	Name string
+}
+
+type sandboxedCallable struct{}
+
+func (sandboxedCallable) Call(*exec.VarArgs) *exec.Value {
+	return exec.AsValue("called")
+}
+
+type denyCallables struct{}
+
+func (denyCallables) IsSafeAttribute(*exec.Value, string) bool { return true }
+func (denyCallables) IsSafeCallable(*exec.Value) bool          { return false }
+
+var _ = Context("sandbox security policy", func() {
+	var (
+		identifier = new(string)
+
+		policy  = new(exec.SecurityPolicy)
+		loader  = new(loaders.Loader)
+		deleted = new(bool)
+		read    = new([]string)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*identifier = "/test"
+		*deleted = false
+		*read = nil
+		*policy = exec.AllowList{
+			reflect.TypeFor[sandboxedAccount](): {"Name"},
+			reflect.TypeFor[sandboxedGetter]():  {"public"},
+		}
+		*loader = loaders.MustNewMemoryLoader(nil)
+	})
+	JustBeforeEach(func() {
+		environment := *gonja.DefaultEnvironment
+		environment.Sandbox = &exec.Sandbox{Policy: *policy}
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate(*identifier, gonja.DefaultConfig, *loader, &environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(exec.NewContext(map[string]any{
+			"account":  sandboxedAccount{Name: "alice", Password: "secret", deleted: deleted},
+			"settings": map[string]any{"theme": "dark"},
+			"greet":    func() string { return "hello" },
+			"getter":   sandboxedGetter{read: read},
+			"unlisted": sandboxedUnlisted{Name: "bob"},
+			"callable": sandboxedCallable{},
+		}))
+	})
+	shouldRender := func(template, result string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier: template,
+				})
+			})
+			It("should return the expected rendered content", func() {
+				By("not returning any error")
+				Expect(*returnedErr).To(BeNil())
+				By("returning the expected result")
+				AssertPrettyDiff(result, *returnedResult)
+			})
+		})
+	}
+	shouldDeny := func(template, attribute string) {
+		Context(template, func() {
+			BeforeEach(func() {
+				*loader = loaders.MustNewMemoryLoader(map[string]string{
+					*identifier: template,
+				})
+			})
+			It("should return a security error", func() {
+				By("returning an error")
+				Expect(*returnedErr).ToNot(BeNil())
+				By("returning a typed security error")
+				securityErr := new(exec.SecurityError)
+				Expect(errors.As(*returnedErr, &securityErr)).To(BeTrue())
+				Expect(securityErr.Template).To(Equal(*identifier))
+				Expect(securityErr.Attribute).To(Equal(attribute))
+				Expect(securityErr.Limit).To(BeEmpty())
+			})
+		})
+	}
+	Context("when reading an allowed attribute", func() {
+		shouldRender("{{ account.Name }}", "alice")
+	})
+	Context("when reading a value of a type which is not restricted", func() {
+		shouldRender("{{ settings.theme }} {{ greet() }} {{ 'a,b'.split(',') | join('') }}", "dark hello ab")
+	})
+	Context("when reading a forbidden attribute", func() {
+		shouldDeny("{{ account.Password }}", "Password")
+	})
+	Context("when reading a forbidden attribute as an item", func() {
+		shouldDeny("{{ account['Password'] }}", "Password")
+	})
+	Context("when reading a forbidden attribute with the attr filter", func() {
+		shouldRender("{{ account | attr('Password') is defined }}", "False")
+	})
+	Context("when reading a forbidden attribute with a filter taking an attribute", func() {
+		shouldDeny("{{ [account] | map(attribute='Password') | select | list | length }}", "Password")
+		shouldDeny("{{ [account, account] | sort(attribute='Name,Password') | length }}", "Password")
+		shouldDeny("{{ [account] | groupby('Password') | length }}", "Password")
+		shouldDeny("{{ [account] | sum(attribute='Password') }}", "Password")
+		shouldDeny("{{ [account] | selectattr('Password') | list | length }}", "Password")
+		shouldDeny("{{ [account] | join(',', attribute='Password') }}", "Password")
+	})
+	Context("when serializing a value with a forbidden attribute", func() {
+		shouldDeny("{{ account | tojson }}", "Password")
+		shouldDeny("{{ {'accounts': [account]} | pprint }}", "Password")
+		shouldRender("{{ {'name': account.Name, 'theme': settings.theme} | tojson }}", `{"name":"alice","theme":"dark"}`)
+	})
+	Context("when reading a value of a struct type missing from the allow list", func() {
+		shouldDeny("{{ unlisted.Name }}", "Name")
+	})
+	Context("when reading the values created by the engine", func() {
+		shouldRender("{{ {'a': 1}.a }}{% for i in [1, 2] %}{{ loop.index }}{% endfor %}{{ {'a': 1}.items() | list | length }}", "1121")
+	})
+	Context("when calling a callable object missing from the allow list", func() {
+		shouldDeny("{{ callable() }}", "callable")
+	})
+	Context("when calling a callable object allowed by the allow list", func() {
+		BeforeEach(func() {
+			(*policy).(exec.AllowList)[reflect.TypeFor[sandboxedCallable]()] = []string{"Call"}
+		})
+		shouldRender("{{ callable() }}", "called")
+	})
+	Context("when calling a forbidden method", func() {
+		shouldDeny("{{ account.Delete() }}", "Delete")
+		It("should not call the method", func() {
+			Expect(*deleted).To(BeFalse())
+		})
+	})
+	Context("when reading a forbidden attribute through a getter", func() {
+		shouldDeny("{{ getter.public }}{{ getter.private }}", "private")
+		BeforeEach(func() {
+			*loader = loaders.MustNewMemoryLoader(map[string]string{
+				*identifier: "{{ getter.public }}{{ getter.private }}",
+			})
+		})
+		It("should not run the getter for the forbidden attribute", func() {
+			Expect(*read).To(HaveExactElements("public"))
+		})
+	})
+	Context("when the policy forbids callables", func() {
+		BeforeEach(func() {
+			*policy = denyCallables{}
+		})
+		shouldDeny("{{ greet() }}", "greet")
+		shouldRender("{{ account.Password }}", "secret")
+	})
+})
@@ -0,0 +1,129 @@
+package integration_test
+
+import (
+	"errors"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("selecting templates", func() {
+	var (
+		environment = new(*exec.Environment)
+		source      = new(string)
+		context     = new(*exec.Context)
+
+		returnedResult = new(string)
+		returnedErr    = new(error)
+	)
+	BeforeEach(func() {
+		*source = ""
+		*context = exec.NewContext(map[string]any{"id": 1})
+	})
+	JustBeforeEach(func() {
+		copied := *gonja.DefaultEnvironment
+		copied.Loader = loaders.MustNewMemoryLoader(map[string]string{
+			"/page.html":           *source,
+			"/default/header.html": "default header",
+			"/user/1/header.html":  "header of user 1",
+			"/default/layout.html": "default[{% block content %}{% endblock %}]",
+			"/default/macros.html": `{% macro greet(name) %}hello {{ name }}{% endmacro %}`,
+			"/user/1/macros.html":  `{% macro greet(name) %}welcome {{ name }}{% endmacro %}`,
+			"/broken.html":         "{{ user. }}",
+		})
+		*environment = &copied
+
+		var t *exec.Template
+		t, *returnedErr = exec.NewTemplate("/page.html", gonja.DefaultConfig, copied.Loader, *environment)
+		if *returnedErr != nil {
+			return
+		}
+		*returnedResult, *returnedErr = t.ExecuteToString(*context)
+	})
+	withSource := func(template string) {
+		BeforeEach(func() {
+			*source = template
+		})
+	}
+	Context("when including a list of templates", func() {
+		withSource(`{% include ["/user/" ~ id ~ "/header.html", "/default/header.html"] %}`)
+		It("should include the first template found", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("header of user 1", *returnedResult)
+		})
+		Context("and the first one does not exist", func() {
+			BeforeEach(func() {
+				(*context).Set("id", 2)
+			})
+			It("should fall back to the next one", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("default header", *returnedResult)
+			})
+		})
+	})
+	Context("when including a list of missing templates", func() {
+		withSource(`{% include ["/a.html", "/b.html"] %}`)
+		It("should return an error naming them", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("none of the templates '/a.html', '/b.html' could be loaded")))
+			var notFound *exec.TemplateNotFoundError
+			Expect(errors.As(*returnedErr, &notFound)).To(BeTrue())
+			Expect(notFound.Names).To(HaveExactElements("/a.html", "/b.html"))
+		})
+		Context("with 'ignore missing'", func() {
+			withSource(`before{% include ["/a.html", "/b.html"] ignore missing %}after`)
+			It("should include nothing", func() {
+				Expect(*returnedErr).To(BeNil())
+				AssertPrettyDiff("beforeafter", *returnedResult)
+			})
+		})
+	})
+	Context("when a template of the list is broken", func() {
+		withSource(`{% include ["/broken.html", "/default/header.html"] %}`)
+		It("should return its error instead of falling back", func() {
+			Expect(*returnedErr).To(MatchError(ContainSubstring("unable to load template '/broken.html'")))
+		})
+	})
+	Context("when extending a list of templates", func() {
+		withSource(`{% extends ["/user/" ~ id ~ "/layout.html", "/default/layout.html"] %}{% block content %}page{% endblock %}`)
+		It("should extend the first template found", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("default[page]", *returnedResult)
+		})
+	})
+	Context("when importing a list of templates", func() {
+		withSource(`{% import ["/user/" ~ id ~ "/macros.html", "/default/macros.html"] as m %}{{ m.greet("bob") }}`)
+		It("should import the first template found", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("welcome bob", *returnedResult)
+		})
+	})
+	Context("when importing names from a list of templates", func() {
+		withSource(`{% from ["/missing.html", "/default/macros.html"] import greet %}{{ greet("bob") }}`)
+		It("should import from the first template found", func() {
+			Expect(*returnedErr).To(BeNil())
+			AssertPrettyDiff("hello bob", *returnedResult)
+		})
+	})
+	Context("when selecting a template from the environment", func() {
+		It("should return the first template found", func() {
+			t, err := (*environment).SelectTemplate("/user/2/header.html", "/default/header.html", "/user/1/header.html")
+			Expect(err).To(BeNil())
+			Expect(t.ExecuteToString(nil)).To(Equal("default header"))
+		})
+		It("should return the error of a broken template", func() {
+			_, err := (*environment).SelectTemplate("/broken.html", "/default/header.html")
+			Expect(err).To(MatchError(ContainSubstring("failed to parse template '/broken.html'")))
+		})
+		It("should return an error when no template is found", func() {
+			_, err := (*environment).SelectTemplate("/a.html", "/b.html")
+			var notFound *exec.TemplateNotFoundError
+			Expect(errors.As(err, &notFound)).To(BeTrue())
+			Expect(notFound.Names).To(HaveExactElements("/a.html", "/b.html"))
+			Expect(notFound.Errs).To(HaveLen(2))
+		})
+	})
+})
@@ -0,0 +1,117 @@
+package integration_test
+
+import (
+	"context"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// flushRecorder records the output written between flushes
+type flushRecorder struct {
+	strings.Builder
+	chunks []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.chunks = append(f.chunks, f.String())
+	f.Reset()
+}
+
+var _ = Context("streaming", func() {
+	var (
+		source      = new(string)
+		environment = new(*exec.Environment)
+		data        = new(*exec.Context)
+
+		template = new(*exec.Template)
+	)
+	BeforeEach(func() {
+		*environment = gonja.DefaultEnvironment
+		*data = exec.NewContext(map[string]any{"rows": 3000})
+	})
+	JustBeforeEach(func() {
+		var err error
+		*template, err = exec.NewTemplate("/report.csv", gonja.DefaultConfig, loaders.MustNewMemoryLoader(map[string]string{"/report.csv": *source}), *environment)
+		Expect(err).To(BeNil())
+	})
+	// collect renders the template as a stream, returning its chunks and the error yielded if any
+	collect := func() ([]string, error) {
+		var chunks []string
+		for chunk, err := range (*template).Stream(context.Background(), *data) {
+			if err != nil {
+				return chunks, err
+			}
+			chunks = append(chunks, chunk)
+		}
+		return chunks, nil
+	}
+	Context("when the output is large", func() {
+		BeforeEach(func() {
+			*source = "id,name\n{% for i in range(rows) %}{{ i }},user {{ i }}\n{% endfor %}"
+		})
+		It("should yield the output by bounded chunks", func() {
+			chunks, err := collect()
+			Expect(err).To(BeNil())
+			Expect(len(chunks)).To(BeNumerically(">", 10))
+			for _, chunk := range chunks {
+				Expect(len(chunk)).To(BeNumerically("<", 2*exec.StreamChunkSize))
+			}
+			expected, err := (*template).ExecuteToString(*data)
+			Expect(err).To(BeNil())
+			Expect(strings.Join(chunks, "")).To(Equal(expected))
+		})
+		It("should stop rendering when the iteration stops", func() {
+			count := 0
+			for range (*template).Stream(context.Background(), *data) {
+				count++
+				if count == 2 {
+					break
+				}
+			}
+			Expect(count).To(Equal(2))
+		})
+	})
+	Context("when the template has flush tags", func() {
+		BeforeEach(func() {
+			*source = "header\n{% flush %}{% for i in range(3) %}{{ i }}{% flush %}{% endfor %}footer"
+		})
+		It("should yield the output rendered before each of them", func() {
+			chunks, err := collect()
+			Expect(err).To(BeNil())
+			Expect(chunks).To(HaveExactElements("header\n", "0", "1", "2", "footer"))
+		})
+		It("should flush outputs supporting it when executed", func() {
+			output := &flushRecorder{}
+			Expect((*template).Execute(output, *data)).To(Succeed())
+			Expect(append(output.chunks, output.String())).To(HaveExactElements("header\n", "0", "1", "2", "footer"))
+		})
+		Context("and the output size is limited by the sandbox", func() {
+			BeforeEach(func() {
+				copied := *gonja.DefaultEnvironment
+				copied.Sandbox = &exec.Sandbox{MaxOutputSize: 100}
+				*environment = &copied
+			})
+			It("should still yield the output rendered before each of them", func() {
+				chunks, err := collect()
+				Expect(err).To(BeNil())
+				Expect(chunks).To(HaveExactElements("header\n", "0", "1", "2", "footer"))
+			})
+		})
+	})
+	Context("when rendering fails", func() {
+		BeforeEach(func() {
+			*source = "before{% flush %}after{{ 1 | unknown }}"
+		})
+		It("should yield the output rendered so far and then the error", func() {
+			chunks, err := collect()
+			Expect(chunks).To(HaveExactElements("before", "after"))
+			Expect(err).To(MatchError(ContainSubstring("unknown")))
+		})
+	})
+})
@@ -0,0 +1,217 @@
+package integration_test
+
+import (
+	"io"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// countingLoader records the number of times each template is read
+type countingLoader struct {
+	loaders.Loader
+	reads map[string]int
+}
+
+func (c *countingLoader) Read(path string) (io.Reader, error) {
+	c.reads[path]++
+	return c.Loader.Read(path)
+}
+
+func (c *countingLoader) Inherit(from string) (loaders.Loader, error) {
+	loader, err := c.Loader.Inherit(from)
+	if err != nil {
+		return nil, err
+	}
+	return &countingLoader{Loader: loader, reads: c.reads}, nil
+}
+
+var _ = Context("template cache", func() {
+	var (
+		loader      = new(*countingLoader)
+		environment = new(*exec.Environment)
+	)
+	BeforeEach(func() {
+		*loader = &countingLoader{
+			Loader: loaders.MustNewMemoryLoader(map[string]string{
+				"/page":    `{% extends "/base" %}{% block content %}{% include "/partial" %}{% endblock %}`,
+				"/base":    `<main>{% block content %}{% endblock %}</main>`,
+				"/partial": `{% import "/macros" as m %}{{ m.greet("bob") }}`,
+				"/macros":  `{% macro greet(name) %}hello {{ name }}{% endmacro %}`,
+				"/other":   `other`,
+			}),
+			reads: map[string]int{},
+		}
+		copied := *gonja.DefaultEnvironment
+		copied.Loader = *loader
+		copied.Cache = exec.NewTemplateCache(4)
+		*environment = &copied
+	})
+	It("should return the same template when getting it twice", func() {
+		first, err := (*environment).GetTemplate("/other")
+		Expect(err).To(BeNil())
+		second, err := (*environment).GetTemplate("/other")
+		Expect(err).To(BeNil())
+		Expect(second).To(BeIdenticalTo(first))
+		Expect((*loader).reads["/other"]).To(Equal(1))
+	})
+	It("should share the cache with included, extended and imported templates", func() {
+		for range 3 {
+			template, err := (*environment).GetTemplate("/page")
+			Expect(err).To(BeNil())
+			result, err := template.ExecuteToString(nil)
+			Expect(err).To(BeNil())
+			AssertPrettyDiff("<main>hello bob</main>", result)
+		}
+		Expect((*loader).reads).To(Equal(map[string]int{
+			"/page":    1,
+			"/base":    1,
+			"/partial": 1,
+			"/macros":  1,
+		}))
+		Expect((*environment).Cache.Len()).To(Equal(4))
+	})
+	It("should evict the least recently used templates once full", func() {
+		template, err := (*environment).GetTemplate("/page")
+		Expect(err).To(BeNil())
+		_, err = template.ExecuteToString(nil)
+		Expect(err).To(BeNil())
+		_, err = (*environment).GetTemplate("/other")
+		Expect(err).To(BeNil())
+		Expect((*environment).Cache.Len()).To(Equal(4))
+		By("evicting the extended template parsed first")
+		_, err = (*environment).GetTemplate("/base")
+		Expect(err).To(BeNil())
+		Expect((*loader).reads["/base"]).To(Equal(2))
+		By("keeping the templates used more recently")
+		_, err = (*environment).GetTemplate("/partial")
+		Expect(err).To(BeNil())
+		Expect((*loader).reads["/partial"]).To(Equal(1))
+	})
+	It("should not share templates between environments using different loaders", func() {
+		other := **environment
+		other.Loader = loaders.MustNewMemoryLoader(map[string]string{
+			"/other": `another other`,
+		})
+		for range 2 {
+			template, err := (*environment).GetTemplate("/other")
+			Expect(err).To(BeNil())
+			Expect(template.ExecuteToString(nil)).To(Equal("other"))
+			template, err = other.GetTemplate("/other")
+			Expect(err).To(BeNil())
+			Expect(template.ExecuteToString(nil)).To(Equal("another other"))
+		}
+	})
+	It("should not share included templates between templates rendered with different loaders", func() {
+		for _, partial := range []string{"first", "second"} {
+			loader := loaders.MustNewMemoryLoader(map[string]string{
+				"/page":    `{% include "/partial" %}`,
+				"/partial": partial,
+			})
+			template, err := exec.NewTemplate("/page", gonja.DefaultConfig, loader, *environment)
+			Expect(err).To(BeNil())
+			Expect(template.ExecuteToString(nil)).To(Equal(partial))
+		}
+	})
+	It("should not share templates parsed with a different syntax", func() {
+		_, err := (*environment).GetTemplate("/other")
+		Expect(err).To(BeNil())
+		other := **environment
+		other.Config = gonja.DefaultConfig.Inherit()
+		other.Config.TrimBlocks = true
+		_, err = other.GetTemplate("/other")
+		Expect(err).To(BeNil())
+		Expect((*loader).reads["/other"]).To(Equal(2))
+	})
+	It("should not cache templates without a cache", func() {
+		(*environment).Cache = nil
+		for range 2 {
+			_, err := (*environment).GetTemplate("/other")
+			Expect(err).To(BeNil())
+		}
+		Expect((*loader).reads["/other"]).To(Equal(2))
+	})
+	It("should fail to get templates without a loader", func() {
+		(*environment).Loader = nil
+		_, err := (*environment).GetTemplate("/other")
+		Expect(err).To(MatchError("environment has no loader to get templates from"))
+	})
+	It("should fail to get missing templates", func() {
+		_, err := (*environment).GetTemplate("/missing")
+		Expect(err).ToNot(BeNil())
+		Expect((*environment).Cache.Len()).To(Equal(0))
+	})
+})
+
+var _ = Context("template cache auto reload", func() {
+	var (
+		sources     = new(map[string]string)
+		environment = new(*exec.Environment)
+
+		render = func(name string) string {
+			template, err := (*environment).GetTemplate(name)
+			Expect(err).To(BeNil())
+			result, err := template.ExecuteToString(nil)
+			Expect(err).To(BeNil())
+			return result
+		}
+	)
+	BeforeEach(func() {
+		*sources = map[string]string{
+			"/page":    `{% extends "/layout" %}{% block content %}page{% endblock %}`,
+			"/layout":  `{% extends "/base" %}{% block main %}[{% block content %}{% endblock %}]{% endblock %}`,
+			"/base":    `<main>{% block main %}{% endblock %}</main>{% include "/footer" %}`,
+			"/footer":  `<footer/>`,
+			"/unknown": `unused`,
+		}
+		copied := *gonja.DefaultEnvironment
+		copied.Loader = loaders.MustNewMemoryLoader(*sources)
+		copied.Cache = exec.NewTemplateCache(0)
+		copied.Cache.AutoReload = true
+		*environment = &copied
+	})
+	JustBeforeEach(func() {
+		AssertPrettyDiff("<main>[page]</main><footer/>", render("/page"))
+	})
+	It("should keep returning the cached template while nothing changes", func() {
+		first, err := (*environment).GetTemplate("/page")
+		Expect(err).To(BeNil())
+		second, err := (*environment).GetTemplate("/page")
+		Expect(err).To(BeNil())
+		Expect(second).To(BeIdenticalTo(first))
+	})
+	It("should reload the template when its source changes", func() {
+		(*sources)["/page"] = `{% extends "/layout" %}{% block content %}changed{% endblock %}`
+		AssertPrettyDiff("<main>[changed]</main><footer/>", render("/page"))
+	})
+	It("should reload the template when the template it extends changes", func() {
+		(*sources)["/layout"] = `{% extends "/base" %}{% block main %}({% block content %}{% endblock %}){% endblock %}`
+		AssertPrettyDiff("<main>(page)</main><footer/>", render("/page"))
+	})
+	It("should reload the template when any of its ancestors changes", func() {
+		(*sources)["/base"] = `<body>{% block main %}{% endblock %}</body>{% include "/footer" %}`
+		AssertPrettyDiff("<body>[page]</body><footer/>", render("/page"))
+	})
+	It("should reload included templates when they change", func() {
+		(*sources)["/footer"] = `<footer>changed</footer>`
+		AssertPrettyDiff("<main>[page]</main><footer>changed</footer>", render("/page"))
+	})
+	It("should return an error once the template cannot be parsed anymore", func() {
+		(*sources)["/page"] = `{% if %}`
+		_, err := (*environment).GetTemplate("/page")
+		Expect(err).ToNot(BeNil())
+	})
+	Context("when auto reload is disabled", func() {
+		BeforeEach(func() {
+			(*environment).Cache.AutoReload = false
+		})
+		It("should keep returning the cached template", func() {
+			(*sources)["/page"] = `{% extends "/layout" %}{% block content %}changed{% endblock %}`
+			AssertPrettyDiff("<main>[page]</main><footer/>", render("/page"))
+		})
+	})
+})
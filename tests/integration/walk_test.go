@@ -0,0 +1,128 @@
+package integration_test
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/nikolalohinski/gonja/v2"
+	"github.com/nikolalohinski/gonja/v2/exec"
+	"github.com/nikolalohinski/gonja/v2/loaders"
+	"github.com/nikolalohinski/gonja/v2/nodes"
+	"github.com/nikolalohinski/gonja/v2/tokens"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Context("walking templates", func() {
+	var (
+		source = new(string)
+
+		template    = new(*exec.Template)
+		returnedErr = new(error)
+	)
+	BeforeEach(func() {
+		*source = strings.Join([]string{
+			`{% extends "/base.html" %}`,
+			`{% import "/macros.html" as m1 %}{% from "/macros.html" import x as m2 %}`,
+			`{% block content %}{{ super() }}{{ a | default(b, c=d) }}{{ e is divisibleby(f) }}{% endblock %}`,
+			`{% macro m(g=h) %}{{ i }}{% endmacro %}`,
+			`{% call(j) m(k) %}{{ l }}{% endcall %}`,
+			`{% for n in o if p %}{{ q[r] }}{% else %}{{ s[t:u] }}{% endfor %}`,
+			`{% if v %}{{ w }}{% elif x %}{{ y }}{% else %}{{ not z }}{% endif %}`,
+			`{% set aa = ab + -ac %}{% do ad.append(ae) if af else ag %}`,
+			`{% with ah = ai %}{{ [aj, (ak,), {"al": am}] }}{% endwith %}`,
+			`{% filter upper(an) %}{{ ao if ap else aq }}{% endfilter %}`,
+			`{% autoescape true %}{{ ar.as }}{% endautoescape %}`,
+			`{% include at %}{% trans au=av %}{{ au }}{{ aw }}{% endtrans %}`,
+			`{% raw %}{{ ax }}{% endraw %}{# {{ ay }} #}`,
+		}, "")
+	})
+	JustBeforeEach(func() {
+		loader := loaders.MustNewMemoryLoader(map[string]string{
+			"/page.html":   *source,
+			"/base.html":   `{% block content %}{% endblock %}`,
+			"/macros.html": `{% macro x() %}{% endmacro %}`,
+		})
+		*template, *returnedErr = exec.NewTemplate("/page.html", gonja.DefaultConfig, loader, gonja.DefaultEnvironment)
+	})
+	It("should visit every node", func() {
+		Expect(*returnedErr).To(BeNil())
+		var names, filters, tests []string
+		nodes.Inspect((*template).Root(), func(node nodes.Node) bool {
+			switch n := node.(type) {
+			case *nodes.Name:
+				names = append(names, n.Name.Val)
+			case *nodes.FilterCall:
+				filters = append(filters, n.Name)
+			case *nodes.TestCall:
+				tests = append(tests, n.Name)
+			}
+			return true
+		})
+		Expect(names).To(Equal([]string{
+			"super", "a", "b", "d", "e", "f",
+			"h", "i", "m", "k", "l", "o", "p", "q", "r", "s", "t", "u", "v", "w", "x", "y", "z",
+			"aa", "ab", "ac", "ad", "ae", "af", "ag", "ai", "aj", "ak", "am", "an", "ao", "ap", "aq", "ar",
+			"at", "av", "aw",
+		}))
+		Expect(filters).To(Equal([]string{"default", "upper"}))
+		Expect(tests).To(Equal([]string{"divisibleby"}))
+	})
+	It("should stop walking the children of a node when told so", func() {
+		Expect(*returnedErr).To(BeNil())
+		var names []string
+		nodes.Inspect((*template).Root(), func(node nodes.Node) bool {
+			if name, ok := node.(*nodes.Name); ok {
+				names = append(names, name.Name.Val)
+			}
+			_, isLoop := node.(*nodes.ControlStructureBlock)
+			return !isLoop || node.(*nodes.ControlStructureBlock).Name != "for"
+		})
+		Expect(names).ToNot(ContainElements("o", "p", "q"))
+		Expect(names).To(ContainElements("m", "v"))
+	})
+	Context("when rewriting a template", func() {
+		BeforeEach(func() {
+			*source = `{# comment #}{% for item in items %}{{ item | upper }}{% endfor %}{% if user %}{{ user.name }}{% endif %}`
+		})
+		It("should render the rewritten template", func() {
+			Expect(*returnedErr).To(BeNil())
+			root, err := nodes.Rewrite(nodes.RewriterFunc(func(node nodes.Node) (nodes.Node, error) {
+				switch n := node.(type) {
+				case *nodes.Comment:
+					return nil, nil
+				case *nodes.Name:
+					if n.Name.Val == "items" {
+						return &nodes.Name{Name: &tokens.Token{Type: tokens.Name, Val: "values", Line: n.Name.Line, Col: n.Name.Col}}, nil
+					}
+				case *nodes.FilterCall:
+					n.Name = "lower"
+				}
+				return node, nil
+			}), (*template).Root())
+			Expect(err).To(BeNil())
+			Expect(root).To(BeIdenticalTo((*template).Root()))
+			Expect(slices.ContainsFunc((*template).Root().Nodes, func(node nodes.Node) bool {
+				_, isComment := node.(*nodes.Comment)
+				return isComment
+			})).To(BeFalse())
+			output, err := (*template).ExecuteToString(exec.NewContext(map[string]any{
+				"values": []string{"A", "B"},
+				"user":   map[string]string{"name": "Ann"},
+			}))
+			Expect(err).To(BeNil())
+			Expect(output).To(Equal("abAnn"))
+		})
+		It("should fail to replace a node with a node of another type", func() {
+			Expect(*returnedErr).To(BeNil())
+			_, err := nodes.Rewrite(nodes.RewriterFunc(func(node nodes.Node) (nodes.Node, error) {
+				if _, ok := node.(*nodes.Wrapper); ok {
+					return &nodes.Comment{}, nil
+				}
+				return node, nil
+			}), (*template).Root())
+			Expect(err).To(MatchError(ContainSubstring("expected *nodes.Wrapper, got *nodes.Comment")))
+		})
+	})
+})
@@ -100,19 +100,53 @@ func NewLexer(input string, config *config.Config) *Lexer {
 func Lex(input string, config *config.Config) *Stream {
 	l := NewLexer(input, config)
 	go l.Run()
-	return NewStream(l.Tokens)
+	stream := NewStream(l.Tokens)
+	stream.Source = l.Input
+	return stream
 }
 
 // LexAll lexes the input synchronously, collecting all tokens into a slice.
 // This avoids goroutine/channel overhead.
 func LexAll(input string, cfg *config.Config) *Stream {
-	l := NewLexer(input, cfg)
+	return NewLexer(input, cfg).collect()
+}
+
+// LexFrom lexes the source of a stream like LexAll, but from the given offset, the tokens being located in the
+// whole source. The source must be normalized by the lexer like the Source of streams, and the offset must not
+// be within a tag, a variable or a comment.
+func LexFrom(source string, cfg *config.Config, offset int) *Stream {
+	l := NewLexer("", cfg)
+	l.Input = source
+	l.lineOffsets = PrecomputeLineOffsets(source)
+	l.Start, l.Pos = offset, offset
+	return l.collect()
+}
+
+// Resume lexes the rest of the source of a stream whose lexing stopped at the given error token, from the first
+// tag, variable or comment starting after the error, so that the following tokens can be checked as well.
+func Resume(source string, cfg *config.Config, failed *Token) *Stream {
+	offset := len(source)
+	if lineOffsets := PrecomputeLineOffsets(source); failed.Line > 0 && failed.Line <= len(lineOffsets) {
+		start := min(lineOffsets[failed.Line-1]+failed.Col, len(source))
+		for _, delimiter := range []string{cfg.BlockStartString, cfg.VariableStartString, cfg.CommentStartString} {
+			if index := strings.Index(source[start:], delimiter); index >= 0 {
+				offset = min(offset, start+index)
+			}
+		}
+	}
+	return LexFrom(source, cfg, offset)
+}
+
+// collect lexes the input synchronously into the stream of its tokens
+func (l *Lexer) collect() *Stream {
 	// Estimate ~1 token per 3 bytes as initial capacity (measured ratio is ~3.8)
-	estTokens := len(l.Input)/3 + 16
+	estTokens := (len(l.Input)-l.Pos)/3 + 16
 	l.collected = make([]*Token, 0, estTokens)
 	l.tokenSlab = make([]Token, estTokens)
 	l.runSync()
-	return NewStream(l.collected)
+	stream := NewStream(l.collected)
+	stream.Source = l.Input
+	return stream
 }
 
 // allocToken returns a pointer to a Token from the pre-allocated slab,
@@ -149,6 +183,7 @@ func (l *Lexer) errorf(format string, args ...any) lexFn {
 	tok.Type = Error
 	tok.Val = fmt.Sprintf(format, args...)
 	tok.Pos = l.Pos
+	tok.Line, tok.Col, l.lineHint = ReadablePositionHinted(l.Start, l.lineOffsets, l.lineHint)
 	l.sendToken(tok)
 	return nil
 }
@@ -6,6 +6,9 @@ import "fmt"
 var sentinelEOF = &Token{Type: EOF}
 
 type Stream struct {
+	// Source is the normalized input the tokens were lexed from, if known
+	Source string
+
 	it       TokenIterator
 	previous *Token
 	current  *Token